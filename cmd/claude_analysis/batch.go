@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"claude_analysis/core/batch"
+	"claude_analysis/core/telemetry"
+)
+
+// batchLine 是 batch 命令输出的一行 NDJSON：一个会话的记录，或一个无法分析的 transcript
+type batchLine struct {
+	Project    string                              `json:"project"`
+	Transcript string                              `json:"transcript"`
+	Error      string                              `json:"error,omitempty"`
+	Record     *telemetry.ClaudeCodeAnalysisRecord `json:"record,omitempty"`
+}
+
+// runBatch 实现 batch 命令：并发分析目录或 glob 下的所有 transcript
+func runBatch(args []string) int {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of transcripts analyzed concurrently")
+	output := fs.String("o", "", "Write the NDJSON results to this file instead of stdout; the summary then goes to stdout")
+	summaryFormat := fs.String("summary", "table", "Summary format: table, json or none")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: claude_analysis batch [flags] [dir|file|glob ...]\n\n"+
+			"Analyze every transcript (*.jsonl) under the given directories, files or globs\n"+
+			"(default ~/.claude/projects). One NDJSON line per session is written to stdout,\n"+
+			"and a summary per project directory to stderr.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if *summaryFormat != "table" && *summaryFormat != "json" && *summaryFormat != "none" {
+		fmt.Fprintf(os.Stderr, "invalid -summary: %q\n", *summaryFormat)
+		return 2
+	}
+
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.Printf("[ERROR] Failed to locate home directory: %v", err)
+			return 1
+		}
+		patterns = []string{filepath.Join(home, ".claude", "projects")}
+	}
+	transcripts, err := batch.Find(patterns)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		return 1
+	}
+	if len(transcripts) == 0 {
+		fmt.Fprintln(os.Stderr, "No transcripts found.")
+		return 1
+	}
+
+	results, summaryOut := io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Printf("[ERROR] Failed to create output file: %v", err)
+			return 1
+		}
+		defer file.Close()
+		results, summaryOut = file, os.Stdout
+	}
+
+	start := time.Now()
+	encoder := json.NewEncoder(results)
+	summarizer := batch.NewSummarizer()
+	var writeErr error
+	batch.Run(transcripts, *workers, func(result *batch.Result) {
+		summarizer.Add(result)
+		if result.Err != nil {
+			log.Printf("[WARN] Failed to analyze %s: %v", result.Path, result.Err)
+			writeErr = firstError(writeErr, encoder.Encode(batchLine{Project: result.Project, Transcript: result.Path, Error: result.Err.Error()}))
+			return
+		}
+		for i := range result.Records {
+			writeErr = firstError(writeErr, encoder.Encode(batchLine{Project: result.Project, Transcript: result.Path, Record: &result.Records[i]}))
+		}
+	})
+	if writeErr != nil {
+		log.Printf("[ERROR] Failed to write results: %v", writeErr)
+		return 1
+	}
+
+	summary := summarizer.Summary()
+	switch *summaryFormat {
+	case "table":
+		writeBatchSummary(summaryOut, summary)
+		fmt.Fprintf(summaryOut, "\nAnalyzed %d transcript(s) in %s\n", len(transcripts), time.Since(start).Round(time.Millisecond))
+	case "json":
+		data, _ := json.MarshalIndent(summary, "", "  ")
+		fmt.Fprintln(summaryOut, string(data))
+	}
+	if summary.Total.Errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// writeBatchSummary 以对齐的表格输出各项目的汇总
+func writeBatchSummary(w io.Writer, summary *batch.Summary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tSESSIONS\tERRORS\tTOOL CALLS\tWRITTEN\tDIFF CHARS\tINPUT TOKENS\tOUTPUT TOKENS\tLAST ACTIVE")
+	for _, project := range append(summary.Projects, &summary.Total) {
+		lastActive := "-"
+		if project.LastActivity > 0 {
+			lastActive = time.Unix(project.LastActivity, 0).Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			truncateText(project.Project, maxTitleWidth),
+			project.Sessions,
+			project.Errors,
+			project.ToolCalls,
+			project.WriteLines,
+			project.DiffCharacters,
+			project.InputTokens,
+			project.OutputTokens,
+			lastActive,
+		)
+	}
+	tw.Flush()
+}
+
+func firstError(current, err error) error {
+	if current != nil {
+		return current
+	}
+	return err
+}
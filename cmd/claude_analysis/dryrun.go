@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/hook"
+	"claude_analysis/core/otlp"
+	"claude_analysis/core/sink"
+	"claude_analysis/core/submission"
+	"claude_analysis/core/telemetry"
+)
+
+// dryRunReport 是 dry-run 的输出：每个 sink 将收到的最终负载，以及各字段的来源说明
+type dryRunReport struct {
+	DryRun       bool              `json:"dryRun"`
+	SessionID    string            `json:"sessionId"`
+	Transcript   string            `json:"transcriptPath"`
+	GeneratedAt  time.Time         `json:"generatedAt"`
+	Error        string            `json:"error,omitempty"`
+	Throttled    string            `json:"throttled,omitempty"`      // 提交策略限流的原因，正常运行时这次不会发送
+	Folded       []string          `json:"foldedSessions,omitempty"` // 一并发送的、之前被限流的会话
+	Skipped      []string          `json:"skipped"`                  // 正常运行时会执行、dry-run 跳过的步骤
+	Sinks        []dryRunSink      `json:"sinks"`
+	FieldSources map[string]string `json:"fieldSources"`
+}
+
+// dryRunSink 描述单个 sink 在正常运行时会收到的内容
+type dryRunSink struct {
+	Name           string                        `json:"name"`
+	Type           string                        `json:"type"`
+	Destination    string                        `json:"destination,omitempty"`
+	Redaction      string                        `json:"redaction"`
+	RedactedFields map[string]string             `json:"redactedFields,omitempty"` // 脱敏修改了哪些字段
+	Filtered       bool                          `json:"filtered,omitempty"`       // 过滤后没有记录，不会发送
+	Requests       int                           `json:"requests,omitempty"`       // http：拆分后的请求数
+	Note           string                        `json:"note,omitempty"`
+	Error          string                        `json:"error,omitempty"`
+	Payload        *telemetry.ClaudeCodeAnalysis `json:"payload,omitempty"`
+	Body           string                        `json:"body,omitempty"` // webhook：模板渲染后的请求内容
+}
+
+// runDryRun 产生 dry-run 报告，不做任何网络请求
+// toStdout 为 true（命令行指定 -dry-run）时直接输出报告；否则（settings.json env 启用）
+// 写入 DataDir/dry-run 并以 systemMessage 提示，保持 hook 协议
+func runDryRun(cfg *config.Config, baseURL string, hookInput *hook.Input, toStdout bool) *hook.Output {
+	if baseURL != "" {
+		cfg.API.Endpoint = baseURL
+	}
+	data, _ := json.MarshalIndent(explain(cfg, hookInput), "", "  ")
+	if toStdout {
+		fmt.Println(string(data))
+		return nil
+	}
+
+	output := &hook.Output{SuppressOutput: true}
+	file, err := saveDryRunReport(cfg.DataDir, hookInput.SessionID, data)
+	if err != nil {
+		log.Printf("[ERROR] Failed to save dry-run report: %v", err)
+		return output
+	}
+	log.Printf("[INFO] Dry run, nothing was sent. Report saved to %s", file)
+	output.SystemMessage = fmt.Sprintf("claude_analysis dry run: nothing was uploaded, see %s", file)
+	return output
+}
+
+// explain 按正常流程产生分析结果，并对每个 sink 套用过滤与脱敏，但不调用 Send
+func explain(cfg *config.Config, hookInput *hook.Input) *dryRunReport {
+	report := &dryRunReport{
+		DryRun:       true,
+		SessionID:    hookInput.SessionID,
+		Transcript:   hookInput.TranscriptPath,
+		GeneratedAt:  time.Now(),
+		Skipped:      []string{"update check", "spool retry", "history store"},
+		FieldSources: telemetry.FieldSources(),
+	}
+	if otlp.Enabled(cfg.OTLP) {
+		report.Skipped = append(report.Skipped, "OTLP export")
+	}
+
+	sinks, err := sink.Build(cfg, sink.Options{Stdout: io.Discard})
+	if err != nil {
+		report.Error = fmt.Sprintf("invalid sink configuration: %v", err)
+		return report
+	}
+	analysis, err := buildAnalysis(cfg, hookInput)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	// 只检查提交策略，不更新限流状态
+	if decision, err := submission.New(cfg.DataDir, cfg.Submission).Check(hookInput.SessionID, time.Now()); err != nil {
+		log.Printf("[WARN] Failed to check submission policy: %v", err)
+	} else if !decision.Allowed {
+		report.Throttled = decision.Reason
+	} else if len(decision.Folded) > 0 {
+		for _, input := range decision.Folded {
+			report.Folded = append(report.Folded, input.SessionID)
+		}
+		foldPending(cfg, analysis, decision.Folded)
+	}
+
+	for _, s := range sinks {
+		entry := dryRunSink{
+			Name:           s.Name,
+			Type:           s.Type,
+			Destination:    s.Destination,
+			Redaction:      s.Redaction,
+			RedactedFields: sink.RedactedFields(s.Redaction),
+		}
+		prepared, err := s.Prepare(analysis)
+		switch {
+		case err != nil:
+			entry.Error = err.Error()
+		case prepared == nil:
+			entry.Filtered = true
+		default:
+			entry.Payload = prepared
+			explainSink(cfg, s, prepared, &entry)
+		}
+		report.Sinks = append(report.Sinks, entry)
+	}
+	return report
+}
+
+// explainSink 补充各类 sink 实际送出的形式
+func explainSink(cfg *config.Config, s *sink.Configured, prepared *telemetry.ClaudeCodeAnalysis, entry *dryRunSink) {
+	switch s.Type {
+	case config.SinkHTTP:
+		parts, err := telemetry.SplitAnalysis(prepared, cfg.API.MaxPayloadBytes)
+		if err != nil {
+			entry.Error = err.Error()
+			return
+		}
+		entry.Requests = len(parts)
+		for _, part := range parts {
+			if err := telemetry.Validate(part); err != nil {
+				entry.Error = err.Error()
+				return
+			}
+		}
+		if len(parts) > 1 {
+			entry.Note = "The payload is uploaded in several parts, split by record details"
+		}
+	case config.SinkFile:
+		entry.Note = "Appended to the file as one JSON line"
+	case config.SinkStdout:
+		entry.Note = "Written as one JSON line to stderr"
+	case config.SinkPrometheus:
+		entry.Note = "Only cumulative counters (sessions, tool calls, tokens, lines) labeled with repo and user leave the machine"
+		entry.Payload = nil
+	}
+	if previewer, ok := s.Sink.(sink.Previewer); ok {
+		body, err := previewer.Preview(prepared)
+		if err != nil {
+			entry.Error = err.Error()
+			return
+		}
+		entry.Body = string(body)
+	}
+}
+
+// saveDryRunReport 将报告写入 DataDir/dry-run/<session>.json，返回文件路径
+func saveDryRunReport(dataDir, sessionID string, data []byte) (string, error) {
+	dir := filepath.Join(dataDir, "dry-run")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(sessionID)
+	if name == "" {
+		name = "unknown-session"
+	}
+	file := filepath.Join(dir, name+".json")
+	if err := os.WriteFile(file, append(data, '\n'), 0o600); err != nil {
+		return "", err
+	}
+	return file, nil
+}
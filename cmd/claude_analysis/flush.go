@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/spool"
+	"claude_analysis/core/telemetry"
+)
+
+// drainSpool 重送离线队列中的 payload，每个 payload 发送到其原本的 endpoint
+func drainSpool(cfg *config.Config, force bool) (*spool.DrainResult, error) {
+	clients := make(map[string]*telemetry.Client)
+	send := func(entry *spool.Entry) error {
+		client, ok := clients[entry.Endpoint]
+		if !ok {
+			entryCfg := *cfg
+			entryCfg.API.Endpoint = entry.Endpoint
+			client = telemetry.New(&entryCfg)
+			clients[entry.Endpoint] = client
+		}
+		_, err := client.SubmitWithKey(entry.Payload, entry.IdempotencyKey)
+		if err != nil && !telemetry.IsRetryable(err) {
+			return fmt.Errorf("%w: %v", spool.ErrRejected, err)
+		}
+		return err
+	}
+	return spool.New(cfg.DataDir).Drain(send, force, time.Now())
+}
+
+// runFlush 实现 flush 命令：手动重送离线队列
+func runFlush(args []string) int {
+	fs := flag.NewFlagSet("flush", flag.ExitOnError)
+	force := fs.Bool("force", false, "Retry spooled payloads even if their backoff has not elapsed")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: claude_analysis flush [-force]\n\nResend telemetry payloads that failed to upload.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	result, err := drainSpool(loadConfig(), *force)
+	if err != nil {
+		log.Printf("[ERROR] Failed to flush spool: %v", err)
+		fmt.Printf("{\"status\": \"error\", \"message\": %q}\n", err.Error())
+		return 1
+	}
+	jsonOutput, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(jsonOutput))
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -8,37 +8,68 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"claude_analysis/core/background"
 	"claude_analysis/core/config"
+	"claude_analysis/core/history"
+	"claude_analysis/core/hook"
+	"claude_analysis/core/otlp"
+	"claude_analysis/core/policy"
+	"claude_analysis/core/sink"
+	"claude_analysis/core/spool"
+	"claude_analysis/core/submission"
 	"claude_analysis/core/telemetry"
 	"claude_analysis/core/updater"
 	"claude_analysis/core/version"
 )
 
+// eventLogRetention 是本地事件日志的保留时间
+const eventLogRetention = 30 * 24 * time.Hour
+
+// eventPruneInterval 是 Stop 与 SessionEnd 清理事件日志的最小间隔；长时间不重新开始的会话也会定期清理
+const eventPruneInterval = 24 * time.Hour
+
+// subcommands 是以第一个参数选择的子命令
+var subcommands = map[string]func(args []string) int{
+	"batch":  runBatch,
+	"flush":  runFlush,
+	"query":  runQuery,
+	"report": runReport,
+	"schema": runSchema,
+	"worker": runWorker,
+}
+
 // parseJSONLFile 直接解析 JSONL 文件并生成分析结果
-func parseJSONLFile(filePath, outputPath string) error {
+func parseJSONLFile(filePath, outputPath string, checkSurvival bool) error {
 	// 检查输入文件是否存在
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return fmt.Errorf("input file does not exist: %s", filePath)
 	}
 
 	log.Printf("[INFO] Reading JSONL file: %s", filePath)
-	data, err := telemetry.ReadJSONL(filePath)
+	analysis, err := telemetry.AnalyzeTranscript(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read JSONL file: %v", err)
 	}
 
-	// 使用新的分析逻辑
-	analysis := telemetry.AnalyzeConversations(data)
-
 	// Load configuration for metadata
 	cfg := config.Default()
 
+	// 按需比对工作区，统计代码存活情况
+	if checkSurvival || cfg.Analysis.CodeSurvival {
+		for i := range analysis.Records {
+			telemetry.CheckCodeSurvival(&analysis.Records[i])
+		}
+	}
+
 	// 设置顶级字段
 	analysis.User = cfg.UserName
 	analysis.ExtensionName = cfg.ExtensionName
 	analysis.MachineID = cfg.MachineID
 	analysis.InsightsVersion = cfg.InsightsVersion
+	telemetry.NormalizePaths(&analysis)
 
 	// 将分析结果转换为 JSON
 	jsonData, err := json.MarshalIndent(analysis, "", "  ")
@@ -66,41 +97,189 @@ func parseJSONLFile(filePath, outputPath string) error {
 	return nil
 }
 
-// readStdinAndSave reads JSON data from stdin, sends it to API and returns response
-func readStdinAndSave(baseURL string) map[string]interface{} {
-	// Load configuration
-	cfg := config.Default()
+// readHookInput reads and parses the hook event from stdin
+func readHookInput() (*hook.Input, error) {
+	stdinData, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return hook.ParseInput(stdinData)
+}
 
-	// Override API endpoint if baseURL is provided
-	if baseURL != "" {
-		cfg.API.Endpoint = baseURL
+// recordHookEvent 记录 hook 事件到本地事件日志，返回需要输出的 hook JSON 以及是否需要继续执行 Stop 分析
+func recordHookEvent(cfg *config.Config, hookInput *hook.Input) (*hook.Output, bool) {
+	// 旧版输入没有 hook_event_name，视为 Stop
+	if hookInput.HookEventName == "" {
+		hookInput.HookEventName = hook.EventStop
 	}
 
-	// Create telemetry client
-	client := telemetry.New(cfg)
+	now := time.Now()
+	event := hook.NewEvent(hookInput, now)
+	var output *hook.Output
+	if hookInput.HookEventName == hook.EventPreToolUse {
+		if decision := evaluatePolicy(cfg, hookInput); decision != nil {
+			event.Decision = decision.Decision
+			event.DecisionReason = decision.Reason
+			event.RuleID = decision.RuleID
+			event.Target = decision.Target
+			output = hook.PermissionOutput(decision.Decision, decision.Reason)
+		}
+	}
+	if _, err := hook.RecordEvent(cfg.DataDir, event); err != nil {
+		log.Printf("[WARN] Failed to record %s event: %v", hookInput.HookEventName, err)
+	}
+	switch hookInput.HookEventName {
+	case hook.EventSessionStart:
+		hook.PruneEvents(cfg.DataDir, eventLogRetention, now)
+	case hook.EventStop, hook.EventSessionEnd:
+		hook.PruneEventsEvery(cfg.DataDir, eventLogRetention, eventPruneInterval, now)
+	}
+	return output, hookInput.HookEventName == hook.EventStop
+}
 
-	// 读取 stdin
-	stdinData, err := io.ReadAll(os.Stdin)
+// runHook 处理一次 hook 调用并返回要输出给 Claude Code 的 JSON
+// 任何错误（包括 panic）都只记录日志，不会阻断或中断用户的会话
+// dryRun 为 true 时以 dry-run 处理 Stop 事件并把报告输出到 stdout
+func runHook(baseURL string, skipUpdateCheck, dryRun bool) (output *hook.Output) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[ERROR] claude_analysis panicked: %v", r)
+			output = &hook.Output{SuppressOutput: true}
+		}
+	}()
+
+	hookInput, err := readHookInput()
+	if err != nil {
+		log.Printf("[ERROR] Failed to parse hook input: %v", err)
+		return &hook.Output{SuppressOutput: true}
+	}
+
+	// 非 Stop 事件只写入本地事件日志（PreToolUse 另外输出策略决策），保持轻量
+	cfg := loadConfig()
+	output, isStop := recordHookEvent(cfg, hookInput)
+	dryRunMode := dryRun || cfg.DryRun
+	if !isStop {
+		log.Printf("[INFO] Recorded %s event", hookInput.HookEventName)
+		// 会话结束时补上涵盖整个会话的根 span
+		if hookInput.HookEventName == hook.EventSessionEnd && otlp.Enabled(cfg.OTLP) && otlp.Supported(cfg) && !dryRunMode {
+			if !cfg.Background.Enabled || startBackground(cfg, newJob(hookInput, baseURL, skipUpdateCheck)) != nil {
+				exportOTLP(cfg, hookInput.TranscriptPath, true)
+			}
+		}
+		return output
+	}
+
+	output = &hook.Output{SuppressOutput: true}
+	// stop_hook_active 表示 Claude 正因 Stop hook 而继续执行，此时跳过分析与提示以免循环；
+	// 下一次正常的 Stop 会重新分析完整的 transcript
+	if hookInput.StopHookActive {
+		log.Printf("[INFO] stop_hook_active is set, skipping analysis")
+		return output
+	}
+
+	// dry-run 只产生负载与来源说明，不做任何网络请求（包括更新检查）
+	if dryRunMode {
+		return runDryRun(cfg, baseURL, hookInput, dryRun)
+	}
+
+	// 分析与上传交给脱离的背景行程，hook 立即返回；背景行程检查到的更新在下一次 Stop 提示
+	if cfg.Background.Enabled {
+		err := startBackground(cfg, newJob(hookInput, baseURL, skipUpdateCheck))
+		if err == nil {
+			output.SystemMessage = takeUpdateNotice(cfg.DataDir)
+			return output
+		}
+		log.Printf("[WARN] Failed to start background worker, processing in foreground: %v", err)
+	}
+
+	var messages []string
+	// 自動檢查更新（除非用戶明確跳過），結果以 systemMessage 提示
+	if !skipUpdateCheck {
+		notice, err := updater.UpdateNotice(cfg.Proxy)
+		if err != nil {
+			log.Printf("[WARN] Update check failed: %v", err)
+		} else if notice != "" {
+			messages = append(messages, notice)
+		}
+	}
+
+	response := readStdinAndSave(baseURL, hookInput)
+	if jsonResponse, err := json.Marshal(response); err == nil {
+		log.Printf("[INFO] API response: %s", jsonResponse)
+	}
+
+	output.SystemMessage = strings.Join(messages, "\n")
+	return output
+}
+
+// newJob 快照 hook 输入，交给背景行程处理
+func newJob(hookInput *hook.Input, baseURL string, skipUpdateCheck bool) *background.Job {
+	return &background.Job{Input: hookInput, BaseURL: baseURL, SkipUpdateCheck: skipUpdateCheck, QueuedAt: time.Now()}
+}
+
+// evaluatePolicy 按规则文件判断是否允许工具调用，规则文件无效时不拦截
+func evaluatePolicy(cfg *config.Config, hookInput *hook.Input) *policy.Decision {
+	p, err := policy.Load(cfg.Policy.File)
 	if err != nil {
-		log.Printf("[ERROR] Failed to read from stdin: %v", err)
-		return map[string]interface{}{"status": "error", "message": "failed to read stdin"}
+		log.Printf("[WARN] Ignoring policy file %s: %v", cfg.Policy.File, err)
+		return nil
+	}
+	decision := p.Evaluate(hookInput)
+	if decision != nil {
+		log.Printf("[INFO] Policy rule %s: %s %s", decision.RuleID, decision.Decision, hookInput.ToolName)
 	}
+	return decision
+}
+
+// flagPassed 判断命令行是否显式指定了某个参数
+func flagPassed(name string) bool {
+	passed := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
 
-	// STOP mode - extract transcript path and read JSONL file
-	path, err := telemetry.ExtractTranscriptPath(string(stdinData))
+// loadConfig 读取配置文件，失败时记录警告并使用默认配置
+func loadConfig() *config.Config {
+	cfg, err := config.Load()
 	if err != nil {
-		log.Printf("[ERROR] Failed to extract transcript path: %v", err)
-		return map[string]interface{}{"status": "error", "message": "failed to extract transcript path"}
+		log.Printf("[WARN] %v, using defaults", err)
 	}
-	log.Printf("[INFO] Extracted transcript path: %s", path)
-	data, err := telemetry.ReadJSONL(path)
+	return cfg
+}
+
+// buildAnalysis 分析 Stop 事件的 transcript，附加事件日志与顶级字段，返回即将发送的分析结果
+func buildAnalysis(cfg *config.Config, hookInput *hook.Input) (*telemetry.ClaudeCodeAnalysis, error) {
+	// STOP mode - read JSONL file from transcript path
+	if hookInput.TranscriptPath == "" {
+		return nil, fmt.Errorf("failed to extract transcript path: transcript_path is empty")
+	}
+	log.Printf("[INFO] Extracted transcript path: %s", hookInput.TranscriptPath)
+	analysis, err := telemetry.AnalyzeTranscript(hookInput.TranscriptPath)
 	if err != nil {
-		log.Printf("[ERROR] Failed to read JSONL file: %v", err)
-		return map[string]interface{}{"status": "error", "message": "failed to read JSONL file"}
+		return nil, fmt.Errorf("failed to read JSONL file: %w", err)
 	}
 
-	// 使用新的分析逻辑
-	analysis := telemetry.AnalyzeConversations(data)
+	// Stop 时工作区即为最终结果，可选地统计代码存活情况
+	if cfg.Analysis.CodeSurvival {
+		for i := range analysis.Records {
+			telemetry.CheckCodeSurvival(&analysis.Records[i])
+		}
+	}
+
+	// 附加事件日志中的会话边界、事件次数与策略决策
+	if events, err := hook.ReadEvents(cfg.DataDir, hookInput.SessionID); err == nil && len(events) > 0 {
+		summary := hook.Summarize(events)
+		decisions := policyDecisions(summary.Decisions)
+		for i := range analysis.Records {
+			analysis.Records[i].SessionStartedAt = summary.StartedAt
+			analysis.Records[i].HookEventCounts = summary.Counts
+			analysis.Records[i].PolicyDecisions = decisions
+		}
+	}
 
 	// 设置顶级字段
 	analysis.User = cfg.UserName
@@ -108,24 +287,171 @@ func readStdinAndSave(baseURL string) map[string]interface{} {
 	analysis.MachineID = cfg.MachineID
 	analysis.InsightsVersion = cfg.InsightsVersion
 
-	// 将分析结果转换为 payload
-	payload := map[string]interface{}{
-		"user":            analysis.User,
-		"records":         analysis.Records,
-		"extensionName":   analysis.ExtensionName,
-		"machineId":       analysis.MachineID,
-		"insightsVersion": analysis.InsightsVersion,
+	// 统一路径格式（正斜杠、去除多余的 . 与 ..），需在计算幂等键之前
+	telemetry.NormalizePaths(&analysis)
+
+	// 同一会话内容不变时幂等键相同，服务端可据此去重
+	if key, err := telemetry.IdempotencyKey(analysis.MachineID, analysis.Records); err == nil {
+		analysis.IdempotencyKey = key
+	} else {
+		log.Printf("[WARN] Failed to compute idempotency key: %v", err)
+	}
+	return &analysis, nil
+}
+
+// readStdinAndSave analyzes the transcript of a Stop event, sends it to every sink and returns the result
+func readStdinAndSave(baseURL string, hookInput *hook.Input) map[string]interface{} {
+	// Load configuration
+	cfg := loadConfig()
+
+	// Override API endpoint if baseURL is provided
+	if baseURL != "" {
+		cfg.API.Endpoint = baseURL
+	}
+
+	// hook 模式下 stdout 保留给 hook 协议，stdout sink 改写到 stderr
+	sinks, err := sink.Build(cfg, sink.Options{Stdout: os.Stderr, Spool: spool.New(cfg.DataDir)})
+	if err != nil {
+		log.Printf("[ERROR] Invalid sink configuration: %v", err)
+		return map[string]interface{}{"status": "error", "message": "invalid sink configuration"}
+	}
+
+	analysis, err := buildAnalysis(cfg, hookInput)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		return map[string]interface{}{"status": "error", "message": err.Error()}
+	}
+
+	// 保存到本地历史记录（同一会话只保留最新版本），供 query 命令查询
+	if cfg.History.Enabled {
+		if err := history.New(cfg.DataDir).Put(analysis, time.Now()); err != nil {
+			log.Printf("[WARN] Failed to save session history: %v", err)
+		}
+	}
+
+	result := submit(cfg, sinks, hookInput, analysis)
+
+	// 启用 OTLP 时另外导出 traces 与 metrics；不支持的协议只记录警告，不影响这次结果
+	if otlp.Enabled(cfg.OTLP) && otlp.Supported(cfg) {
+		if err := exportOTLP(cfg, hookInput.TranscriptPath, false); err != nil {
+			result["status"] = "error"
+			result["otlp"] = err.Error()
+		} else {
+			result["otlp"] = "ok"
+		}
+	}
+	return result
+}
+
+// submit 按提交策略把分析结果发送到所有 sink
+// 被限流时不发送，该会话记为待上传；允许时一并发送之前被限流的其他会话。
+// 只有所有 sink 都成功时才清除待上传的会话并计入当天次数，失败时留待下一次上传
+func submit(cfg *config.Config, sinks []*sink.Configured, hookInput *hook.Input, analysis *telemetry.ClaudeCodeAnalysis) map[string]interface{} {
+	limiter := submission.New(cfg.DataDir, cfg.Submission)
+	decision, err := limiter.Allow(hookInput, time.Now())
+	var included []*hook.Input
+	switch {
+	case err != nil:
+		log.Printf("[WARN] Submission policy unavailable, sending anyway: %v", err)
+	case !decision.Allowed:
+		log.Printf("[INFO] Upload throttled (%s), session %s will be included in the next upload", decision.Reason, hookInput.SessionID)
+		return map[string]interface{}{"status": "throttled", "reason": decision.Reason}
+	case len(decision.Folded) > 0:
+		included = foldPending(cfg, analysis, decision.Folded)
+	}
+
+	// 发送到所有 sink，各 sink 的失败互不影响
+	status := "success"
+	sinkStatus := make(map[string]string)
+	uploaded := false
+	for _, result := range sink.Dispatch(sinks, analysis) {
+		switch {
+		case result.Err != nil:
+			log.Printf("[ERROR] Sink %s failed: %v", result.Name, result.Err)
+			status = "error"
+			sinkStatus[result.Name] = result.Err.Error()
+		case result.Skipped:
+			sinkStatus[result.Name] = "skipped"
+		default:
+			log.Printf("[INFO] Sent telemetry data to sink %s", result.Name)
+			sinkStatus[result.Name] = "ok"
+			uploaded = uploaded || result.Type == config.SinkHTTP
+		}
+	}
+	if err == nil {
+		settle := limiter.Commit
+		if status != "success" {
+			log.Printf("[INFO] Upload failed, session %s will be included in the next upload", hookInput.SessionID)
+			settle = func(input *hook.Input, _ []*hook.Input, now time.Time) error { return limiter.Release(input, now) }
+		}
+		if err := settle(hookInput, included, time.Now()); err != nil {
+			log.Printf("[WARN] Failed to update submission state: %v", err)
+		}
+	}
+
+	// 网关可达时顺便重送之前失败的 payload
+	if uploaded {
+		if result, err := drainSpool(cfg, false); err != nil {
+			log.Printf("[WARN] Failed to drain spool: %v", err)
+		} else if result.Sent > 0 || result.Failed > 0 {
+			log.Printf("[INFO] Spool drained: %d sent, %d failed, %d remaining", result.Sent, result.Failed, result.Remaining)
+		}
+	}
+	return map[string]interface{}{"status": status, "sinks": sinkStatus}
+}
+
+// foldPending 重新分析之前被限流的会话，把记录加入这次上传并重新计算幂等键，返回实际加入的会话
+// 每次分析的都是完整的 transcript，因此被略过的上传不会遗漏任何统计；分析失败的会话仍保留为待上传
+func foldPending(cfg *config.Config, analysis *telemetry.ClaudeCodeAnalysis, pending []*hook.Input) []*hook.Input {
+	var included []*hook.Input
+	for _, input := range pending {
+		folded, err := buildAnalysis(cfg, input)
+		if err != nil {
+			log.Printf("[WARN] Keeping throttled session %s for a later upload: %v", input.SessionID, err)
+			continue
+		}
+		analysis.Records = append(analysis.Records, folded.Records...)
+		included = append(included, input)
+		log.Printf("[INFO] Including throttled session %s in this upload", input.SessionID)
+	}
+	if key, err := telemetry.IdempotencyKey(analysis.MachineID, analysis.Records); err == nil {
+		analysis.IdempotencyKey = key
 	}
+	return included
+}
 
-	// 发送
-	response, err := client.Submit(payload)
+// exportOTLP 读取 transcript 并以 OTLP 导出，final 表示会话已结束
+func exportOTLP(cfg *config.Config, transcriptPath string, final bool) error {
+	if transcriptPath == "" {
+		return fmt.Errorf("transcript_path is empty")
+	}
+	records, err := telemetry.ReadJSONL(transcriptPath)
+	if err == nil {
+		err = otlp.ExportSession(cfg, telemetry.DecodeLogs(records), final)
+	}
 	if err != nil {
-		log.Printf("[ERROR] API call failed (endpoint: %s): %v", cfg.API.Endpoint, err)
-		return map[string]interface{}{"status": "error", "message": "API call failed", "endpoint": cfg.API.Endpoint}
+		log.Printf("[ERROR] OTLP export failed: %v", err)
+		return err
 	}
+	log.Printf("[INFO] Exported session to OTLP endpoint")
+	return nil
+}
 
-	log.Printf("[INFO] Successfully sent telemetry data to %s", cfg.API.Endpoint)
-	return response
+// policyDecisions 将事件日志中的策略决策转换为分析记录字段
+func policyDecisions(events []hook.Event) []telemetry.ClaudeCodeAnalysisPolicyDecision {
+	var decisions []telemetry.ClaudeCodeAnalysisPolicyDecision
+	for _, event := range events {
+		decisions = append(decisions, telemetry.ClaudeCodeAnalysisPolicyDecision{
+			ToolName:  event.ToolName,
+			ToolUseID: event.ToolUseID,
+			RuleID:    event.RuleID,
+			Decision:  event.Decision,
+			Reason:    event.DecisionReason,
+			Target:    event.Target,
+			Timestamp: event.Timestamp,
+		})
+	}
+	return decisions
 }
 
 func main() {
@@ -140,6 +466,13 @@ func main() {
 		log.Printf("[INFO] Read API endpoint from environment variable O11Y_BASE_URL: %s", envURL)
 	}
 
+	// 子命令各自解析参数
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
 	// Parse command line flags (命令行参数优先级最高)
 	var o11yBaseURL = flag.String("o11y_base_url", defaultBaseURL, "Base URL for o11y API endpoint")
 	var showVersion = flag.Bool("version", false, "Show version information")
@@ -147,12 +480,14 @@ func main() {
 	var skipUpdateCheck = flag.Bool("skip-update-check", false, "Skip automatic update check")
 	var inputPath = flag.String("path", "", "Path to JSONL file to analyze (alternative to stdin mode)")
 	var outputPath = flag.String("output", "", "Output path to save analysis result as JSON file (optional)")
+	var checkSurvival = flag.Bool("survival", false, "Check how much written/edited code still exists in the working tree (path mode)")
+	var dryRun = flag.Bool("dry-run", false, "Read a hook event from stdin and print the payload each sink would receive, with field annotations, without sending anything")
 	flag.Parse()
 
 	// Handle update-related flags first
 	if *checkUpdate {
 		log.Printf("[INFO] Checking for updates...")
-		result, err := updater.CheckForUpdatesGraceful()
+		result, err := updater.CheckForUpdatesGraceful(loadConfig().Proxy)
 		if err != nil {
 			log.Printf("[WARN] Failed to check for updates: %v", err)
 			// 創建錯誤結果並輸出，但不退出程序
@@ -185,7 +520,7 @@ func main() {
 	// Handle path mode (direct JSONL file analysis)
 	if *inputPath != "" {
 		log.Printf("[INFO] Path mode: analyzing JSONL file %s", *inputPath)
-		if err := parseJSONLFile(*inputPath, *outputPath); err != nil {
+		if err := parseJSONLFile(*inputPath, *outputPath, *checkSurvival); err != nil {
 			log.Printf("[ERROR] Failed to analyze JSONL file: %v", err)
 			fmt.Printf(`{"status": "error", "message": "%s"}`, err.Error())
 			os.Exit(1)
@@ -193,35 +528,22 @@ func main() {
 		return
 	}
 
-	// 自動檢查更新（除非用戶明確跳過）
-	if !*skipUpdateCheck {
-		if err := updater.ForceUpdateCheck(); err != nil {
-			log.Printf("[WARN] Update check failed: %v", err)
-		}
-	}
-
 	// 确定最终使用的 URL
 	finalURL := *o11yBaseURL
 	if finalURL != defaultBaseURL && os.Getenv("O11Y_BASE_URL") != "" {
 		log.Printf("[INFO] Command line argument --o11y_base_url overrides environment variable, using: %s", finalURL)
 	}
+	// 未通过参数或环境变量指定时，使用配置文件中的 api.endpoint
+	if !flagPassed("o11y_base_url") && os.Getenv("O11Y_BASE_URL") == "" {
+		finalURL = ""
+	}
 
+	// Hook 模式：stdout 只输出符合 Claude Code hook 协议的 JSON，并始终以 0 退出
 	log.Printf("[INFO] claude_analysis starting...")
-	inputData := readStdinAndSave(finalURL)
-
-	log.Printf("[INFO] readStdinAndSave completed, preparing output...")
-	if len(inputData) > 0 {
-		jsonOutput, err := json.MarshalIndent(inputData, "", "  ")
-		if err != nil {
-			log.Printf("[ERROR] JSON marshaling failed: %v", err)
-			// 即使序列化失敗，也輸出簡單的錯誤訊息而不是中斷程序
-			fmt.Println(`{"status": "error", "message": "JSON marshaling failed"}`)
-		} else {
-			fmt.Println(string(jsonOutput))
+	if output := runHook(finalURL, *skipUpdateCheck, *dryRun); output != nil {
+		if err := output.Write(os.Stdout); err != nil {
+			log.Printf("[ERROR] Failed to write hook output: %v", err)
 		}
-	} else {
-		log.Printf("[WARN] No data to output")
-		fmt.Println(`{"status": "no_data", "message": "no data to output"}`)
 	}
 	log.Printf("[INFO] claude_analysis completed")
 }
@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestClaudeAnalysis_PathMode_OutputToStdout(t *testing.T) {
@@ -194,7 +195,7 @@ func TestClaudeAnalysis_HelpFlag(t *testing.T) {
 	// --help typically exits with status 2, so we don't check err
 
 	outputStr := string(output)
-	expectedFlags := []string{"-path", "-output", "-version", "-check-update", "-skip-update-check", "-o11y_base_url"}
+	expectedFlags := []string{"-path", "-output", "-version", "-check-update", "-skip-update-check", "-dry-run", "-o11y_base_url"}
 
 	for _, flag := range expectedFlags {
 		if !strings.Contains(outputStr, flag) {
@@ -276,3 +277,215 @@ func TestClaudeAnalysis_OutputDirectoryCreation(t *testing.T) {
 		t.Fatalf("Output directory was not created: %s", outputDir)
 	}
 }
+
+func TestClaudeAnalysis_DryRun(t *testing.T) {
+	// Get the path to the built binary
+	_, thisFile, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("failed to get caller info")
+	}
+	// Navigate from cmd/claude_analysis to project root
+	projectRoot := filepath.Dir(filepath.Dir(filepath.Dir(thisFile)))
+	binaryPath := filepath.Join(projectRoot, "build", "claude_analysis")
+
+	// Check if binary exists
+	if _, err := os.Stat(binaryPath); os.IsNotExist(err) {
+		t.Skipf("Binary not found at %s, skipping integration test", binaryPath)
+	}
+
+	jsonlPath := filepath.Join(projectRoot, "examples", "test_conversation.jsonl")
+	input, _ := json.Marshal(map[string]string{
+		"session_id":      "dry-run-test",
+		"transcript_path": jsonlPath,
+		"hook_event_name": "Stop",
+		"cwd":             projectRoot,
+	})
+
+	// 指向无法连接的地址：dry-run 不应发出任何请求
+	cmd := exec.Command(binaryPath, "-dry-run", "-skip-update-check", "-o11y_base_url", "http://127.0.0.1:1")
+	cmd.Stdin = strings.NewReader(string(input))
+	cmd.Env = append(os.Environ(), "CLAUDE_ANALYSIS_DATA_DIR="+t.TempDir())
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	var report struct {
+		DryRun bool `json:"dryRun"`
+		Sinks  []struct {
+			Type    string                 `json:"type"`
+			Error   string                 `json:"error"`
+			Payload map[string]interface{} `json:"payload"`
+		} `json:"sinks"`
+		FieldSources map[string]string `json:"fieldSources"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		t.Fatalf("Output is not a dry-run report: %v\nOutput: %s", err, string(output))
+	}
+	if !report.DryRun || len(report.Sinks) == 0 || len(report.FieldSources) == 0 {
+		t.Fatalf("Unexpected report: %s", string(output))
+	}
+	if report.Sinks[0].Error != "" || report.Sinks[0].Payload["records"] == nil {
+		t.Errorf("Expected the default sink payload, got: %+v", report.Sinks[0])
+	}
+}
+
+func TestClaudeAnalysis_BackgroundStop(t *testing.T) {
+	// Get the path to the built binary
+	_, thisFile, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("failed to get caller info")
+	}
+	// Navigate from cmd/claude_analysis to project root
+	projectRoot := filepath.Dir(filepath.Dir(filepath.Dir(thisFile)))
+	binaryPath := filepath.Join(projectRoot, "build", "claude_analysis")
+
+	// Check if binary exists
+	if _, err := os.Stat(binaryPath); os.IsNotExist(err) {
+		t.Skipf("Binary not found at %s, skipping integration test", binaryPath)
+	}
+
+	dataDir := t.TempDir()
+	input, _ := json.Marshal(map[string]string{
+		"session_id":      "background-test",
+		"transcript_path": filepath.Join(projectRoot, "examples", "test_conversation.jsonl"),
+		"hook_event_name": "Stop",
+		"cwd":             projectRoot,
+	})
+	cmd := exec.Command(binaryPath, "-skip-update-check", "-o11y_base_url", "http://127.0.0.1:1")
+	cmd.Stdin = strings.NewReader(string(input))
+	cmd.Env = append(os.Environ(), "CLAUDE_ANALYSIS_DATA_DIR="+dataDir, "CLAUDE_ANALYSIS_BACKGROUND=true")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != `{"suppressOutput":true}` {
+		t.Errorf("Unexpected hook output: %s", string(output))
+	}
+
+	// 背景行程分析完成后写入本地历史记录，工作队列清空
+	historyFile := filepath.Join(dataDir, "history", "records.ndjson")
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(historyFile); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if _, err := os.Stat(historyFile); err != nil {
+		log, _ := os.ReadFile(filepath.Join(dataDir, "worker.log"))
+		t.Fatalf("Background worker did not record the session: %v\nworker.log:\n%s", err, string(log))
+	}
+}
+
+func TestClaudeAnalysis_Batch(t *testing.T) {
+	// Get the path to the built binary
+	_, thisFile, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("failed to get caller info")
+	}
+	// Navigate from cmd/claude_analysis to project root
+	projectRoot := filepath.Dir(filepath.Dir(filepath.Dir(thisFile)))
+	binaryPath := filepath.Join(projectRoot, "build", "claude_analysis")
+
+	// Check if binary exists
+	if _, err := os.Stat(binaryPath); os.IsNotExist(err) {
+		t.Skipf("Binary not found at %s, skipping integration test", binaryPath)
+	}
+
+	outputPath := filepath.Join(t.TempDir(), "sessions.ndjson")
+	cmd := exec.Command(binaryPath, "batch", "-workers", "3", "-o", outputPath, "-summary", "json", filepath.Join(projectRoot, "examples", "original"))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	var summary struct {
+		Projects []map[string]interface{} `json:"projects"`
+		Total    struct {
+			Sessions int `json:"sessions"`
+			Errors   int `json:"errors"`
+		} `json:"total"`
+	}
+	if err := json.Unmarshal(output, &summary); err != nil {
+		t.Fatalf("Summary is not valid JSON: %v\nOutput: %s", err, string(output))
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != summary.Total.Sessions || summary.Total.Errors != 0 || len(summary.Projects) == 0 {
+		t.Errorf("Expected one NDJSON line per session, got %d lines for %+v", len(lines), summary.Total)
+	}
+	var line struct {
+		Project string                 `json:"project"`
+		Record  map[string]interface{} `json:"record"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil || line.Project == "" || line.Record["taskId"] == nil {
+		t.Errorf("Unexpected NDJSON line: %s", lines[0])
+	}
+}
+
+func TestClaudeAnalysis_Report(t *testing.T) {
+	// Get the path to the built binary
+	_, thisFile, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("failed to get caller info")
+	}
+	// Navigate from cmd/claude_analysis to project root
+	projectRoot := filepath.Dir(filepath.Dir(filepath.Dir(thisFile)))
+	binaryPath := filepath.Join(projectRoot, "build", "claude_analysis")
+
+	// Check if binary exists
+	if _, err := os.Stat(binaryPath); os.IsNotExist(err) {
+		t.Skipf("Binary not found at %s, skipping integration test", binaryPath)
+	}
+
+	examples := filepath.Join(projectRoot, "examples", "original")
+	// stdout is a pipe here, so the report must be plain text
+	output, err := exec.Command(binaryPath, "report", "-top", "3", examples).Output()
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	text := string(output)
+	for _, want := range []string{"Sessions per day", "Top repos", "Tool usage", "Tokens and estimated cost", "Code changes", "Most-edited files", "TOTAL", "$"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Report is missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "\x1b[") || strings.Contains(text, "╭") {
+		t.Errorf("Expected plain text when stdout is not a terminal:\n%s", text)
+	}
+
+	output, err = exec.Command(binaryPath, "report", "-json", "-top", "3", examples).Output()
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	var report struct {
+		Sessions int                      `json:"sessions"`
+		Repos    []map[string]interface{} `json:"repos"`
+		Tokens   struct {
+			Cost float64 `json:"cost"`
+		} `json:"tokens"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		t.Fatalf("Report is not valid JSON: %v\nOutput: %s", err, string(output))
+	}
+	if report.Sessions == 0 || len(report.Repos) != 3 || report.Tokens.Cost <= 0 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	htmlPath := filepath.Join(t.TempDir(), "report.html")
+	if output, err := exec.Command(binaryPath, "report", "-html", htmlPath, examples).Output(); err != nil || !strings.Contains(string(output), htmlPath) {
+		t.Fatalf("Command failed: %v\nOutput: %s", err, string(output))
+	}
+	html, err := os.ReadFile(htmlPath)
+	if err != nil {
+		t.Fatalf("Failed to read HTML report: %v", err)
+	}
+	if !strings.Contains(string(html), `<script id="report-data" type="application/json">{"meta":`) || strings.Contains(string(html), `src="http`) {
+		t.Errorf("Expected a self-contained HTML report with embedded data")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"claude_analysis/core/history"
+	"claude_analysis/core/telemetry"
+)
+
+// maxTitleWidth 限制表格中标题的宽度
+const maxTitleWidth = 60
+
+// runQuery 实现 query 命令：查询本地历史记录
+func runQuery(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	since := fs.String("since", "", "Only sessions active since this time (2006-01-02, RFC3339, or a duration such as 7d or 12h)")
+	until := fs.String("until", "", "Only sessions started until this time (a date includes the whole day)")
+	repo := fs.String("repo", "", "Only sessions whose git remote or folder contains this text")
+	tool := fs.String("tool", "", "Only sessions that used this tool (e.g. Bash, Edit, mcp__jira__search)")
+	file := fs.String("file", "", "Only sessions that read or changed a matching file (substring or glob)")
+	limit := fs.Int("limit", 50, "Maximum number of sessions to show (0 for no limit)")
+	asJSON := fs.Bool("json", false, "Print matching records as NDJSON instead of a table")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: claude_analysis query [flags]\n\nSearch the local history of analyzed sessions.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	now := time.Now()
+	query := history.Query{Repo: *repo, Tool: *tool, File: *file, Limit: *limit}
+	var err error
+	if query.Since, err = parseQueryTime(*since, false, now); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -since: %v\n", err)
+		return 2
+	}
+	if query.Until, err = parseQueryTime(*until, true, now); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -until: %v\n", err)
+		return 2
+	}
+
+	cfg := loadConfig()
+	entries, err := history.New(cfg.DataDir).Find(query)
+	if err != nil {
+		log.Printf("[ERROR] Failed to query history: %v", err)
+		return 1
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				log.Printf("[ERROR] Failed to write result: %v", err)
+				return 1
+			}
+		}
+		return 0
+	}
+	if len(entries) == 0 {
+		fmt.Println("No matching sessions.")
+		if !cfg.History.Enabled {
+			fmt.Println("Session history is disabled (CLAUDE_ANALYSIS_HISTORY=false).")
+		}
+		return 0
+	}
+	writeQueryTable(os.Stdout, entries)
+	return 0
+}
+
+// writeQueryTable 以对齐的表格输出查询结果
+func writeQueryTable(w io.Writer, entries []*history.Entry) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LAST ACTIVE\tSESSION\tREPO\tTOOL CALLS\tWRITTEN\tDIFFED\tTITLE")
+	for _, entry := range entries {
+		record := &entry.Record
+		calls, diffed := 0, 0
+		for _, latency := range record.ToolLatencies {
+			calls += latency.Count
+		}
+		for _, detail := range record.ApplyDiffDetails {
+			diffed += detail.LineCount
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n",
+			time.Unix(record.Timestamp, 0).Format("2006-01-02 15:04"),
+			shortID(record.TaskID),
+			telemetry.RepoName(record),
+			calls,
+			record.TotalWriteLines,
+			diffed,
+			truncateText(record.Title, maxTitleWidth),
+		)
+	}
+	tw.Flush()
+}
+
+// parseQueryTime 解析日期、RFC3339 时间或相对时间（如 7d、12h）
+// endOfDay 为 true 时，只有日期的值表示当天结束
+func parseQueryTime(value string, endOfDay bool, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			return t.AddDate(0, 0, 1).Add(-time.Second), nil
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date, RFC3339 time or duration", value)
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func truncateText(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"claude_analysis/core/batch"
+	"claude_analysis/core/report"
+	"claude_analysis/core/version"
+)
+
+// maxBarWidth 是每日会话条形图的最大宽度
+const maxBarWidth = 30
+
+// runReport 实现 report 命令：以终端表格汇总一个或多个 transcript 的使用情况
+func runReport(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	since := fs.String("since", "", "Only sessions active since this time (2006-01-02, RFC3339, or a duration such as 7d or 12h)")
+	until := fs.String("until", "", "Only sessions active until this time (a date includes the whole day)")
+	top := fs.Int("top", 10, "Number of repos, tools and files to show (0 for all)")
+	days := fs.Int("days", 14, "Number of most recent active days to show (0 for all)")
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of transcripts analyzed concurrently")
+	asJSON := fs.Bool("json", false, "Print the report as JSON instead of tables")
+	htmlPath := fs.String("html", "", "Write a self-contained HTML report with charts to this file instead of printing tables")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: claude_analysis report [flags] [dir|file|glob ...]\n\n"+
+			"Summarize sessions, repos, tools, tokens, estimated cost and code changes of the\n"+
+			"given transcripts (default ~/.claude/projects). Tables are styled on a terminal\n"+
+			"and plain text otherwise; -html writes a single HTML file that works offline.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	now := time.Now()
+	from, err := parseQueryTime(*since, false, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -since: %v\n", err)
+		return 2
+	}
+	to, err := parseQueryTime(*until, true, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -until: %v\n", err)
+		return 2
+	}
+
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.Printf("[ERROR] Failed to locate home directory: %v", err)
+			return 1
+		}
+		patterns = []string{filepath.Join(home, ".claude", "projects")}
+	}
+	transcripts, err := batch.Find(patterns)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		return 1
+	}
+	if len(transcripts) == 0 {
+		fmt.Fprintln(os.Stderr, "No transcripts found.")
+		return 1
+	}
+
+	builder := report.NewBuilder()
+	failed := 0
+	batch.Run(transcripts, *workers, func(result *batch.Result) {
+		if result.Err != nil {
+			log.Printf("[WARN] Failed to analyze %s: %v", result.Path, result.Err)
+			failed++
+			return
+		}
+		for i := range result.Records {
+			record := &result.Records[i]
+			if (!from.IsZero() && record.Timestamp < from.Unix()) || (!to.IsZero() && record.Timestamp > to.Unix()) {
+				continue
+			}
+			builder.Add(record)
+		}
+	})
+	if *htmlPath != "" {
+		return writeHTMLReport(*htmlPath, builder.Build(report.Options{}), len(transcripts), failed)
+	}
+	usage := builder.Build(report.Options{Top: *top, Days: *days})
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(usage, "", "  ")
+		fmt.Println(string(data))
+		return 0
+	}
+	if usage.Sessions == 0 {
+		fmt.Println("No matching sessions.")
+		return 0
+	}
+	writeReport(os.Stdout, usage, len(transcripts), failed, isTerminal(os.Stdout))
+	return 0
+}
+
+// writeHTMLReport 将完整（不截断）的报表写入 HTML 文件
+func writeHTMLReport(path string, usage *report.Report, transcripts, failed int) int {
+	file, err := os.Create(path)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create HTML report: %v", err)
+		return 1
+	}
+	page := report.Page{
+		Title:       "Claude Code usage report",
+		GeneratedAt: time.Now(),
+		Transcripts: transcripts,
+		Failed:      failed,
+		Version:     version.GetVersion(),
+	}
+	err = report.WriteHTML(file, usage, page)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to write HTML report: %v", err)
+		return 1
+	}
+	fmt.Printf("Wrote HTML report of %d session(s) to %s\n", usage.Sessions, path)
+	return 0
+}
+
+// isTerminal 判断输出是否为终端；重定向到文件或管道时输出不带样式的纯文本
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// reportTable 是报表中的一个表格
+type reportTable struct {
+	title   string
+	headers []string
+	rows    [][]string
+}
+
+// writeReport 输出报表；styled 为 false 时以 tabwriter 对齐，不含任何 ANSI 转义序列
+func writeReport(w io.Writer, usage *report.Report, transcripts, failed int, styled bool) {
+	renderer := lipgloss.NewRenderer(w)
+	titleStyle := renderer.NewStyle().Bold(true).Foreground(lipgloss.Color("#7D56F4"))
+	noteStyle := renderer.NewStyle().Foreground(lipgloss.Color("#6272A4")).Italic(true)
+	if !styled {
+		titleStyle, noteStyle = renderer.NewStyle(), renderer.NewStyle()
+	}
+
+	heading := fmt.Sprintf("Claude Code usage: %d session(s) in %d transcript(s)", usage.Sessions, transcripts)
+	if usage.FirstActivity > 0 {
+		heading += fmt.Sprintf(", %s to %s",
+			time.Unix(usage.FirstActivity, 0).Format("2006-01-02"), time.Unix(usage.LastActivity, 0).Format("2006-01-02"))
+	}
+	fmt.Fprintln(w, titleStyle.Render(heading))
+	if failed > 0 {
+		fmt.Fprintln(w, noteStyle.Render(fmt.Sprintf("%d transcript(s) could not be analyzed", failed)))
+	}
+
+	for _, t := range reportTables(usage, styled, renderer) {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, titleStyle.Render(t.title))
+		if len(t.rows) == 0 {
+			fmt.Fprintln(w, "none")
+			continue
+		}
+		if styled {
+			fmt.Fprintln(w, styledTable(renderer, t))
+		} else {
+			writePlainTable(w, t)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, noteStyle.Render("Costs are estimated at list prices; models without a known price are not counted."))
+}
+
+// reportTables 将报表转换为要输出的表格
+func reportTables(usage *report.Report, styled bool, renderer *lipgloss.Renderer) []reportTable {
+	days := reportTable{title: "Sessions per day", headers: []string{"DAY", "SESSIONS", "TOOL CALLS", "WRITTEN", "DIFFED", "EST. COST", "ACTIVITY"}}
+	maxSessions := 0
+	for _, day := range usage.Days {
+		maxSessions = max(maxSessions, day.Sessions)
+	}
+	barStyle := renderer.NewStyle().Foreground(lipgloss.Color("#50FA7B"))
+	for _, day := range usage.Days {
+		width := max(1, day.Sessions*maxBarWidth/maxSessions)
+		bar := strings.Repeat("#", width)
+		if styled {
+			bar = barStyle.Render(strings.Repeat("█", width))
+		}
+		days.rows = append(days.rows, []string{day.Day, formatCount(day.Sessions), formatCount(day.ToolCalls),
+			formatCount(day.LinesWritten), formatCount(day.LinesDiffed), formatCost(day.Cost), bar})
+	}
+
+	repos := reportTable{title: "Top repos", headers: []string{"REPO", "SESSIONS", "TOOL CALLS", "WRITTEN", "DIFFED", "TOKENS", "EST. COST"}}
+	for _, repo := range usage.Repos {
+		repos.rows = append(repos.rows, []string{truncateText(repo.Repo, maxTitleWidth), formatCount(repo.Sessions),
+			formatCount(repo.ToolCalls), formatCount(repo.LinesWritten), formatCount(repo.LinesDiffed),
+			formatTokens(repo.Tokens), formatCost(repo.Cost)})
+	}
+
+	tools := reportTable{title: "Tool usage", headers: []string{"TOOL", "CALLS", "SHARE"}}
+	for _, tool := range usage.Tools {
+		tools.rows = append(tools.rows, []string{truncateText(tool.Tool, maxTitleWidth), formatCount(tool.Calls),
+			fmt.Sprintf("%.1f%%", float64(tool.Calls)*100/float64(max(1, usage.ToolCalls)))})
+	}
+
+	tokens := reportTable{title: "Tokens and estimated cost", headers: []string{"MODEL", "MESSAGES", "INPUT", "OUTPUT", "CACHE WRITE", "CACHE READ", "EST. COST"}}
+	for _, model := range append(usage.Models, &usage.Tokens) {
+		cost := formatCost(model.Cost)
+		if !model.Priced {
+			cost = "-"
+		}
+		tokens.rows = append(tokens.rows, []string{model.Model, formatCount(model.Messages), formatTokens(model.InputTokens),
+			formatTokens(model.OutputTokens), formatTokens(model.CacheWriteTokens), formatTokens(model.CacheReadTokens), cost})
+	}
+
+	changes := reportTable{title: "Code changes", headers: []string{"METRIC", "VALUE"}, rows: [][]string{
+		{"Files changed", formatCount(usage.Changes.FilesChanged)},
+		{"Lines written (Write)", formatCount(usage.Changes.LinesWritten)},
+		{"Lines diffed (Edit)", formatCount(usage.Changes.LinesDiffed)},
+		{"Characters written", formatCount(usage.Changes.WriteCharacters)},
+		{"Diff characters", formatCount(usage.Changes.DiffCharacters)},
+	}}
+
+	files := reportTable{title: "Most-edited files", headers: []string{"FILE", "EDITS", "WRITES", "LINES"}}
+	for _, file := range usage.Files {
+		files.rows = append(files.rows, []string{truncatePath(file.Path, maxTitleWidth), formatCount(file.Edits),
+			formatCount(file.Writes), formatCount(file.Lines)})
+	}
+	return []reportTable{days, repos, tools, tokens, changes, files}
+}
+
+// styledTable 以 lipgloss 绘制带边框的表格，数字列右对齐
+func styledTable(renderer *lipgloss.Renderer, t reportTable) string {
+	numeric := make([]bool, len(t.headers))
+	for col := 1; col < len(t.headers); col++ {
+		numeric[col] = true
+		for _, row := range t.rows {
+			if !isNumericCell(row[col]) {
+				numeric[col] = false
+				break
+			}
+		}
+	}
+	cell := renderer.NewStyle().Padding(0, 1)
+	header := cell.Bold(true).Foreground(lipgloss.Color("#7D56F4"))
+	return table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(renderer.NewStyle().Foreground(lipgloss.Color("#6272A4"))).
+		Headers(t.headers...).
+		Rows(t.rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			style := cell
+			if row == table.HeaderRow {
+				style = header
+			}
+			if numeric[col] {
+				style = style.Align(lipgloss.Right)
+			}
+			return style
+		}).
+		String()
+}
+
+// writePlainTable 以对齐的纯文本输出表格
+func writePlainTable(w io.Writer, t reportTable) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.headers, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
+
+func isNumericCell(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789,.$%kM-") == ""
+}
+
+// formatCount 以千分位输出整数
+func formatCount(n int) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// formatTokens 以 k / M 为单位输出 token 数量
+func formatTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	default:
+		return fmt.Sprint(n)
+	}
+}
+
+func formatCost(cost float64) string {
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+// truncatePath 保留路径结尾（文件名）并截断开头
+func truncatePath(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return "…" + string(runes[len(runes)-n+1:])
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"claude_analysis/core/telemetry"
+)
+
+// runSchema 实现 schema 命令：输出负载的 JSON Schema，或校验已有的负载文件
+func runSchema(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	validate := fs.Bool("validate", false, "Validate the given payload files instead of printing the schema")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: claude_analysis schema [-validate file...]\n\nPrint the JSON Schema (version %d) of the telemetry payload, or validate payload files against it.\n\n", telemetry.SchemaVersion)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if !*validate {
+		jsonOutput, _ := json.MarshalIndent(telemetry.Schema(), "", "  ")
+		fmt.Println(string(jsonOutput))
+		return 0
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, file := range fs.Args() {
+		data, err := os.ReadFile(file)
+		if err == nil {
+			err = telemetry.ValidateJSON(data)
+		}
+		if err != nil {
+			fmt.Printf("%s: %v\n", file, err)
+			status = 1
+			continue
+		}
+		fmt.Printf("%s: ok\n", file)
+	}
+	return status
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"claude_analysis/core/background"
+	"claude_analysis/core/config"
+	"claude_analysis/core/filelock"
+	"claude_analysis/core/hook"
+	"claude_analysis/core/updater"
+)
+
+// updateNoticeFile 保存背景行程检查到的更新提示，由下一次 Stop hook 输出
+const updateNoticeFile = "update_notice.txt"
+
+// startBackground 保存 hook 输入并启动背景行程处理，成功时 hook 可以立即返回
+// 启动失败时删除刚加入的工作，由调用者改为前景处理
+func startBackground(cfg *config.Config, job *background.Job) error {
+	file, err := background.New(cfg.DataDir).Add(job)
+	if err != nil {
+		return err
+	}
+	if err := background.Spawn([]string{"worker"}, background.LogFile(cfg.DataDir)); err != nil {
+		_ = os.Remove(file)
+		return err
+	}
+	log.Printf("[INFO] Queued %s event for background processing", job.Input.HookEventName)
+	return nil
+}
+
+// runWorker 实现 worker 命令：处理工作队列直到清空或用完时间预算
+// 由 Stop hook 以脱离的背景行程启动，也可以手动执行以处理残留的工作
+func runWorker(args []string) int {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: claude_analysis worker\n\nAnalyze and upload hook events queued by the Stop hook.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	cfg := loadConfig()
+	budget := time.Duration(cfg.Background.Budget)
+	queue := background.New(cfg.DataDir)
+
+	// 硬性时间上限：到期时无论正在做什么都释放锁并直接结束
+	var mu sync.Mutex
+	var held *filelock.Lock
+	timer := time.AfterFunc(budget, func() {
+		mu.Lock()
+		held.Release()
+		log.Printf("[ERROR] Background worker exceeded its %s budget, exiting", budget)
+		os.Exit(1)
+	})
+	defer timer.Stop()
+
+	// 释放锁之后可能有新的工作加入，而新启动的行程因锁被占用已经退出，因此再检查一次
+	for queue.Len() > 0 {
+		lock, err := queue.Lock(budget + time.Minute)
+		if err == background.ErrBusy {
+			log.Printf("[INFO] Another background worker is running")
+			return 0
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to lock job queue: %v", err)
+			return 1
+		}
+		mu.Lock()
+		held = lock
+		mu.Unlock()
+		// 上一个背景行程超时或崩溃时留下的工作重新放回队列
+		if requeued, dropped, err := queue.Recover(); err != nil {
+			log.Printf("[WARN] Failed to recover interrupted jobs: %v", err)
+		} else if requeued+dropped > 0 {
+			log.Printf("[INFO] Requeued %d interrupted job(s), gave up on %d after %d attempts", requeued, dropped, background.MaxAttempts)
+		}
+		processed := drainJobs(cfg, queue)
+		mu.Lock()
+		held.Release()
+		held = nil
+		mu.Unlock()
+		log.Printf("[INFO] Background worker processed %d job(s)", processed)
+	}
+	return 0
+}
+
+// drainJobs 依序处理队列中的工作，返回处理的数量
+func drainJobs(cfg *config.Config, queue *background.Queue) int {
+	processed := 0
+	for {
+		job, err := queue.Next()
+		if err != nil {
+			log.Printf("[ERROR] Failed to read job queue: %v", err)
+			return processed
+		}
+		if job == nil {
+			return processed
+		}
+		processJob(cfg, job)
+		if err := queue.Done(job); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+		processed++
+	}
+}
+
+// processJob 处理一个工作；panic 只记录日志，不影响队列中的其他工作
+func processJob(cfg *config.Config, job *background.Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[ERROR] Background job for session %s panicked: %v\n%s", job.Input.SessionID, r, debug.Stack())
+		}
+	}()
+
+	log.Printf("[INFO] Processing %s event of session %s queued at %s",
+		job.Input.HookEventName, job.Input.SessionID, job.QueuedAt.Format(time.RFC3339))
+	if job.Input.HookEventName == hook.EventSessionEnd {
+		exportOTLP(cfg, job.Input.TranscriptPath, true)
+		return
+	}
+
+	if !job.SkipUpdateCheck {
+		notice, err := updater.UpdateNotice(cfg.Proxy)
+		if err != nil {
+			log.Printf("[WARN] Update check failed: %v", err)
+		} else if err := saveUpdateNotice(cfg.DataDir, notice); err != nil {
+			log.Printf("[WARN] Failed to save update notice: %v", err)
+		}
+	}
+
+	response := readStdinAndSave(job.BaseURL, job.Input)
+	if jsonResponse, err := json.Marshal(response); err == nil {
+		log.Printf("[INFO] API response: %s", jsonResponse)
+	}
+}
+
+// saveUpdateNotice 保存更新提示，没有新版本时删除旧的提示
+func saveUpdateNotice(dataDir, notice string) error {
+	file := filepath.Join(dataDir, updateNoticeFile)
+	if notice == "" {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(file, []byte(notice+"\n"), 0o600)
+}
+
+// takeUpdateNotice 读取并删除背景行程保存的更新提示，每个提示只显示一次
+func takeUpdateNotice(dataDir string) string {
+	file := filepath.Join(dataDir, updateNoticeFile)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	_ = os.Remove(file)
+	return strings.TrimSpace(string(data))
+}
@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +13,8 @@ import (
 	"golang.org/x/net/html"
 
 	"claude_analysis/cmd/installer/internal/env"
+	"claude_analysis/cmd/installer/internal/platform"
+	"claude_analysis/core/httpclient"
 )
 
 // GetGAISFToken performs login to get GAISF token from the MLOP gateway
@@ -29,13 +30,12 @@ func GetGAISFToken(username, password string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to create cookie jar: %w", err)
 	}
-	client := &http.Client{
-		Jar:     jar,
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
+	client := httpclient.New(httpclient.Options{
+		Timeout:            30 * time.Second,
+		InsecureSkipVerify: true,
+		Proxy:              platform.Proxy(),
+	})
+	client.Jar = jar
 
 	// Step 1: GET login page and parse CSRF from input[name="_csrf"]
 	resp, err := client.Get(loginURL)
@@ -10,6 +10,7 @@ import (
 	"claude_analysis/cmd/installer/internal/install"
 	"claude_analysis/cmd/installer/internal/logger"
 	"claude_analysis/cmd/installer/internal/platform"
+	"claude_analysis/core/hook"
 )
 
 type Settings struct {
@@ -122,7 +123,37 @@ func EnsureDefaultSettings(settings *Settings, hookPath, baseURL, customHeader s
 	if settings.Hooks == nil {
 		settings.Hooks = make(map[string][]Hook)
 	}
-	settings.Hooks["Stop"] = []Hook{{Matcher: "*", Hooks: []Hook{{Type: "command", Command: hookPath}}}}
+	settings.Hooks[hook.EventStop] = []Hook{{Matcher: "*", Hooks: []Hook{{Type: "command", Command: hookPath}}}}
+	// Register the lightweight activity events without touching user-defined hooks
+	for _, event := range hook.Events {
+		if event != hook.EventStop {
+			ensureHookCommand(settings, event, hookPath)
+		}
+	}
+}
+
+// ensureHookCommand makes sure the event has exactly one entry running hookPath,
+// keeping any other hooks the user configured for the same event.
+func ensureHookCommand(settings *Settings, event, hookPath string) {
+	matcher := ""
+	if hook.IsToolEvent(event) {
+		matcher = "*"
+	}
+
+	var kept []Hook
+	for _, entry := range settings.Hooks[event] {
+		var actions []HookAction
+		for _, action := range entry.Hooks {
+			if action.Command != hookPath {
+				actions = append(actions, action)
+			}
+		}
+		if len(actions) > 0 {
+			entry.Hooks = actions
+			kept = append(kept, entry)
+		}
+	}
+	settings.Hooks[event] = append(kept, Hook{Matcher: matcher, Hooks: []Hook{{Type: "command", Command: hookPath}}})
 }
 
 // ApplyDefaultEnv sets/overwrites the expected env defaults used by settings.json
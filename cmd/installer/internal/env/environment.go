@@ -1,7 +1,6 @@
 package env
 
 import (
-	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +8,8 @@ import (
 	"time"
 
 	"claude_analysis/cmd/installer/internal/logger"
+	"claude_analysis/cmd/installer/internal/platform"
+	"claude_analysis/core/httpclient"
 )
 
 // EnvironmentConfig represents a domain environment and its associated endpoints
@@ -53,13 +54,12 @@ var selectedEnv *Environment
 
 // checkURLReachability performs an HTTP HEAD request to test if URL is reachable
 func checkURLReachability(url string, timeout time.Duration) error {
-	client := &http.Client{
-		Timeout: timeout,
-		Transport: &http.Transport{
-			DisableKeepAlives: true,
-			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
-		},
-	}
+	client := httpclient.New(httpclient.Options{
+		Timeout:            timeout,
+		InsecureSkipVerify: true,
+		DisableKeepAlives:  true,
+		Proxy:              platform.Proxy(),
+	})
 
 	// Single lightweight GET request. Many services don't support HEAD reliably.
 	req, _ := http.NewRequest(http.MethodGet, url, nil)
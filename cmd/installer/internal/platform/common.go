@@ -8,8 +8,11 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 
 	"claude_analysis/cmd/installer/internal/logger"
+	"claude_analysis/core/config"
+	"claude_analysis/core/httpclient"
 )
 
 // Platform utilities
@@ -39,10 +42,36 @@ func PlatformSuffix() string {
 	return osname + "-" + arch
 }
 
+var (
+	proxyOnce sync.Once
+	proxy     httpclient.Proxy
+)
+
+// Proxy returns the proxy used for the installer's own requests and npm: the same one
+// claude_analysis uses, i.e. the proxy section of its config.json over the CLAUDE_ANALYSIS_PROXY* variables
+func Proxy() httpclient.Proxy {
+	proxyOnce.Do(func() {
+		// Load falls back to the environment defaults when config.json is unreadable
+		cfg, err := config.Load()
+		if err != nil {
+			logger.Warning("⚠️ Failed to load claude_analysis config; using proxy environment variables", fmt.Sprintf("Error: %v", err))
+		}
+		proxy = cfg.Proxy
+	})
+	return proxy
+}
+
+// childEnv returns the environment for child processes: color disabled, and npm
+// pointed at the same proxy (including credentials) used for the installer's own requests
+func childEnv() []string {
+	env := append(os.Environ(), "NO_COLOR=1")
+	return append(env, Proxy().NpmEnv()...)
+}
+
 func RunLoggedCmd(name string, args ...string) error {
 	cmd := exec.Command(name, args...)
 	// Ensure color is disabled for child processes that honor NO_COLOR
-	cmd.Env = append(os.Environ(), "NO_COLOR=1")
+	cmd.Env = childEnv()
 
 	// Capture output instead of direct piping to avoid TUI interference
 	output, err := cmd.CombinedOutput()
@@ -68,7 +97,7 @@ func RunLoggedCmd(name string, args ...string) error {
 func RunLoggedShell(script string) error {
 	cmd := exec.Command("sh", "-lc", script)
 	// Ensure color is disabled for child processes that honor NO_COLOR
-	cmd.Env = append(os.Environ(), "NO_COLOR=1")
+	cmd.Env = childEnv()
 
 	// Capture output instead of direct piping to avoid TUI interference
 	output, err := cmd.CombinedOutput()
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package background
+
+import "syscall"
+
+// detachAttr 以新的 session 啟動，脫離 hook 的行程群組與控制終端
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
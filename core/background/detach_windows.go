@@ -0,0 +1,19 @@
+//go:build windows
+
+package background
+
+import "syscall"
+
+// Windows 行程建立旗標，syscall 套件未定義
+const (
+	detachedProcess = 0x00000008
+	createNoWindow  = 0x08000000
+)
+
+// detachAttr 以新的行程群組啟動且不附加主控台，hook 結束時不會被一併終止
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess | createNoWindow,
+		HideWindow:    true,
+	}
+}
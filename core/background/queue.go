@@ -0,0 +1,210 @@
+package background
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"claude_analysis/core/filelock"
+	"claude_analysis/core/hook"
+)
+
+const lockName = ".lock"
+
+// runningSuffix 標記已取出、正在處理的工作檔
+const runningSuffix = ".running"
+
+// MaxAttempts 是處理中斷的工作最多嘗試的次數，避免每次都導致崩潰或逾時的工作無限重試
+const MaxAttempts = 3
+
+// ErrBusy 表示另一個背景行程正在處理佇列
+var ErrBusy = filelock.ErrLocked
+
+// Job - hook 前景階段保存的輸入快照，由背景行程分析與上傳
+type Job struct {
+	Input           *hook.Input `json:"input"`
+	BaseURL         string      `json:"baseUrl,omitempty"` // 命令列或 O11Y_BASE_URL 指定的 API endpoint
+	SkipUpdateCheck bool        `json:"skipUpdateCheck,omitempty"`
+	QueuedAt        time.Time   `json:"queuedAt"`
+	Attempts        int         `json:"attempts,omitempty"` // 先前處理中斷的次數
+
+	path string // 處理中的工作檔
+}
+
+// Queue - 等待背景處理的工作，每個工作階段與事件一個檔案
+//
+// 同一工作階段在背景行程處理前再次觸發 Stop 時覆蓋舊的工作：
+// 每次分析的都是完整的 transcript，只需處理最新的一次
+type Queue struct {
+	Dir string
+}
+
+// Dir 返回工作佇列目錄
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "jobs")
+}
+
+// New 建立位於 dataDir/jobs 的佇列
+func New(dataDir string) *Queue {
+	return &Queue{Dir: Dir(dataDir)}
+}
+
+// Add 以暫存檔加 rename 寫入工作，返回工作檔路徑
+func (q *Queue) Add(job *Job) (string, error) {
+	if err := os.MkdirAll(q.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create job dir: %w", err)
+	}
+	data, err := json.Marshal(job)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal job: %w", err)
+	}
+	path := filepath.Join(q.Dir, jobName(job.Input))
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write job: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("failed to write job: %w", err)
+	}
+	return path, nil
+}
+
+// Next 取出最早加入的工作並改名為處理中，佇列為空時返回 nil；損壞的檔案直接刪除
+//
+// 處理完成後必須呼叫 Done 刪除工作檔；處理中途逾時或崩潰時工作檔會保留，由下一個背景行程的 Recover 放回佇列
+func (q *Queue) Next() (*Job, error) {
+	for {
+		files, err := q.files()
+		if err != nil || len(files) == 0 {
+			return nil, err
+		}
+		path := filepath.Join(q.Dir, files[0].Name())
+		running := path + runningSuffix
+		if err := os.Rename(path, running); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to take job: %w", err)
+		}
+		job, err := readJob(running)
+		if err != nil {
+			_ = os.Remove(running)
+			continue
+		}
+		return job, nil
+	}
+}
+
+// Done 刪除處理完成的工作
+func (q *Queue) Done(job *Job) error {
+	if err := os.Remove(job.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove job: %w", err)
+	}
+	return nil
+}
+
+// Recover 將先前處理中斷的工作放回佇列，返回放回與放棄的數量；呼叫者必須持有佇列的鎖
+//
+// 同一工作階段與事件已有較新的工作時，舊的工作直接刪除；已嘗試 MaxAttempts 次的工作也不再重試
+func (q *Queue) Recover() (requeued, dropped int, err error) {
+	matches, err := filepath.Glob(filepath.Join(q.Dir, "*.json"+runningSuffix))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read job dir: %w", err)
+	}
+	for _, running := range matches {
+		path := strings.TrimSuffix(running, runningSuffix)
+		job, readErr := readJob(running)
+		_, statErr := os.Stat(path)
+		switch {
+		case readErr != nil, statErr == nil:
+			_ = os.Remove(running)
+			continue
+		case job.Attempts+1 >= MaxAttempts:
+			_ = os.Remove(running)
+			dropped++
+			continue
+		}
+		job.Attempts++
+		data, err := json.Marshal(job)
+		if err != nil {
+			return requeued, dropped, fmt.Errorf("failed to marshal job: %w", err)
+		}
+		if err := os.WriteFile(running, data, 0o600); err != nil {
+			return requeued, dropped, fmt.Errorf("failed to write job: %w", err)
+		}
+		if err := os.Rename(running, path); err != nil {
+			return requeued, dropped, fmt.Errorf("failed to requeue job: %w", err)
+		}
+		requeued++
+	}
+	return requeued, dropped, nil
+}
+
+// Len 返回佇列中的工作數量，包括處理中斷、等待 Recover 的工作
+func (q *Queue) Len() int {
+	files, _ := q.files()
+	running, _ := filepath.Glob(filepath.Join(q.Dir, "*.json"+runningSuffix))
+	return len(files) + len(running)
+}
+
+// Lock 取得處理佇列的鎖；其他行程持有且未超過 staleAfter 時返回 ErrBusy
+func (q *Queue) Lock(staleAfter time.Duration) (*filelock.Lock, error) {
+	return filelock.TryLock(filepath.Join(q.Dir, lockName), staleAfter)
+}
+
+// files 返回依修改時間排序的工作檔，最舊的在前
+func (q *Queue) files() ([]os.FileInfo, error) {
+	dirEntries, err := os.ReadDir(q.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job dir: %w", err)
+	}
+	var files []os.FileInfo
+	for _, entry := range dirEntries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			files = append(files, info)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	return files, nil
+}
+
+// readJob 讀取工作檔，內容損壞或缺少 hook 輸入時返回錯誤
+func readJob(path string) (*Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	if job.Input == nil {
+		return nil, fmt.Errorf("job %s has no hook input", filepath.Base(path))
+	}
+	job.path = path
+	return &job, nil
+}
+
+// jobName 以工作階段與事件命名，同一工作階段的同一事件只保留最新的工作
+func jobName(input *hook.Input) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(input.SessionID)
+	if name == "" {
+		name = "unknown-session"
+	}
+	event := input.HookEventName
+	if event == "" {
+		event = hook.EventStop
+	}
+	return name + "-" + event + ".json"
+}
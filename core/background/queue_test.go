@@ -0,0 +1,158 @@
+package background
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claude_analysis/core/hook"
+)
+
+func TestQueue_AddAndNext(t *testing.T) {
+	q := New(t.TempDir())
+	if job, err := q.Next(); job != nil || err != nil {
+		t.Fatalf("empty queue returned %+v, %v", job, err)
+	}
+
+	first := &Job{Input: &hook.Input{SessionID: "a", HookEventName: hook.EventStop, TranscriptPath: "/old"}, QueuedAt: time.Now()}
+	if _, err := q.Add(first); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	// 讓修改時間可以區分先後
+	old := time.Now().Add(-time.Minute)
+	_ = os.Chtimes(filepath.Join(q.Dir, "a-Stop.json"), old, old)
+	if _, err := q.Add(&Job{Input: &hook.Input{SessionID: "b/../c", HookEventName: hook.EventSessionEnd}}); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	// 同一工作階段的同一事件覆蓋舊的工作
+	first.Input.TranscriptPath = "/new"
+	if _, err := q.Add(first); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	if q.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", q.Len())
+	}
+
+	job, err := q.Next()
+	if err != nil || job == nil || job.Input.SessionID != "b/../c" {
+		t.Fatalf("Next = %+v, %v, want the oldest job", job, err)
+	}
+	_ = q.Done(job)
+	job, _ = q.Next()
+	if job == nil || job.Input.TranscriptPath != "/new" {
+		t.Fatalf("Next = %+v, want the latest Stop job of session a", job)
+	}
+	// 處理中的工作在 Done 之前仍算在佇列中
+	if q.Len() != 1 {
+		t.Errorf("running job should still be counted, Len = %d", q.Len())
+	}
+	if next, _ := q.Next(); next != nil {
+		t.Errorf("running job should not be taken again: %+v", next)
+	}
+	_ = q.Done(job)
+	if q.Len() != 0 {
+		t.Errorf("jobs should be removed once done, %d left", q.Len())
+	}
+}
+
+func TestQueue_RecoverInterruptedJobs(t *testing.T) {
+	q := New(t.TempDir())
+	for _, session := range []string{"a", "b"} {
+		if _, err := q.Add(&Job{Input: &hook.Input{SessionID: session, HookEventName: hook.EventStop, TranscriptPath: "/old"}}); err != nil {
+			t.Fatalf("Add error: %v", err)
+		}
+	}
+	// 兩個工作都在處理中途中斷；b 在那之後又有新的 Stop
+	a, _ := q.Next()
+	b, _ := q.Next()
+	if a == nil || b == nil {
+		t.Fatalf("expected two jobs, got %+v / %+v", a, b)
+	}
+	if _, err := q.Add(&Job{Input: &hook.Input{SessionID: "b", HookEventName: hook.EventStop, TranscriptPath: "/new"}}); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+
+	requeued, dropped, err := q.Recover()
+	if err != nil || requeued != 1 || dropped != 0 {
+		t.Fatalf("Recover = %d, %d, %v", requeued, dropped, err)
+	}
+	jobs := map[string]*Job{}
+	for job, _ := q.Next(); job != nil; job, _ = q.Next() {
+		jobs[job.Input.SessionID] = job
+	}
+	if jobs["a"] == nil || jobs["a"].Attempts != 1 || jobs["b"] == nil || jobs["b"].Input.TranscriptPath != "/new" || jobs["b"].Attempts != 0 {
+		t.Fatalf("unexpected jobs after Recover: %+v", jobs)
+	}
+
+	// 每次都中斷的工作在 MaxAttempts 次後放棄
+	_ = q.Done(jobs["b"])
+	if requeued, _, _ := q.Recover(); requeued != 1 {
+		t.Fatalf("second interruption should be retried, requeued %d", requeued)
+	}
+	if job, _ := q.Next(); job == nil || job.Attempts != MaxAttempts-1 {
+		t.Fatalf("Next = %+v, want the last attempt of a", job)
+	}
+	if requeued, dropped, _ := q.Recover(); requeued != 0 || dropped != 1 {
+		t.Errorf("Recover = %d, %d, want a to be dropped", requeued, dropped)
+	}
+	if q.Len() != 0 {
+		t.Errorf("job should be dropped after %d attempts, %d left", MaxAttempts, q.Len())
+	}
+}
+
+func TestQueue_SkipsCorruptJobs(t *testing.T) {
+	q := New(t.TempDir())
+	if err := os.MkdirAll(q.Dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.WriteFile(filepath.Join(q.Dir, "broken.json"), []byte("{"), 0o600)
+	old := time.Now().Add(-time.Minute)
+	_ = os.Chtimes(filepath.Join(q.Dir, "broken.json"), old, old)
+	if _, err := q.Add(&Job{Input: &hook.Input{SessionID: "ok"}}); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+
+	job, err := q.Next()
+	if err != nil || job == nil || job.Input.SessionID != "ok" {
+		t.Fatalf("Next = %+v, %v", job, err)
+	}
+	_ = q.Done(job)
+	if q.Len() != 0 {
+		t.Errorf("corrupt job should be removed")
+	}
+}
+
+func TestQueue_Lock(t *testing.T) {
+	q := New(t.TempDir())
+	lock, err := q.Lock(time.Minute)
+	if err != nil {
+		t.Fatalf("Lock error: %v", err)
+	}
+	if _, err := q.Lock(time.Minute); err != ErrBusy {
+		t.Errorf("expected ErrBusy, got %v", err)
+	}
+	if q.Len() != 0 {
+		t.Errorf("lock file should not count as a job")
+	}
+	lock.Release()
+	if lock, err := q.Lock(time.Minute); err != nil {
+		t.Errorf("Lock after release: %v", err)
+	} else {
+		lock.Release()
+	}
+}
+
+func TestOpenLog_TruncatesLargeLog(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "worker.log")
+	_ = os.WriteFile(file, make([]byte, maxLogBytes+1), 0o600)
+	f, err := openLog(file)
+	if err != nil {
+		t.Fatalf("openLog error: %v", err)
+	}
+	f.WriteString("line\n")
+	f.Close()
+	if data, _ := os.ReadFile(file); string(data) != "line\n" {
+		t.Errorf("large log should be truncated, got %d bytes", len(data))
+	}
+}
@@ -0,0 +1,56 @@
+package background
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// maxLogBytes 背景行程日誌超過此大小時在下一次啟動時清空
+const maxLogBytes = 1 << 20
+
+// LogFile 返回背景行程的日誌檔路徑
+func LogFile(dataDir string) string {
+	return filepath.Join(dataDir, "worker.log")
+}
+
+// Spawn 以目前的執行檔與 args 啟動脫離目前行程的背景行程，不等待其結束
+//
+// 背景行程沒有 stdin/stdout，stderr（日誌）寫入 logFile；
+// 它不屬於 hook 的行程群組，Claude Code 結束 hook 時不會一併被終止
+func Spawn(args []string, logFile string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	logOutput, err := openLog(logFile)
+	if err != nil {
+		return err
+	}
+	defer logOutput.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stderr = logOutput
+	cmd.SysProcAttr = detachAttr()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start background worker: %w", err)
+	}
+	return cmd.Process.Release()
+}
+
+// openLog 以附加模式開啟日誌檔，過大時先清空
+func openLog(logFile string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(logFile), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log dir: %w", err)
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if info, err := os.Stat(logFile); err == nil && info.Size() > maxLogBytes {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(logFile, flags, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worker log: %w", err)
+	}
+	return f, nil
+}
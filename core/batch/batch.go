@@ -0,0 +1,151 @@
+package batch
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"claude_analysis/core/telemetry"
+)
+
+// Transcript - 一個要分析的 transcript 檔案
+type Transcript struct {
+	Path    string
+	Project string // 專案目錄名稱：搜尋根目錄下的第一層目錄（~/.claude/projects 中即為編碼後的工作目錄）
+}
+
+// Result - 一個 transcript 的分析結果
+type Result struct {
+	Transcript
+	Records []telemetry.ClaudeCodeAnalysisRecord
+	Err     error
+}
+
+// Find 依 patterns 找出所有 transcript（*.jsonl），依路徑排序並去除重複
+//
+// pattern 可以是目錄（遞迴搜尋）、檔案或 glob；目錄下第一層的子目錄視為專案，
+// 檔案與 glob 的結果以所在目錄為專案
+func Find(patterns []string) ([]Transcript, error) {
+	seen := make(map[string]bool)
+	var transcripts []Transcript
+	add := func(path, project string) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if !seen[path] {
+			seen[path] = true
+			transcripts = append(transcripts, Transcript{Path: path, Project: project})
+		}
+	}
+
+	for _, pattern := range patterns {
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(match, filepath.Base(filepath.Dir(match)))
+				continue
+			}
+			root := match
+			err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if entry.IsDir() || filepath.Ext(path) != ".jsonl" {
+					return nil
+				}
+				add(path, projectName(root, path))
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+			}
+		}
+	}
+	sort.Slice(transcripts, func(i, j int) bool { return transcripts[i].Path < transcripts[j].Path })
+	return transcripts, nil
+}
+
+// projectName 返回 path 在 root 下的第一層目錄名稱，直接位於 root 的檔案以 root 為專案
+func projectName(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.Base(filepath.Dir(path))
+	}
+	if first, _, ok := strings.Cut(filepath.ToSlash(rel), "/"); ok {
+		return first
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return filepath.Base(root)
+}
+
+// Run 以最多 workers 個 goroutine 分析 transcripts，每完成一個就呼叫 emit
+//
+// emit 只在呼叫 Run 的 goroutine 中依完成順序呼叫，不需要自行加鎖；
+// 標題所需的 summary 記錄由 telemetry 依檔案修改時間快取，同一檔案只解析一次
+func Run(transcripts []Transcript, workers int, emit func(*Result)) {
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan Transcript)
+	results := make(chan *Result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < min(workers, len(transcripts)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for transcript := range jobs {
+				results <- analyze(transcript)
+			}
+		}()
+	}
+	go func() {
+		for _, transcript := range transcripts {
+			jobs <- transcript
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+	for result := range results {
+		emit(result)
+	}
+}
+
+// analyze 分析一個 transcript；panic 視為該檔案的錯誤，不影響其他檔案
+func analyze(transcript Transcript) (result *Result) {
+	result = &Result{Transcript: transcript}
+	defer func() {
+		if r := recover(); r != nil {
+			result.Records = nil
+			result.Err = fmt.Errorf("panic while analyzing: %v", r)
+		}
+	}()
+
+	records, err := telemetry.ReadJSONL(transcript.Path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	records = append(records, telemetry.ReadSessionSummaries(transcript.Path, records)...)
+	analysis := telemetry.AnalyzeConversations(records)
+	telemetry.NormalizePaths(&analysis)
+	result.Records = analysis.Records
+	return result
+}
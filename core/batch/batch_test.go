@@ -0,0 +1,110 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func examplesDir(t *testing.T) string {
+	_, thisFile, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("failed to get caller info")
+	}
+	return filepath.Join(filepath.Dir(filepath.Dir(filepath.Dir(thisFile))), "examples", "original")
+}
+
+func TestFind(t *testing.T) {
+	root := examplesDir(t)
+	transcripts, err := Find([]string{root})
+	if err != nil {
+		t.Fatalf("Find error: %v", err)
+	}
+	entries, _ := os.ReadDir(root)
+	projects := make(map[string]int)
+	for _, transcript := range transcripts {
+		projects[transcript.Project]++
+		if filepath.Base(filepath.Dir(transcript.Path)) != transcript.Project {
+			t.Errorf("%s grouped under %s", transcript.Path, transcript.Project)
+		}
+	}
+	if len(projects) != len(entries) || len(transcripts) < len(entries) {
+		t.Errorf("found %d transcripts in %d projects, want one project per directory (%d)", len(transcripts), len(projects), len(entries))
+	}
+
+	// glob 與目錄重疊時不重複
+	again, err := Find([]string{root, filepath.Join(root, "*", "*.jsonl")})
+	if err != nil || len(again) != len(transcripts) {
+		t.Errorf("overlapping patterns found %d transcripts, want %d (%v)", len(again), len(transcripts), err)
+	}
+
+	if _, err := Find([]string{filepath.Join(root, "missing")}); err == nil {
+		t.Errorf("expected an error for a missing directory")
+	}
+}
+
+func TestFind_NestedAndLooseFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"loose.jsonl", "proj/a.jsonl", "proj/sub/agent.jsonl", "proj/notes.txt"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte("{}\n"), 0o600)
+	}
+	transcripts, err := Find([]string{root})
+	if err != nil || len(transcripts) != 3 {
+		t.Fatalf("Find = %+v, %v", transcripts, err)
+	}
+	for _, transcript := range transcripts {
+		want := "proj"
+		if filepath.Base(transcript.Path) == "loose.jsonl" {
+			want = filepath.Base(root)
+		}
+		if transcript.Project != want {
+			t.Errorf("%s: project %q, want %q", transcript.Path, transcript.Project, want)
+		}
+	}
+}
+
+func TestRun_Summary(t *testing.T) {
+	transcripts, err := Find([]string{examplesDir(t)})
+	if err != nil {
+		t.Fatalf("Find error: %v", err)
+	}
+	// 無法讀取的檔案計為錯誤，不影響其他檔案
+	transcripts = append(transcripts, Transcript{Path: filepath.Join(t.TempDir(), "missing.jsonl"), Project: "bad"})
+
+	summarizer := NewSummarizer()
+	seen := make(map[string]bool)
+	sessions, writeLines := 0, 0
+	Run(transcripts, 4, func(result *Result) {
+		if seen[result.Path] {
+			t.Errorf("%s reported twice", result.Path)
+		}
+		seen[result.Path] = true
+		summarizer.Add(result)
+		for _, record := range result.Records {
+			sessions++
+			writeLines += record.TotalWriteLines
+		}
+	})
+	if len(seen) != len(transcripts) {
+		t.Fatalf("got %d results for %d transcripts", len(seen), len(transcripts))
+	}
+
+	summary := summarizer.Summary()
+	total := summary.Total
+	if total.Transcripts != len(transcripts) || total.Sessions != sessions || total.WriteLines != writeLines || total.Errors != 1 {
+		t.Errorf("unexpected total: %+v (sessions %d, lines %d)", total, sessions, writeLines)
+	}
+	sum := 0
+	for i, project := range summary.Projects {
+		sum += project.Sessions
+		if i > 0 && summary.Projects[i-1].Project >= project.Project {
+			t.Errorf("projects not sorted")
+		}
+	}
+	if sum != total.Sessions || total.LastActivity < total.FirstActivity || total.InputTokens == 0 {
+		t.Errorf("project sums do not match total: %+v", total)
+	}
+}
@@ -0,0 +1,109 @@
+package batch
+
+import (
+	"sort"
+
+	"claude_analysis/core/telemetry"
+)
+
+// ProjectSummary - 一個專案目錄的彙總
+type ProjectSummary struct {
+	Project        string `json:"project"`
+	Folder         string `json:"folder,omitempty"` // 最近一個工作階段的工作目錄
+	Transcripts    int    `json:"transcripts"`
+	Sessions       int    `json:"sessions"`
+	Errors         int    `json:"errors"`
+	ToolCalls      int    `json:"toolCalls"`
+	WriteLines     int    `json:"writeLines"`
+	DiffCharacters int    `json:"diffCharacters"`
+	InputTokens    int    `json:"inputTokens"` // 含快取讀取與建立的 input token
+	OutputTokens   int    `json:"outputTokens"`
+	FirstActivity  int64  `json:"firstActivity,omitempty"` // Unix 秒
+	LastActivity   int64  `json:"lastActivity,omitempty"`  // Unix 秒
+}
+
+// Summary - 依專案分組的彙總，Projects 依名稱排序
+type Summary struct {
+	Projects []*ProjectSummary `json:"projects"`
+	Total    ProjectSummary    `json:"total"`
+}
+
+// Summarizer 累加分析結果
+type Summarizer struct {
+	projects map[string]*ProjectSummary
+}
+
+// NewSummarizer 建立空的彙總
+func NewSummarizer() *Summarizer {
+	return &Summarizer{projects: make(map[string]*ProjectSummary)}
+}
+
+// Add 將一個 transcript 的結果加入所屬專案
+func (s *Summarizer) Add(result *Result) {
+	project, ok := s.projects[result.Project]
+	if !ok {
+		project = &ProjectSummary{Project: result.Project}
+		s.projects[result.Project] = project
+	}
+	project.Transcripts++
+	if result.Err != nil {
+		project.Errors++
+		return
+	}
+	for i := range result.Records {
+		addRecord(project, &result.Records[i])
+	}
+}
+
+// Summary 返回目前的彙總與總計
+func (s *Summarizer) Summary() *Summary {
+	summary := &Summary{Projects: make([]*ProjectSummary, 0, len(s.projects))}
+	for _, project := range s.projects {
+		summary.Projects = append(summary.Projects, project)
+		merge(&summary.Total, project)
+	}
+	sort.Slice(summary.Projects, func(i, j int) bool { return summary.Projects[i].Project < summary.Projects[j].Project })
+	summary.Total.Project = "TOTAL"
+	return summary
+}
+
+func addRecord(project *ProjectSummary, record *telemetry.ClaudeCodeAnalysisRecord) {
+	project.Sessions++
+	// 與 query 命令相同，以耗時統計計算工具呼叫次數（包含 MCP 等所有工具）
+	for _, latency := range record.ToolLatencies {
+		project.ToolCalls += latency.Count
+	}
+	project.WriteLines += record.TotalWriteLines
+	project.DiffCharacters += record.TotalDiffCharacters
+	for _, usage := range record.TokenUsage {
+		project.InputTokens += usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
+		project.OutputTokens += usage.OutputTokens
+	}
+	if record.Timestamp > project.LastActivity {
+		project.LastActivity = record.Timestamp
+		if record.FolderPath != "" {
+			project.Folder = record.FolderPath
+		}
+	}
+	if record.Timestamp > 0 && (project.FirstActivity == 0 || record.Timestamp < project.FirstActivity) {
+		project.FirstActivity = record.Timestamp
+	}
+}
+
+// merge 將 from 的數值累加到 into
+func merge(into, from *ProjectSummary) {
+	into.Transcripts += from.Transcripts
+	into.Sessions += from.Sessions
+	into.Errors += from.Errors
+	into.ToolCalls += from.ToolCalls
+	into.WriteLines += from.WriteLines
+	into.DiffCharacters += from.DiffCharacters
+	into.InputTokens += from.InputTokens
+	into.OutputTokens += from.OutputTokens
+	if from.LastActivity > into.LastActivity {
+		into.LastActivity = from.LastActivity
+	}
+	if from.FirstActivity > 0 && (into.FirstActivity == 0 || from.FirstActivity < into.FirstActivity) {
+		into.FirstActivity = from.FirstActivity
+	}
+}
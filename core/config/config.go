@@ -1,11 +1,17 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"claude_analysis/core/httpclient"
 	"claude_analysis/core/version"
 
 	"github.com/denisbrodbeck/machineid"
@@ -13,19 +19,205 @@ import (
 
 // Config holds the application configuration
 type Config struct {
-	API             APIConfig `json:"api"`
-	UserName        string    `json:"user_name"`
-	ExtensionName   string    `json:"extension_name"`
-	MachineID       string    `json:"machine_id"`
-	InsightsVersion string    `json:"insights_version"`
+	API             APIConfig        `json:"api"`
+	Analysis        AnalysisConfig   `json:"analysis"`
+	Policy          PolicyConfig     `json:"policy"`
+	OTLP            OTLPConfig       `json:"otlp"`
+	History         HistoryConfig    `json:"history"`
+	Background      BackgroundConfig `json:"background"`
+	Submission      SubmissionConfig `json:"submission"`
+	Proxy           httpclient.Proxy `json:"proxy"`    // 所有對外請求使用的代理，未設定時依 HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	Sinks           []SinkConfig     `json:"sinks"`    // 分析結果的輸出目的地，未設定時只上傳到 API.Endpoint
+	DryRun          bool             `json:"dry_run"`  // 只產生負載並寫入 DataDir/dry-run，不做任何網路請求
+	DataDir         string           `json:"data_dir"` // 本地狀態目錄（事件日誌等），預設為 ~/.claude/claude_analysis
+	UserName        string           `json:"user_name"`
+	ExtensionName   string           `json:"extension_name"`
+	MachineID       string           `json:"machine_id"`
+	InsightsVersion string           `json:"insights_version"`
 }
 
 // APIConfig holds API-related configuration
 type APIConfig struct {
-	Endpoint        string        `json:"endpoint"`
-	Timeout         time.Duration `json:"timeout"`
-	SkipSSLVerify   bool          `json:"skip_ssl_verify"`
-	InsecureSkipTLS bool          `json:"insecure_skip_tls"` // Alias for SkipSSLVerify
+	Endpoint        string     `json:"endpoint"`
+	Timeout         Duration   `json:"timeout"`
+	SkipSSLVerify   bool       `json:"skip_ssl_verify"`
+	InsecureSkipTLS bool       `json:"insecure_skip_tls"` // Alias for SkipSSLVerify
+	MaxRetries      int        `json:"max_retries"`       // 429/5xx 時的重試次數
+	RetryBaseDelay  Duration   `json:"retry_base_delay"`  // 第一次重試前的等待時間，之後每次加倍
+	MaxRetryAfter   Duration   `json:"max_retry_after"`   // Retry-After 超過此值時不再等待，交由呼叫者處理
+	Compression     string     `json:"compression"`       // 請求壓縮方式："gzip" 或空字串（不壓縮）
+	MaxPayloadBytes int        `json:"max_payload_bytes"` // 單次請求的上限（未壓縮），超過時拆分成多個部分；0 表示不拆分
+	Auth            AuthConfig `json:"auth"`
+}
+
+// 上傳的認證方式
+const (
+	AuthNone   = "none"
+	AuthGAISF  = "gaisf"  // 重用 settings.json 中 ANTHROPIC_CUSTOM_HEADERS 的 api-key
+	AuthBearer = "bearer" // 靜態 bearer token
+	AuthMTLS   = "mtls"   // 客戶端憑證
+)
+
+// AuthConfig holds how telemetry uploads authenticate to the collector
+type AuthConfig struct {
+	Mode         string `json:"mode"`
+	Token        string `json:"token"`         // bearer 模式使用
+	CertFile     string `json:"cert_file"`     // mtls 模式使用
+	KeyFile      string `json:"key_file"`      // mtls 模式使用
+	SettingsFile string `json:"settings_file"` // gaisf 模式讀取的 Claude Code settings.json
+}
+
+// AnalysisConfig holds optional analysis steps
+type AnalysisConfig struct {
+	// CodeSurvival 在分析后比对工作区，统计 AI 写入的代码仍保留了多少
+	CodeSurvival bool `json:"code_survival"`
+}
+
+// PolicyConfig holds the PreToolUse guardrail configuration
+type PolicyConfig struct {
+	// File 是宣告式規則檔路徑，檔案不存在時不做任何攔截
+	File string `json:"file"`
+}
+
+// HistoryConfig holds the local session history store
+type HistoryConfig struct {
+	// Enabled 時每次分析都保存到 DataDir/history，供 query 命令查詢
+	Enabled bool `json:"enabled"`
+}
+
+// BackgroundConfig holds the detached upload worker
+type BackgroundConfig struct {
+	// Enabled 時 Stop hook 只保存輸入並啟動背景行程分析與上傳，立即返回
+	Enabled bool `json:"enabled"`
+	// Budget 是背景行程的執行時間上限，逾時即結束，未處理的工作留待下一次
+	// 低於 MinBackgroundBudget 時使用預設值
+	Budget Duration `json:"budget"`
+}
+
+// 背景行程的執行時間上限
+const (
+	DefaultBackgroundBudget = 120 * time.Second
+	MinBackgroundBudget     = time.Second // 更短的上限會讓背景行程一啟動就結束
+)
+
+// Duration 是設定檔中的時間長度：數字為秒數（與對應的環境變數相同），字串為 Go duration（"2m"、"500ms"）
+type Duration time.Duration
+
+// UnmarshalJSON 以秒數或 duration 字串解析時間長度
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be seconds or a duration string: %s", data)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", text, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON 輸出 duration 字串，可以原樣讀回
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// backgroundBudget 把過短的上限換成預設值
+func backgroundBudget(budget Duration) Duration {
+	if time.Duration(budget) < MinBackgroundBudget {
+		return Duration(DefaultBackgroundBudget)
+	}
+	return budget
+}
+
+// SubmissionConfig holds the upload sampling and rate limits
+// 被限流的工作階段會在下一次允許的上傳中一併送出，統計值不會遺漏
+type SubmissionConfig struct {
+	MinInterval      Duration `json:"min_interval"`       // 同一工作階段兩次上傳的最小間隔，0 表示每次 Stop 都上傳
+	MaxPerDay        int      `json:"max_per_day"`        // 每天（本地時間）最多上傳次數，0 表示不限制
+	DetailSampleRate float64  `json:"detail_sample_rate"` // 明細列表保留的比例 (0, 1]，統計值仍以完整 transcript 計算
+}
+
+// OTLPConfig holds the OpenTelemetry OTLP/HTTP exporter configuration
+// endpoint 等預設值來自標準的 OTEL_EXPORTER_OTLP_* 環境變數；Claude Code 本身的遙測也使用這些變數，
+// hook 會繼承它們，因此必須另外以 Enabled 明確啟用，避免與 Claude Code 的原生指標重複
+type OTLPConfig struct {
+	Enabled            bool              `json:"enabled"`
+	Endpoint           string            `json:"endpoint"`         // 基礎 URL，traces/metrics 分別附加 /v1/traces、/v1/metrics
+	TracesEndpoint     string            `json:"traces_endpoint"`  // 完整 URL，優先於 Endpoint
+	MetricsEndpoint    string            `json:"metrics_endpoint"` // 完整 URL，優先於 Endpoint
+	Protocol           string            `json:"protocol"`         // 只支援 http/json
+	Headers            map[string]string `json:"headers"`
+	Compression        string            `json:"compression"` // "gzip" 或空字串（不壓縮）
+	Timeout            Duration          `json:"timeout"`     // 環境變數 OTEL_EXPORTER_OTLP_TIMEOUT 以毫秒為單位，設定檔中與其他時間長度一樣是秒數或 duration 字串
+	ServiceName        string            `json:"service_name"`
+	ResourceAttributes map[string]string `json:"resource_attributes"`
+	Redaction          string            `json:"redaction"` // span 屬性的脫敏等級，預設 content（移除命令參數、URL 路徑與搜尋模式）
+}
+
+// 遙測輸出目的地類型
+const (
+	SinkHTTP       = "http"
+	SinkFile       = "file"
+	SinkStdout     = "stdout"
+	SinkWebhook    = "webhook"
+	SinkPrometheus = "prometheus"
+)
+
+// 輸出前的脫敏等級
+const (
+	RedactNone    = "none"    // 原樣輸出
+	RedactContent = "content" // 移除檔案內容、diff、命令、查詢與策略原因，保留統計
+	RedactStrict  = "strict"  // 再將路徑、網域、標題與 URL 雜湊或移除
+)
+
+// SinkConfig holds one telemetry output destination
+type SinkConfig struct {
+	Type      string            `json:"type"`
+	Name      string            `json:"name"`
+	Endpoint  string            `json:"endpoint"` // http：覆蓋 API endpoint；webhook：目標 URL；prometheus：Pushgateway URL
+	Path      string            `json:"path"`     // file：NDJSON 檔案，或每天一個檔案的目錄；prometheus：textfile collector 目錄
+	Template  string            `json:"template"` // webhook：text/template 格式的請求內容
+	Headers   map[string]string `json:"headers"`  // webhook：額外的 header
+	Job       string            `json:"job"`      // prometheus：Pushgateway 的 job 名稱，預設為 claude_analysis
+	Redaction string            `json:"redaction"`
+	Filter    SinkFilter        `json:"filter"`
+}
+
+// SinkFilter selects which session records a sink receives
+type SinkFilter struct {
+	Include []string `json:"include"` // 比對 folderPath 或 gitRemoteUrl 的 glob（* 可跨越 /）或目錄前綴，空表示全部
+	Exclude []string `json:"exclude"`
+}
+
+// Load returns the default configuration overlaid with the optional JSON config file
+// (CLAUDE_ANALYSIS_CONFIG, or config.json in the data directory)
+func Load() (*Config, error) {
+	cfg := Default()
+	file := os.Getenv("CLAUDE_ANALYSIS_CONFIG")
+	if file == "" {
+		file = filepath.Join(cfg.DataDir, "config.json")
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return Default(), fmt.Errorf("failed to parse config file %s: %w", file, err)
+	}
+	cfg.Background.Budget = backgroundBudget(cfg.Background.Budget)
+	return cfg, nil
 }
 
 // Default returns the default configuration
@@ -49,13 +241,61 @@ func Default() *Config {
 			getEnvBool("TLS_INSECURE", false)
 	}
 
+	dataDir := defaultDataDir()
+
 	return &Config{
 		API: APIConfig{
 			Endpoint:        "https://gaia.mediatek.inc/o11y/upload_locs",
-			Timeout:         10 * time.Second,
+			Timeout:         Duration(10 * time.Second),
 			SkipSSLVerify:   skipSSL,
 			InsecureSkipTLS: skipSSL, // 保持兩個值同步
+			MaxRetries:      2,
+			RetryBaseDelay:  Duration(time.Second),
+			MaxRetryAfter:   Duration(10 * time.Second),
+			Compression:     getEnvCompression("CLAUDE_ANALYSIS_COMPRESSION"),
+			MaxPayloadBytes: getEnvInt("CLAUDE_ANALYSIS_MAX_PAYLOAD_BYTES", 4<<20),
+			Auth: AuthConfig{
+				Mode:         getEnvString("CLAUDE_ANALYSIS_AUTH_MODE", AuthNone),
+				Token:        os.Getenv("CLAUDE_ANALYSIS_AUTH_TOKEN"),
+				CertFile:     os.Getenv("CLAUDE_ANALYSIS_CLIENT_CERT"),
+				KeyFile:      os.Getenv("CLAUDE_ANALYSIS_CLIENT_KEY"),
+				SettingsFile: defaultSettingsFile(),
+			},
+		},
+		Analysis: AnalysisConfig{
+			CodeSurvival: getEnvBool("CLAUDE_ANALYSIS_CODE_SURVIVAL", false),
+		},
+		Policy: PolicyConfig{
+			File: defaultPolicyFile(dataDir),
 		},
+		History: HistoryConfig{
+			Enabled: getEnvBool("CLAUDE_ANALYSIS_HISTORY", true),
+		},
+		Background: BackgroundConfig{
+			Enabled: getEnvBool("CLAUDE_ANALYSIS_BACKGROUND", true),
+			Budget:  backgroundBudget(Duration(time.Duration(getEnvInt("CLAUDE_ANALYSIS_BACKGROUND_BUDGET", 120)) * time.Second)),
+		},
+		Submission: SubmissionConfig{
+			MinInterval:      Duration(time.Duration(getEnvInt("CLAUDE_ANALYSIS_MIN_INTERVAL", 0)) * time.Second),
+			MaxPerDay:        getEnvInt("CLAUDE_ANALYSIS_MAX_UPLOADS_PER_DAY", 0),
+			DetailSampleRate: getEnvFloat("CLAUDE_ANALYSIS_DETAIL_SAMPLE_RATE", 1),
+		},
+		OTLP: OTLPConfig{
+			Enabled:            getEnvBool("CLAUDE_ANALYSIS_OTLP", false),
+			Endpoint:           os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+			TracesEndpoint:     os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+			MetricsEndpoint:    os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"),
+			Protocol:           getEnvString("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json"),
+			Headers:            getEnvKeyValues("OTEL_EXPORTER_OTLP_HEADERS"),
+			Compression:        getEnvCompression("OTEL_EXPORTER_OTLP_COMPRESSION"),
+			Timeout:            Duration(time.Duration(getEnvInt("OTEL_EXPORTER_OTLP_TIMEOUT", 10000)) * time.Millisecond),
+			ServiceName:        getEnvString("OTEL_SERVICE_NAME", "claude_analysis"),
+			ResourceAttributes: getEnvKeyValues("OTEL_RESOURCE_ATTRIBUTES"),
+			Redaction:          getEnvString("CLAUDE_ANALYSIS_OTLP_REDACTION", RedactContent),
+		},
+		Proxy:           httpclient.ProxyFromEnv(),
+		DryRun:          getEnvBool("CLAUDE_ANALYSIS_DRY_RUN", false),
+		DataDir:         dataDir,
 		UserName:        userName,
 		ExtensionName:   "Claude-Code",
 		MachineID:       machineID,
@@ -63,6 +303,87 @@ func Default() *Config {
 	}
 }
 
+// defaultDataDir 返回本地狀態目錄，可用 CLAUDE_ANALYSIS_DATA_DIR 覆蓋
+func defaultDataDir() string {
+	if dir := os.Getenv("CLAUDE_ANALYSIS_DATA_DIR"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "claude_analysis")
+	}
+	return filepath.Join(home, ".claude", "claude_analysis")
+}
+
+// defaultPolicyFile 返回策略規則檔路徑，可用 CLAUDE_ANALYSIS_POLICY_FILE 覆蓋
+func defaultPolicyFile(dataDir string) string {
+	if file := os.Getenv("CLAUDE_ANALYSIS_POLICY_FILE"); file != "" {
+		return file
+	}
+	return filepath.Join(dataDir, "policy.json")
+}
+
+// defaultSettingsFile 返回 Claude Code 的使用者設定檔路徑
+func defaultSettingsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".claude", "settings.json")
+}
+
+// getEnvString 從環境變數獲取小寫的字串值
+func getEnvString(key, defaultValue string) string {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(key)))
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvCompression 從環境變數獲取壓縮方式，只接受 gzip
+func getEnvCompression(key string) string {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv(key)), "gzip") {
+		return "gzip"
+	}
+	return ""
+}
+
+// getEnvKeyValues 解析 OpenTelemetry 格式的 key1=value1,key2=value2，值可以是 URL 編碼
+func getEnvKeyValues(key string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		if decoded, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = decoded
+		}
+		result[name] = strings.TrimSpace(value)
+	}
+	return result
+}
+
+// getEnvInt 從環境變數獲取整數，無法解析時使用默認值
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvFloat 從環境變數獲取浮點數，無法解析時使用默認值
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv(key)), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 // getEnvBool 從環境變數獲取布林值，支持多種格式
 func getEnvBool(key string, defaultValue bool) bool {
 	value := os.Getenv(key)
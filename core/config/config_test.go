@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestSSLConfigDefaultSkipped(t *testing.T) {
@@ -91,3 +94,140 @@ func TestAlternativeSSLEnvVars(t *testing.T) {
 		t.Error("Expected SkipSSLVerify to be true when INSECURE_SKIP_TLS=true")
 	}
 }
+
+func TestOTLPConfigFromEnv(t *testing.T) {
+	// 測試標準 OpenTelemetry 環境變數
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-tenant=dev, authorization=Bearer%20abc,invalid")
+	t.Setenv("OTEL_EXPORTER_OTLP_TIMEOUT", "2500")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=dev")
+
+	cfg := Default()
+
+	if cfg.OTLP.Endpoint != "http://collector:4318" || cfg.OTLP.Protocol != "http/json" {
+		t.Errorf("unexpected OTLP endpoint/protocol: %+v", cfg.OTLP)
+	}
+	// endpoint 來自 Claude Code 本身也使用的變數，只有 CLAUDE_ANALYSIS_OTLP 才會啟用匯出
+	if cfg.OTLP.Enabled {
+		t.Errorf("OTLP export should require CLAUDE_ANALYSIS_OTLP=true")
+	}
+	t.Setenv("CLAUDE_ANALYSIS_OTLP", "true")
+	if !Default().OTLP.Enabled {
+		t.Errorf("CLAUDE_ANALYSIS_OTLP=true should enable OTLP export")
+	}
+	if len(cfg.OTLP.Headers) != 2 || cfg.OTLP.Headers["authorization"] != "Bearer abc" || cfg.OTLP.Headers["x-tenant"] != "dev" {
+		t.Errorf("unexpected OTLP headers: %+v", cfg.OTLP.Headers)
+	}
+	if time.Duration(cfg.OTLP.Timeout) != 2500*time.Millisecond {
+		t.Errorf("Expected timeout 2.5s, got %v", time.Duration(cfg.OTLP.Timeout))
+	}
+	if cfg.OTLP.ServiceName != "claude_analysis" || cfg.OTLP.ResourceAttributes["deployment.environment"] != "dev" {
+		t.Errorf("unexpected OTLP resource: %+v", cfg.OTLP)
+	}
+	// span 屬性預設移除命令參數與 URL 路徑
+	if cfg.OTLP.Redaction != RedactContent {
+		t.Errorf("expected OTLP redaction to default to content, got %q", cfg.OTLP.Redaction)
+	}
+	// 設定檔中的 timeout 與其他時間長度一樣是秒數
+	if timeout := time.Duration(loadConfig(t, `{"otlp": {"timeout": 5}}`).OTLP.Timeout); timeout != 5*time.Second {
+		t.Errorf("expected otlp timeout 5 to mean 5s, got %v", timeout)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	// config.json 中的數字是秒數（與環境變數相同），字串是 Go duration
+	cases := map[string]time.Duration{
+		`120`:     120 * time.Second,
+		`0.5`:     500 * time.Millisecond,
+		`"2m30s"`: 150 * time.Second,
+		`"10ms"`:  10 * time.Millisecond,
+	}
+	for input, want := range cases {
+		var d Duration
+		if err := json.Unmarshal([]byte(input), &d); err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if time.Duration(d) != want {
+			t.Errorf("%s: expected %v, got %v", input, want, time.Duration(d))
+		}
+	}
+	for _, input := range []string{`"soon"`, `true`} {
+		var d Duration
+		if err := json.Unmarshal([]byte(input), &d); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+	d := Duration(90 * time.Second)
+	data, _ := json.Marshal(d)
+	var back Duration
+	if err := json.Unmarshal(data, &back); err != nil || back != d {
+		t.Errorf("round trip through %s gave %v, %v", data, time.Duration(back), err)
+	}
+}
+
+// loadConfig 以 content 作為設定檔執行 Load
+func loadConfig(t *testing.T, content string) *Config {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLAUDE_ANALYSIS_CONFIG", file)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cfg
+}
+
+func TestBackgroundBudget(t *testing.T) {
+	t.Setenv("CLAUDE_ANALYSIS_BACKGROUND_BUDGET", "0")
+	if budget := time.Duration(Default().Background.Budget); budget != DefaultBackgroundBudget {
+		t.Errorf("budget 0 should fall back to the default, got %v", budget)
+	}
+	t.Setenv("CLAUDE_ANALYSIS_BACKGROUND_BUDGET", "30")
+	if budget := time.Duration(Default().Background.Budget); budget != 30*time.Second {
+		t.Errorf("expected 30s from the environment, got %v", budget)
+	}
+
+	cases := map[string]time.Duration{
+		`{"background": {"budget": 120}}`:     120 * time.Second,
+		`{"background": {"budget": "2m30s"}}`: 150 * time.Second,
+		`{"background": {"budget": 0.5}}`:     DefaultBackgroundBudget,
+		`{"background": {"budget": "10ms"}}`:  DefaultBackgroundBudget,
+		`{"background": {"enabled": false}}`:  30 * time.Second,
+	}
+	for input, want := range cases {
+		if budget := time.Duration(loadConfig(t, input).Background.Budget); budget != want {
+			t.Errorf("%s: expected %v, got %v", input, want, budget)
+		}
+	}
+	if cfg := loadConfig(t, `{"background": {"enabled": false, "budget": 45}}`); cfg.Background.Enabled {
+		t.Errorf("unexpected background config: %+v", cfg.Background)
+	}
+}
+
+func TestSubmissionMinInterval(t *testing.T) {
+	t.Setenv("CLAUDE_ANALYSIS_MIN_INTERVAL", "300")
+	if interval := time.Duration(Default().Submission.MinInterval); interval != 5*time.Minute {
+		t.Errorf("expected 5m from the environment, got %v", interval)
+	}
+	// 設定檔與環境變數使用相同的單位
+	if interval := time.Duration(loadConfig(t, `{"submission": {"min_interval": 300}}`).Submission.MinInterval); interval != 5*time.Minute {
+		t.Errorf("expected min_interval 300 to mean 5m, got %v", interval)
+	}
+	if interval := time.Duration(loadConfig(t, `{"submission": {"min_interval": "1h"}}`).Submission.MinInterval); interval != time.Hour {
+		t.Errorf("expected min_interval \"1h\", got %v", interval)
+	}
+}
+
+func TestAPIDurations(t *testing.T) {
+	cfg := loadConfig(t, `{"api": {"timeout": 10, "retry_base_delay": "250ms", "max_retry_after": 30}}`)
+	if time.Duration(cfg.API.Timeout) != 10*time.Second {
+		t.Errorf("expected timeout 10 to mean 10s, got %v", time.Duration(cfg.API.Timeout))
+	}
+	if time.Duration(cfg.API.RetryBaseDelay) != 250*time.Millisecond || time.Duration(cfg.API.MaxRetryAfter) != 30*time.Second {
+		t.Errorf("unexpected retry settings: %+v", cfg.API)
+	}
+}
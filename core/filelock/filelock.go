@@ -0,0 +1,61 @@
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked 表示鎖已被其他行程持有
+var ErrLocked = errors.New("lock is held by another process")
+
+// Lock - 以 O_EXCL 建立的鎖檔，不依賴平台專屬的 flock
+type Lock struct {
+	path string
+}
+
+// TryLock 嘗試取得鎖，不等待；鎖檔超過 staleAfter 未更新時視為持有者已崩潰並接手
+func TryLock(path string, staleAfter time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create lock dir: %w", err)
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return &Lock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+		info, statErr := os.Stat(path)
+		if statErr != nil || time.Since(info.ModTime()) <= staleAfter {
+			break
+		}
+		_ = os.Remove(path)
+	}
+	return nil, ErrLocked
+}
+
+// Acquire 在 timeout 內重試取得鎖
+func Acquire(path string, staleAfter, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		lock, err := TryLock(path, staleAfter)
+		if err != ErrLocked || time.Now().After(deadline) {
+			return lock, err
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Release 釋放鎖
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	return os.Remove(l.path)
+}
@@ -0,0 +1,34 @@
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTryLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", ".lock")
+	lock, err := TryLock(path, time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock error: %v", err)
+	}
+	if _, err := TryLock(path, time.Minute); err != ErrLocked {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release error: %v", err)
+	}
+	if lock, err = TryLock(path, time.Minute); err != nil {
+		t.Fatalf("TryLock after release: %v", err)
+	}
+
+	// 持有者崩潰留下的舊鎖會被接手
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TryLock(path, time.Minute); err != nil {
+		t.Errorf("stale lock should be taken over: %v", err)
+	}
+}
@@ -0,0 +1,394 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"claude_analysis/core/filelock"
+	"claude_analysis/core/telemetry"
+)
+
+const (
+	dataName  = "records.ndjson"
+	indexName = "index.json"
+	lockName  = ".lock"
+
+	lockStaleAfter = time.Minute
+	lockTimeout    = 5 * time.Second
+
+	// 被新版本取代的資料超過一半時重寫資料檔
+	compactMinBytes = 1 << 20
+)
+
+// Entry - 一筆保存的工作階段記錄
+type Entry struct {
+	User      string                             `json:"user"`
+	MachineID string                             `json:"machineId"`
+	StoredAt  time.Time                          `json:"storedAt"`
+	Record    telemetry.ClaudeCodeAnalysisRecord `json:"record"`
+}
+
+// indexEntry - 索引中的一個工作階段，指向資料檔中最新版本的位置
+type indexEntry struct {
+	Offset int64 `json:"offset"`
+	Length int   `json:"length"`
+	Summary
+}
+
+// Summary - 不需讀取完整記錄即可過濾的欄位
+type Summary struct {
+	Session   string   `json:"session"`
+	StartedAt int64    `json:"startedAt"` // Unix 秒
+	EndedAt   int64    `json:"endedAt"`   // Unix 秒
+	Folder    string   `json:"folder"`
+	Remote    string   `json:"remote"`
+	Title     string   `json:"title,omitempty"`
+	Tools     []string `json:"tools"`
+}
+
+// index - 索引檔內容
+type index struct {
+	Sessions map[string]*indexEntry `json:"sessions"`
+	Size     int64                  `json:"size"` // 建立索引時資料檔的大小
+}
+
+// Store - 以工作階段為 key 的本地歷史記錄，資料為只追加的 NDJSON，另有索引檔
+//
+// 同一工作階段每次 Stop 都會寫入新版本，索引只指向最新版本；過時的版本在壓縮時移除。
+type Store struct {
+	Dir string
+}
+
+// Dir 返回歷史記錄目錄
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "history")
+}
+
+// New 建立位於 dataDir/history 的歷史記錄
+func New(dataDir string) *Store {
+	return &Store{Dir: Dir(dataDir)}
+}
+
+// Put 保存分析結果中的每個工作階段記錄，取代同一工作階段的舊版本
+func (s *Store) Put(analysis *telemetry.ClaudeCodeAnalysis, now time.Time) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create history dir: %w", err)
+	}
+	lock, err := filelock.Acquire(filepath.Join(s.Dir, lockName), lockStaleAfter, lockTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to lock history: %w", err)
+	}
+	defer lock.Release()
+
+	idx, err := s.currentIndex()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.dataPath(), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat history: %w", err)
+	}
+	offset := info.Size()
+	// 上一次寫入中斷時補上換行，避免與新的記錄接在同一行
+	last := make([]byte, 1)
+	if offset > 0 {
+		if _, err := file.ReadAt(last, offset-1); err == nil && last[0] != '\n' {
+			if _, err := file.Write([]byte{'\n'}); err != nil {
+				return fmt.Errorf("failed to write history: %w", err)
+			}
+			offset++
+		}
+	}
+
+	for _, record := range analysis.Records {
+		if record.TaskID == "" {
+			continue
+		}
+		line, err := json.Marshal(Entry{User: analysis.User, MachineID: analysis.MachineID, StoredAt: now, Record: record})
+		if err != nil {
+			return fmt.Errorf("failed to marshal history entry: %w", err)
+		}
+		line = append(line, '\n')
+		if _, err := file.Write(line); err != nil {
+			return fmt.Errorf("failed to write history: %w", err)
+		}
+		idx.Sessions[record.TaskID] = &indexEntry{Offset: offset, Length: len(line), Summary: summarize(&record)}
+		offset += int64(len(line))
+	}
+	idx.Size = offset
+
+	if err := s.saveIndex(idx); err != nil {
+		return err
+	}
+	if live := idx.liveBytes(); offset > compactMinBytes && live*2 < offset {
+		return s.compact(idx)
+	}
+	return nil
+}
+
+// Get 返回單一工作階段的最新記錄，不存在時返回 nil
+func (s *Store) Get(session string) (*Entry, error) {
+	idx, err := s.currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	item, ok := idx.Sessions[session]
+	if !ok {
+		return nil, nil
+	}
+	file, err := os.Open(s.dataPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+	return readEntry(file, item)
+}
+
+// Summaries 返回所有工作階段的摘要，依結束時間由新到舊排序
+func (s *Store) Summaries() ([]Summary, error) {
+	idx, err := s.currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]Summary, 0, len(idx.Sessions))
+	for _, item := range idx.Sessions {
+		summaries = append(summaries, item.Summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].EndedAt != summaries[j].EndedAt {
+			return summaries[i].EndedAt > summaries[j].EndedAt
+		}
+		return summaries[i].Session < summaries[j].Session
+	})
+	return summaries, nil
+}
+
+// summarize 從記錄取出索引欄位
+// 沒有 hook 事件日誌的開始時間時，以最早的工具呼叫時間估算（record.Timestamp 是最後一筆活動的時間）
+func summarize(record *telemetry.ClaudeCodeAnalysisRecord) Summary {
+	started := earliestDetail(record)
+	if record.SessionStartedAt > 0 {
+		started = record.SessionStartedAt / 1000
+	}
+	return Summary{
+		Session:   record.TaskID,
+		StartedAt: started,
+		EndedAt:   record.Timestamp,
+		Folder:    record.FolderPath,
+		Remote:    record.GitRemoteURL,
+		Title:     record.Title,
+		Tools:     recordTools(record),
+	}
+}
+
+// earliestDetail 返回記錄中最早的明細時間（Unix 秒），沒有明細時返回 record.Timestamp
+func earliestDetail(record *telemetry.ClaudeCodeAnalysisRecord) int64 {
+	earliest := record.Timestamp
+	visit := func(base telemetry.ClaudeCodeAnalysisDetailBase) {
+		if base.Timestamp > 0 && base.Timestamp < earliest {
+			earliest = base.Timestamp
+		}
+	}
+	for _, d := range record.WriteToFileDetails {
+		visit(d.ClaudeCodeAnalysisDetailBase)
+	}
+	for _, d := range record.ReadFileDetails {
+		visit(d.ClaudeCodeAnalysisDetailBase)
+	}
+	for _, d := range record.ApplyDiffDetails {
+		visit(d.ClaudeCodeAnalysisDetailBase)
+	}
+	for _, d := range record.RunCommandDetails {
+		visit(d.ClaudeCodeAnalysisDetailBase)
+	}
+	for _, d := range record.WebAccessDetails {
+		visit(d.ClaudeCodeAnalysisDetailBase)
+	}
+	return earliest
+}
+
+// recordTools 返回記錄中用過的工具名稱（已排序）
+func recordTools(record *telemetry.ClaudeCodeAnalysisRecord) []string {
+	seen := make(map[string]bool)
+	calls := record.ToolCallCounts
+	for name, count := range map[string]int{
+		"Read": calls.Read, "Write": calls.Write, "Edit": calls.Edit, "TodoWrite": calls.TodoWrite,
+		"Bash": calls.Bash, "WebFetch": calls.WebFetch, "WebSearch": calls.WebSearch,
+	} {
+		if count > 0 {
+			seen[name] = true
+		}
+	}
+	// 耗時統計涵蓋所有工具，包括 MCP 工具
+	for name := range record.ToolLatencies {
+		seen[name] = true
+	}
+	tools := make([]string, 0, len(seen))
+	for name := range seen {
+		tools = append(tools, name)
+	}
+	sort.Strings(tools)
+	return tools
+}
+
+// currentIndex 返回與資料檔一致的索引；索引不存在、損壞或落後時掃描資料檔重建
+func (s *Store) currentIndex() (*index, error) {
+	idx, err := s.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(s.dataPath())
+	if os.IsNotExist(err) {
+		return &index{Sessions: map[string]*indexEntry{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat history: %w", err)
+	}
+	if info.Size() == idx.Size {
+		return idx, nil
+	}
+	return s.rebuild()
+}
+
+func (s *Store) loadIndex() (*index, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, indexName))
+	if os.IsNotExist(err) {
+		return s.rebuild()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history index: %w", err)
+	}
+	var idx index
+	if json.Unmarshal(data, &idx) != nil || idx.Sessions == nil {
+		return s.rebuild()
+	}
+	return &idx, nil
+}
+
+// rebuild 掃描資料檔重建索引，後出現的版本取代先前的版本；無法解析的行會被略過
+func (s *Store) rebuild() (*index, error) {
+	idx := &index{Sessions: map[string]*indexEntry{}}
+	file, err := os.Open(s.dataPath())
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		// 寫到一半的最後一行不計入，下一次寫入會從檔案結尾開始
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var entry Entry
+			if json.Unmarshal(line, &entry) == nil && entry.Record.TaskID != "" {
+				idx.Sessions[entry.Record.TaskID] = &indexEntry{Offset: offset, Length: len(line), Summary: summarize(&entry.Record)}
+			}
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+	}
+	idx.Size = offset
+	return idx, nil
+}
+
+func (s *Store) saveIndex(idx *index) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history index: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(s.Dir, indexName), data)
+}
+
+// compact 只保留每個工作階段的最新版本，需持有鎖
+func (s *Store) compact(idx *index) error {
+	src, err := os.Open(s.dataPath())
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer src.Close()
+
+	items := make([]*indexEntry, 0, len(idx.Sessions))
+	for _, item := range idx.Sessions {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Offset < items[j].Offset })
+
+	var buf bytes.Buffer
+	compacted := &index{Sessions: make(map[string]*indexEntry, len(items))}
+	for _, item := range items {
+		line := make([]byte, item.Length)
+		if _, err := src.ReadAt(line, item.Offset); err != nil {
+			return fmt.Errorf("failed to read history: %w", err)
+		}
+		moved := *item
+		moved.Offset = int64(buf.Len())
+		compacted.Sessions[item.Session] = &moved
+		buf.Write(line)
+	}
+	compacted.Size = int64(buf.Len())
+
+	if err := writeFileAtomic(s.dataPath(), buf.Bytes()); err != nil {
+		return err
+	}
+	return s.saveIndex(compacted)
+}
+
+func (idx *index) liveBytes() int64 {
+	var total int64
+	for _, item := range idx.Sessions {
+		total += int64(item.Length)
+	}
+	return total
+}
+
+// readEntry 讀取索引指向的記錄；資料檔剛被壓縮而位置不符時返回錯誤
+func readEntry(file *os.File, item *indexEntry) (*Entry, error) {
+	line := make([]byte, item.Length)
+	if _, err := file.ReadAt(line, item.Offset); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	var entry Entry
+	if err := json.Unmarshal(line, &entry); err != nil || entry.Record.TaskID != item.Session {
+		return nil, fmt.Errorf("history index is out of date for session %s", item.Session)
+	}
+	return &entry, nil
+}
+
+func (s *Store) dataPath() string {
+	return filepath.Join(s.Dir, dataName)
+}
+
+// writeFileAtomic 以暫存檔加 rename 寫入，讀取端不會看到寫到一半的檔案
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claude_analysis/core/telemetry"
+)
+
+var day = time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func record(session string, at time.Time, folder, remote string, bash int, files ...string) telemetry.ClaudeCodeAnalysisRecord {
+	r := telemetry.ClaudeCodeAnalysisRecord{
+		TaskID:         session,
+		Timestamp:      at.Unix(),
+		FolderPath:     folder,
+		GitRemoteURL:   remote,
+		ToolCallCounts: telemetry.ClaudeCodeAnalysisToolCalls{Bash: bash},
+	}
+	for _, file := range files {
+		r.ApplyDiffDetails = append(r.ApplyDiffDetails, telemetry.ClaudeCodeAnalysisApplyDiffDetail{
+			ClaudeCodeAnalysisDetailBase: telemetry.ClaudeCodeAnalysisDetailBase{FilePath: file},
+		})
+	}
+	return r
+}
+
+func put(t *testing.T, store *Store, records ...telemetry.ClaudeCodeAnalysisRecord) {
+	t.Helper()
+	if err := store.Put(&telemetry.ClaudeCodeAnalysis{User: "alice", MachineID: "m1", Records: records}, day); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+}
+
+func sessions(entries []*Entry) []string {
+	var ids []string
+	for _, entry := range entries {
+		ids = append(ids, entry.Record.TaskID)
+	}
+	return ids
+}
+
+func seed(t *testing.T) *Store {
+	store := New(t.TempDir())
+	put(t, store, record("s1", day.Add(-48*time.Hour), "/work/api", "git@github.com:acme/api.git", 1, "/work/api/main.go"))
+	mcp := record("s2", day.Add(-24*time.Hour), "/work/web", "", 0, "/work/web/src/App.tsx")
+	mcp.ToolLatencies = map[string]telemetry.ClaudeCodeAnalysisToolLatency{"mcp__jira__search": {Count: 1}}
+	put(t, store, mcp)
+	// s1 的新版本取代舊版本
+	put(t, store, record("s1", day, "/work/api", "git@github.com:acme/api.git", 3, "/work/api/main.go", "/work/api/handler.go"))
+	return store
+}
+
+func TestStore_PutReplacesSessionVersions(t *testing.T) {
+	store := seed(t)
+
+	entry, err := store.Get("s1")
+	if err != nil || entry == nil {
+		t.Fatalf("Get error: %v", err)
+	}
+	if entry.Record.ToolCallCounts.Bash != 3 || entry.User != "alice" || entry.MachineID != "m1" {
+		t.Errorf("expected latest version of s1, got %+v", entry)
+	}
+	summaries, err := store.Summaries()
+	if err != nil || len(summaries) != 2 || summaries[0].Session != "s1" {
+		t.Errorf("unexpected summaries: %+v / %v", summaries, err)
+	}
+	if missing, err := store.Get("nope"); missing != nil || err != nil {
+		t.Errorf("expected nil for unknown session, got %+v / %v", missing, err)
+	}
+}
+
+func TestSummarize_StartedAt(t *testing.T) {
+	r := record("s1", day, "/work/api", "", 0)
+	r.ReadFileDetails = []telemetry.ClaudeCodeAnalysisReadDetail{
+		{ClaudeCodeAnalysisDetailBase: telemetry.ClaudeCodeAnalysisDetailBase{Timestamp: day.Add(-30 * time.Minute).Unix()}},
+	}
+	r.RunCommandDetails = []telemetry.ClaudeCodeAnalysisRunCommandDetail{
+		{ClaudeCodeAnalysisDetailBase: telemetry.ClaudeCodeAnalysisDetailBase{Timestamp: day.Add(-time.Hour).Unix()}},
+	}
+	// 沒有事件日誌時以最早的明細時間為開始時間
+	if got := summarize(&r).StartedAt; got != day.Add(-time.Hour).Unix() {
+		t.Errorf("expected the earliest detail as start, got %d", got)
+	}
+	r.SessionStartedAt = day.Add(-2 * time.Hour).UnixMilli()
+	if got := summarize(&r).StartedAt; got != day.Add(-2*time.Hour).Unix() {
+		t.Errorf("expected sessionStartedAt to win, got %d", got)
+	}
+	if got := summarize(&telemetry.ClaudeCodeAnalysisRecord{Timestamp: day.Unix()}).StartedAt; got != day.Unix() {
+		t.Errorf("expected the record timestamp without details, got %d", got)
+	}
+}
+
+func TestStore_Find(t *testing.T) {
+	store := seed(t)
+
+	for _, tc := range []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"all newest first", Query{}, []string{"s1", "s2"}},
+		{"since", Query{Since: day.Add(-time.Hour)}, []string{"s1"}},
+		{"until", Query{Until: day.Add(-12 * time.Hour)}, []string{"s2"}},
+		{"repo by remote", Query{Repo: "ACME/api"}, []string{"s1"}},
+		{"repo by folder", Query{Repo: "/work/web"}, []string{"s2"}},
+		{"tool", Query{Tool: "bash"}, []string{"s1"}},
+		{"mcp tool", Query{Tool: "mcp__jira__search"}, []string{"s2"}},
+		{"file substring", Query{File: "handler"}, []string{"s1"}},
+		{"file glob by name", Query{File: "*.tsx"}, []string{"s2"}},
+		{"file glob by path", Query{File: "/work/*/main.go"}, []string{"s1"}},
+		{"limit", Query{Limit: 1}, []string{"s1"}},
+		{"no match", Query{Repo: "acme", Tool: "WebFetch"}, nil},
+	} {
+		entries, err := store.Find(tc.query)
+		if err != nil {
+			t.Fatalf("%s: Find error: %v", tc.name, err)
+		}
+		if got := sessions(entries); len(got) != len(tc.want) || (len(got) > 0 && got[0] != tc.want[0]) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestStore_RecoversIndexAndTornWrites(t *testing.T) {
+	store := seed(t)
+
+	// 索引遺失時從資料檔重建
+	if err := os.Remove(filepath.Join(store.Dir, indexName)); err != nil {
+		t.Fatalf("remove index: %v", err)
+	}
+	if entry, err := store.Get("s1"); err != nil || entry == nil || entry.Record.ToolCallCounts.Bash != 3 {
+		t.Fatalf("rebuild failed: %+v / %v", entry, err)
+	}
+
+	// 中斷的寫入留下不完整的一行，之後的寫入仍然可讀
+	file, err := os.OpenFile(filepath.Join(store.Dir, dataName), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("open data: %v", err)
+	}
+	file.WriteString(`{"user":"alice","record":{"taskId":"s3"`)
+	file.Close()
+	put(t, store, record("s4", day, "/work/cli", "", 1))
+	if entry, err := store.Get("s4"); err != nil || entry == nil {
+		t.Fatalf("write after torn line failed: %v", err)
+	}
+	if entries, _ := store.Find(Query{}); len(entries) != 3 {
+		t.Errorf("expected 3 sessions, got %v", sessions(entries))
+	}
+}
+
+func TestStore_Compact(t *testing.T) {
+	store := New(t.TempDir())
+	for i := 0; i < 5; i++ {
+		put(t, store, record("s1", day, "/work/api", "", i), record("s2", day, "/work/web", "", i))
+	}
+	before, _ := os.Stat(filepath.Join(store.Dir, dataName))
+
+	idx, err := store.currentIndex()
+	if err != nil {
+		t.Fatalf("currentIndex error: %v", err)
+	}
+	if err := store.compact(idx); err != nil {
+		t.Fatalf("compact error: %v", err)
+	}
+	after, _ := os.Stat(filepath.Join(store.Dir, dataName))
+	if after.Size() >= before.Size()/4 {
+		t.Errorf("compaction should drop superseded versions: %d -> %d bytes", before.Size(), after.Size())
+	}
+	for _, session := range []string{"s1", "s2"} {
+		if entry, err := store.Get(session); err != nil || entry.Record.ToolCallCounts.Bash != 4 {
+			t.Errorf("%s after compaction: %+v / %v", session, entry, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"claude_analysis/core/telemetry"
+)
+
+// Query - 查詢條件，零值欄位表示不過濾
+type Query struct {
+	Since time.Time // 工作階段在此之後仍有活動
+	Until time.Time // 工作階段在此之前已經開始
+	Repo  string    // git remote 或專案目錄的子字串，不分大小寫
+	Tool  string    // 用過的工具名稱，不分大小寫
+	File  string    // 讀寫過的檔案路徑子字串；含 * ? [ 時以 glob 比對完整路徑或檔名
+	Limit int       // 最多返回的筆數，0 表示不限制
+}
+
+// Find 返回符合條件的記錄，依結束時間由新到舊排序
+func (s *Store) Find(q Query) ([]*Entry, error) {
+	idx, err := s.currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.find(idx, q)
+	if err != nil {
+		// 讀取期間資料檔可能剛被壓縮，重建索引後再試一次
+		if idx, err = s.rebuild(); err != nil {
+			return nil, err
+		}
+		return s.find(idx, q)
+	}
+	return entries, nil
+}
+
+func (s *Store) find(idx *index, q Query) ([]*Entry, error) {
+	var candidates []*indexEntry
+	for _, item := range idx.Sessions {
+		if q.matchSummary(&item.Summary) {
+			candidates = append(candidates, item)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].EndedAt != candidates[j].EndedAt {
+			return candidates[i].EndedAt > candidates[j].EndedAt
+		}
+		return candidates[i].Session < candidates[j].Session
+	})
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	file, err := os.Open(s.dataPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	for _, item := range candidates {
+		entry, err := readEntry(file, item)
+		if err != nil {
+			return nil, err
+		}
+		if q.File != "" && !touchesFile(&entry.Record, q.File) {
+			continue
+		}
+		entries = append(entries, entry)
+		if q.Limit > 0 && len(entries) >= q.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// matchSummary 以索引欄位過濾日期、repo 與工具
+func (q Query) matchSummary(summary *Summary) bool {
+	if !q.Since.IsZero() && summary.EndedAt < q.Since.Unix() {
+		return false
+	}
+	if !q.Until.IsZero() && summary.StartedAt > q.Until.Unix() {
+		return false
+	}
+	if q.Repo != "" {
+		repo := strings.ToLower(q.Repo)
+		if !strings.Contains(strings.ToLower(summary.Remote), repo) &&
+			!strings.Contains(strings.ToLower(filepath.ToSlash(summary.Folder)), repo) {
+			return false
+		}
+	}
+	if q.Tool != "" {
+		found := false
+		for _, tool := range summary.Tools {
+			if strings.EqualFold(tool, q.Tool) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// touchesFile 判斷工作階段是否讀寫過符合 pattern 的檔案
+func touchesFile(record *telemetry.ClaudeCodeAnalysisRecord, pattern string) bool {
+	for _, file := range RecordFiles(record) {
+		if matchFile(file, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordFiles 返回工作階段讀取、寫入或編輯過的檔案（去重、已排序）
+func RecordFiles(record *telemetry.ClaudeCodeAnalysisRecord) []string {
+	seen := make(map[string]bool)
+	for _, detail := range record.WriteToFileDetails {
+		seen[detail.FilePath] = true
+	}
+	for _, detail := range record.ApplyDiffDetails {
+		seen[detail.FilePath] = true
+	}
+	for _, detail := range record.ReadFileDetails {
+		seen[detail.FilePath] = true
+	}
+	delete(seen, "")
+	files := make([]string, 0, len(seen))
+	for file := range seen {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func matchFile(file, pattern string) bool {
+	file = filepath.ToSlash(file)
+	if !strings.ContainsAny(pattern, "*?[") {
+		return strings.Contains(strings.ToLower(file), strings.ToLower(pattern))
+	}
+	if ok, _ := path.Match(pattern, file); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, path.Base(file))
+	return ok
+}
@@ -0,0 +1,303 @@
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Claude Code hook 事件名稱
+const (
+	EventSessionStart     = "SessionStart"
+	EventUserPromptSubmit = "UserPromptSubmit"
+	EventPreToolUse       = "PreToolUse"
+	EventPostToolUse      = "PostToolUse"
+	EventStop             = "Stop"
+	EventSubagentStop     = "SubagentStop"
+	EventPreCompact       = "PreCompact"
+	EventSessionEnd       = "SessionEnd"
+)
+
+// Events 是 claude_analysis 需要註冊的所有 hook 事件
+var Events = []string{
+	EventSessionStart,
+	EventUserPromptSubmit,
+	EventPreToolUse,
+	EventPostToolUse,
+	EventStop,
+	EventSubagentStop,
+	EventPreCompact,
+	EventSessionEnd,
+}
+
+// IsToolEvent 判斷事件是否需要工具 matcher（PreToolUse/PostToolUse）
+func IsToolEvent(event string) bool {
+	return event == EventPreToolUse || event == EventPostToolUse
+}
+
+// Event - 寫入本地事件日誌的輕量事件，不包含 prompt 或工具輸入的內容
+type Event struct {
+	Event        string `json:"event"`
+	SessionID    string `json:"sessionId"`
+	Timestamp    int64  `json:"timestamp"` // Unix 毫秒
+	CWD          string `json:"cwd,omitempty"`
+	ToolName     string `json:"toolName,omitempty"`
+	ToolUseID    string `json:"toolUseId,omitempty"`
+	DurationMs   int64  `json:"durationMs,omitempty"` // PostToolUse：與對應 PreToolUse 的時間差
+	Source       string `json:"source,omitempty"`
+	Trigger      string `json:"trigger,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	PromptLength int    `json:"promptLength,omitempty"`
+	// PreToolUse 的策略決策（見 core/policy）
+	Decision       string `json:"decision,omitempty"`
+	DecisionReason string `json:"decisionReason,omitempty"`
+	RuleID         string `json:"ruleId,omitempty"`
+	Target         string `json:"target,omitempty"`
+}
+
+// EventLogDir 返回事件日誌目錄
+func EventLogDir(dataDir string) string {
+	return filepath.Join(dataDir, "events")
+}
+
+// eventLogPath 返回單一 session 的事件日誌路徑
+func eventLogPath(dataDir, sessionID string) string {
+	// session id 來自外部輸入，避免路徑穿越
+	name := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(sessionID)
+	if name == "" {
+		name = "unknown"
+	}
+	return filepath.Join(EventLogDir(dataDir), name+".jsonl")
+}
+
+// NewEvent 由 hook 輸入建立事件
+func NewEvent(input *Input, now time.Time) Event {
+	return Event{
+		Event:        input.HookEventName,
+		SessionID:    input.SessionID,
+		Timestamp:    now.UnixMilli(),
+		CWD:          input.CWD,
+		ToolName:     input.ToolName,
+		ToolUseID:    input.ToolUseID,
+		Source:       input.Source,
+		Trigger:      input.Trigger,
+		Reason:       input.Reason,
+		PromptLength: utf8.RuneCountInString(input.Prompt),
+	}
+}
+
+// RecordEvent 將事件記錄到該 session 的事件日誌
+// PostToolUse 會與最近一個尚未配對的 PreToolUse 配對並記錄耗時；
+// 配對從日誌尾端往前找，對應的 PreToolUse 通常就在最後幾行，不需要讀取整個日誌
+func RecordEvent(dataDir string, event Event) (Event, error) {
+	if event.Event == EventPostToolUse {
+		if pre := findPreToolUse(eventLogPath(dataDir, event.SessionID), event); pre != nil {
+			event.DurationMs = event.Timestamp - pre.Timestamp
+		}
+	}
+	return event, AppendEvent(dataDir, event)
+}
+
+// AppendEvent 追加一筆事件到事件日誌
+func AppendEvent(dataDir string, event Event) error {
+	path := eventLogPath(dataDir, event.SessionID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create event log dir: %w", err)
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer f.Close()
+	// 單次 write 追加整行，並行的 hook 之間不會交錯
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write event log: %w", err)
+	}
+	return nil
+}
+
+// ReadEvents 讀取 session 的所有事件，日誌不存在時返回空列表
+func ReadEvents(dataDir, sessionID string) ([]Event, error) {
+	f, err := os.Open(eventLogPath(dataDir, sessionID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		// 寫入中斷造成的殘缺行直接略過
+		if json.Unmarshal(scanner.Bytes(), &event) == nil {
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
+
+// findPreToolUse 由日誌尾端往前找出與 PostToolUse 對應的 PreToolUse：
+// 兩者都有 tool_use_id 時比對 id，否則取同名工具最近一次未配對的事件
+func findPreToolUse(path string, post Event) *Event {
+	completedIDs := make(map[string]bool) // 之後已配對的 tool_use_id
+	laterPosts := make(map[string]int)    // 之後沒有 id 的 PostToolUse 數量，各自配對一個更早的同名 PreToolUse
+	var found *Event
+	_ = scanEventsBackward(path, func(event Event) bool {
+		switch event.Event {
+		case EventPostToolUse:
+			if event.ToolUseID != "" {
+				completedIDs[event.ToolUseID] = true
+			} else {
+				laterPosts[event.ToolName]++
+			}
+		case EventPreToolUse:
+			if event.ToolUseID != "" && completedIDs[event.ToolUseID] {
+				return true
+			}
+			if post.ToolUseID != "" && event.ToolUseID != "" {
+				if event.ToolUseID == post.ToolUseID {
+					found = &event
+					return false
+				}
+				return true
+			}
+			if event.ToolName != post.ToolName {
+				return true
+			}
+			if laterPosts[event.ToolName] > 0 {
+				laterPosts[event.ToolName]--
+				return true
+			}
+			found = &event
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// eventScanChunk 是由尾端往前讀取日誌時每次讀取的大小
+const eventScanChunk = 16 * 1024
+
+// scanEventsBackward 由新到舊逐筆讀取日誌中的事件，fn 返回 false 時停止
+func scanEventsBackward(path string, fn func(Event) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	offset := info.Size()
+	var rest []byte // 尚未遇到換行的行首部分
+	for offset > 0 {
+		size := min(int64(eventScanChunk), offset)
+		offset -= size
+		chunk := make([]byte, size, int(size)+len(rest))
+		if _, err := f.ReadAt(chunk, offset); err != nil {
+			return err
+		}
+		buf := append(chunk, rest...)
+		for {
+			i := bytes.LastIndexByte(buf, '\n')
+			if i < 0 {
+				break
+			}
+			if !emitEvent(buf[i+1:], fn) {
+				return nil
+			}
+			buf = buf[:i]
+		}
+		rest = buf
+	}
+	emitEvent(rest, fn)
+	return nil
+}
+
+// emitEvent 解析一行事件並交給 fn；空行與寫入中斷造成的殘缺行直接略過
+func emitEvent(line []byte, fn func(Event) bool) bool {
+	var event Event
+	if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &event) != nil {
+		return true
+	}
+	return fn(event)
+}
+
+// Summary - 事件日誌的彙總，附加到分析記錄中
+type Summary struct {
+	StartedAt int64          // 第一個 SessionStart 的時間（Unix 毫秒），沒有則為第一筆事件
+	Counts    map[string]int // 每種事件的次數
+	Decisions []Event        // 帶有策略決策的 PreToolUse 事件
+}
+
+// Summarize 彙總事件日誌
+func Summarize(events []Event) Summary {
+	summary := Summary{Counts: make(map[string]int)}
+	for _, event := range events {
+		summary.Counts[event.Event]++
+		if event.Decision != "" {
+			summary.Decisions = append(summary.Decisions, event)
+		}
+		if event.Event == EventSessionStart && (summary.StartedAt == 0 || event.Timestamp < summary.StartedAt) {
+			summary.StartedAt = event.Timestamp
+		}
+	}
+	if summary.StartedAt == 0 && len(events) > 0 {
+		summary.StartedAt = events[0].Timestamp
+	}
+	return summary
+}
+
+// pruneMarker 是事件日誌目錄中記錄上次清理時間的標記檔
+const pruneMarker = ".pruned"
+
+// PruneEvents 刪除超過 maxAge 未更新的事件日誌
+func PruneEvents(dataDir string, maxAge time.Duration, now time.Time) {
+	entries, err := os.ReadDir(EventLogDir(dataDir))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || filepath.Ext(entry.Name()) != ".jsonl" {
+			continue
+		}
+		if now.Sub(info.ModTime()) > maxAge {
+			_ = os.Remove(filepath.Join(EventLogDir(dataDir), entry.Name()))
+		}
+	}
+}
+
+// PruneEventsEvery 在距上次清理超過 interval 時執行 PruneEvents，返回是否執行了清理
+// 以標記檔的修改時間節流：Stop 與 SessionEnd 觸發得很頻繁，不需要每次都掃描目錄
+func PruneEventsEvery(dataDir string, maxAge, interval time.Duration, now time.Time) bool {
+	marker := filepath.Join(EventLogDir(dataDir), pruneMarker)
+	if info, err := os.Stat(marker); err == nil && now.Sub(info.ModTime()) < interval {
+		return false
+	}
+	if err := os.MkdirAll(EventLogDir(dataDir), 0o755); err != nil {
+		return false
+	}
+	if err := os.WriteFile(marker, nil, 0o600); err != nil {
+		return false
+	}
+	_ = os.Chtimes(marker, now, now)
+	PruneEvents(dataDir, maxAge, now)
+	return true
+}
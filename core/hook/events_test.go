@@ -0,0 +1,159 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordEvent_PairsToolTimings(t *testing.T) {
+	dataDir := t.TempDir()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	inputs := []struct {
+		input *Input
+		at    time.Duration
+	}{
+		{&Input{SessionID: "s1", HookEventName: EventSessionStart, Source: "startup"}, 0},
+		{&Input{SessionID: "s1", HookEventName: EventUserPromptSubmit, Prompt: "fix the build"}, time.Second},
+		{&Input{SessionID: "s1", HookEventName: EventPreToolUse, ToolName: "Bash"}, 2 * time.Second},
+		{&Input{SessionID: "s1", HookEventName: EventPreToolUse, ToolName: "Read", ToolUseID: "toolu_r"}, 3 * time.Second},
+		{&Input{SessionID: "s1", HookEventName: EventPostToolUse, ToolName: "Read", ToolUseID: "toolu_r"}, 4 * time.Second},
+		{&Input{SessionID: "s1", HookEventName: EventPostToolUse, ToolName: "Bash"}, 7 * time.Second},
+	}
+	var recorded []Event
+	for _, in := range inputs {
+		event, err := RecordEvent(dataDir, NewEvent(in.input, start.Add(in.at)))
+		if err != nil {
+			t.Fatalf("RecordEvent error: %v", err)
+		}
+		recorded = append(recorded, event)
+	}
+
+	if recorded[1].PromptLength != 13 {
+		t.Errorf("prompt length expected 13, got %d", recorded[1].PromptLength)
+	}
+	if recorded[4].DurationMs != 1000 {
+		t.Errorf("Read duration expected 1000ms, got %d", recorded[4].DurationMs)
+	}
+	if recorded[5].DurationMs != 5000 {
+		t.Errorf("Bash duration expected 5000ms, got %d", recorded[5].DurationMs)
+	}
+
+	events, err := ReadEvents(dataDir, "s1")
+	if err != nil || len(events) != len(inputs) {
+		t.Fatalf("ReadEvents returned %d events, err %v", len(events), err)
+	}
+	summary := Summarize(events)
+	if summary.StartedAt != start.UnixMilli() {
+		t.Errorf("StartedAt mismatch: %d", summary.StartedAt)
+	}
+	if summary.Counts[EventPreToolUse] != 2 || summary.Counts[EventSessionStart] != 1 {
+		t.Errorf("Counts mismatch: %+v", summary.Counts)
+	}
+}
+
+func TestRecordEvent_PairsAcrossLongLog(t *testing.T) {
+	dataDir := t.TempDir()
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := func(input *Input, at time.Duration) Event {
+		t.Helper()
+		event, err := RecordEvent(dataDir, NewEvent(input, start.Add(at)))
+		if err != nil {
+			t.Fatalf("RecordEvent error: %v", err)
+		}
+		return event
+	}
+
+	// the outer Bash call stays open while enough events to span several read chunks are logged
+	record(&Input{SessionID: "s1", HookEventName: EventPreToolUse, ToolName: "Bash"}, 0)
+	for i := 0; i < 500; i++ {
+		at := time.Duration(i+1) * time.Second
+		record(&Input{SessionID: "s1", HookEventName: EventPreToolUse, ToolName: "Bash", CWD: strings.Repeat("x", 50)}, at)
+		if event := record(&Input{SessionID: "s1", HookEventName: EventPostToolUse, ToolName: "Bash"}, at+time.Second); event.DurationMs != 1000 {
+			t.Fatalf("inner Bash duration expected 1000ms, got %d", event.DurationMs)
+		}
+	}
+	// a truncated line left by an interrupted write is skipped
+	f, err := os.OpenFile(eventLogPath(dataDir, "s1"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"event":"PreToolUse","toolNa` + "\n")
+	f.Close()
+
+	if event := record(&Input{SessionID: "s1", HookEventName: EventPostToolUse, ToolName: "Bash"}, 1000*time.Second); event.DurationMs != 1000*1000 {
+		t.Errorf("outer Bash duration expected 1000s, got %dms", event.DurationMs)
+	}
+	if event := record(&Input{SessionID: "s1", HookEventName: EventPostToolUse, ToolName: "Bash"}, 1001*time.Second); event.DurationMs != 0 {
+		t.Errorf("a PostToolUse without a pending PreToolUse should have no duration, got %dms", event.DurationMs)
+	}
+}
+
+func TestReadEvents_MissingLogAndUnsafeSessionID(t *testing.T) {
+	dataDir := t.TempDir()
+	events, err := ReadEvents(dataDir, "missing")
+	if err != nil || len(events) != 0 {
+		t.Errorf("expected no events for missing log, got %v / %v", events, err)
+	}
+
+	if _, err := RecordEvent(dataDir, NewEvent(&Input{SessionID: "../../escape", HookEventName: EventStop}, time.Now())); err != nil {
+		t.Fatalf("RecordEvent error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "escape.jsonl")); err == nil {
+		t.Errorf("session id must not escape the event log directory")
+	}
+}
+
+func TestPruneEvents(t *testing.T) {
+	dataDir := t.TempDir()
+	now := time.Now()
+	for _, session := range []string{"old", "new"} {
+		if err := AppendEvent(dataDir, Event{Event: EventStop, SessionID: session}); err != nil {
+			t.Fatalf("AppendEvent error: %v", err)
+		}
+	}
+	old := now.Add(-40 * 24 * time.Hour)
+	if err := os.Chtimes(eventLogPath(dataDir, "old"), old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	PruneEvents(dataDir, 30*24*time.Hour, now)
+	if _, err := os.Stat(eventLogPath(dataDir, "old")); !os.IsNotExist(err) {
+		t.Errorf("old event log should be pruned")
+	}
+	if _, err := os.Stat(eventLogPath(dataDir, "new")); err != nil {
+		t.Errorf("recent event log should be kept: %v", err)
+	}
+}
+
+func TestPruneEventsEvery(t *testing.T) {
+	dataDir := t.TempDir()
+	now := time.Now()
+	if err := AppendEvent(dataDir, Event{Event: EventStop, SessionID: "old"}); err != nil {
+		t.Fatalf("AppendEvent error: %v", err)
+	}
+	old := now.Add(-40 * 24 * time.Hour)
+	if err := os.Chtimes(eventLogPath(dataDir, "old"), old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	if !PruneEventsEvery(dataDir, 30*24*time.Hour, time.Hour, now) {
+		t.Fatalf("first call should prune")
+	}
+	if _, err := os.Stat(eventLogPath(dataDir, "old")); !os.IsNotExist(err) {
+		t.Errorf("old event log should be pruned")
+	}
+	// 間隔內不再掃描，過了間隔才再次清理
+	if PruneEventsEvery(dataDir, 30*24*time.Hour, time.Hour, now.Add(30*time.Minute)) {
+		t.Errorf("second call within the interval should be skipped")
+	}
+	if !PruneEventsEvery(dataDir, 30*24*time.Hour, time.Hour, now.Add(2*time.Hour)) {
+		t.Errorf("call after the interval should prune again")
+	}
+	if _, err := os.Stat(filepath.Join(EventLogDir(dataDir), pruneMarker)); err != nil {
+		t.Errorf("marker should be kept: %v", err)
+	}
+}
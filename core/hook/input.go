@@ -0,0 +1,65 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Input - Claude Code 透過 stdin 傳給 hook 的事件內容
+type Input struct {
+	SessionID      string                 `json:"session_id"`
+	TranscriptPath string                 `json:"transcript_path"`
+	CWD            string                 `json:"cwd"`
+	HookEventName  string                 `json:"hook_event_name"`
+	StopHookActive bool                   `json:"stop_hook_active"`
+	ToolName       string                 `json:"tool_name"`
+	ToolInput      map[string]interface{} `json:"tool_input"`
+	ToolUseID      string                 `json:"tool_use_id"`
+	ToolResponse   interface{}            `json:"tool_response"`
+	// SessionStart: startup/resume/clear/compact
+	Source string `json:"source"`
+	// UserPromptSubmit
+	Prompt string `json:"prompt"`
+	// PreCompact: manual/auto
+	Trigger string `json:"trigger"`
+	// SessionEnd: clear/logout/prompt_input_exit/other
+	Reason string `json:"reason"`
+}
+
+// ParseInput 解析 hook 的 stdin 內容
+// 優先以 JSON 解析；失敗時退回 Python dict 字面量格式（舊版 wrapper 會以 str(dict) 傳入）
+func ParseInput(data []byte) (*Input, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty hook input")
+	}
+
+	var input Input
+	jsonErr := json.Unmarshal(data, &input)
+	if jsonErr == nil {
+		return &input, nil
+	}
+
+	value, err := ParsePythonLiteral(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse hook input as JSON (%v) or Python literal (%w)", jsonErr, err)
+	}
+	if _, ok := value.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("hook input is not an object")
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize hook input: %w", err)
+	}
+	if err := json.Unmarshal(normalized, &input); err != nil {
+		return nil, fmt.Errorf("failed to decode hook input: %w", err)
+	}
+	return &input, nil
+}
+
+// ToolInputString 返回 tool_input 中的字串欄位，不存在或型別不符時返回空字串
+func (in *Input) ToolInputString(key string) string {
+	s, _ := in.ToolInput[key].(string)
+	return s
+}
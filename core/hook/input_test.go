@@ -0,0 +1,75 @@
+package hook
+
+import (
+	"testing"
+)
+
+func TestParseInput_JSON(t *testing.T) {
+	data := `{
+		"session_id": "abc123",
+		"transcript_path": "/Users/o'brien/.claude/projects/x/abc123.jsonl",
+		"cwd": "/Users/o'brien/repo",
+		"hook_event_name": "PreToolUse",
+		"stop_hook_active": false,
+		"tool_name": "Bash",
+		"tool_input": {"command": "echo 'None of True'", "timeout": 1000}
+	}`
+
+	input, err := ParseInput([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseInput error: %v", err)
+	}
+	if input.SessionID != "abc123" || input.HookEventName != "PreToolUse" || input.ToolName != "Bash" {
+		t.Errorf("unexpected input: %+v", input)
+	}
+	if input.TranscriptPath != "/Users/o'brien/.claude/projects/x/abc123.jsonl" {
+		t.Errorf("transcript_path mismatch: %q", input.TranscriptPath)
+	}
+	if input.ToolInputString("command") != "echo 'None of True'" {
+		t.Errorf("tool_input.command mismatch: %q", input.ToolInputString("command"))
+	}
+}
+
+func TestParseInput_PythonDictFallback(t *testing.T) {
+	data := `{'session_id': 'abc123', 'transcript_path': "/tmp/it's None/abc.jsonl", ` +
+		`'hook_event_name': 'Stop', 'stop_hook_active': True, 'tool_input': {'command': 'grep -r "False" .', 'args': ('a', None), 'n': -1.5e2}}`
+
+	input, err := ParseInput([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseInput error: %v", err)
+	}
+	if input.TranscriptPath != "/tmp/it's None/abc.jsonl" {
+		t.Errorf("transcript_path mismatch: %q", input.TranscriptPath)
+	}
+	if !input.StopHookActive || input.HookEventName != "Stop" {
+		t.Errorf("unexpected input: %+v", input)
+	}
+	if input.ToolInputString("command") != `grep -r "False" .` {
+		t.Errorf("tool_input.command mismatch: %q", input.ToolInputString("command"))
+	}
+	args, ok := input.ToolInput["args"].([]interface{})
+	if !ok || len(args) != 2 || args[0] != "a" || args[1] != nil {
+		t.Errorf("tuple not decoded: %#v", input.ToolInput["args"])
+	}
+	if input.ToolInput["n"] != -150.0 {
+		t.Errorf("number not decoded: %#v", input.ToolInput["n"])
+	}
+}
+
+func TestParsePythonLiteral_Escapes(t *testing.T) {
+	value, err := ParsePythonLiteral(`'line\n\'quoted\' 世\x41 \d'`)
+	if err != nil {
+		t.Fatalf("ParsePythonLiteral error: %v", err)
+	}
+	if value != "line\n'quoted' 世A \\d" {
+		t.Errorf("unexpected value: %q", value)
+	}
+}
+
+func TestParseInput_Invalid(t *testing.T) {
+	for _, data := range []string{"", "not a dict", "{'a': }", "['list']", "{'a': 1} trailing"} {
+		if _, err := ParseInput([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
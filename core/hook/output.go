@@ -0,0 +1,51 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// PreToolUse 權限決策
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+	DecisionAsk   = "ask"
+)
+
+// Output - Claude Code hook 的 JSON 輸出
+type Output struct {
+	Continue           *bool           `json:"continue,omitempty"`
+	StopReason         string          `json:"stopReason,omitempty"`
+	SuppressOutput     bool            `json:"suppressOutput,omitempty"`
+	SystemMessage      string          `json:"systemMessage,omitempty"`
+	HookSpecificOutput *SpecificOutput `json:"hookSpecificOutput,omitempty"`
+}
+
+// SpecificOutput - 事件專屬的輸出欄位
+type SpecificOutput struct {
+	HookEventName            string `json:"hookEventName"`
+	PermissionDecision       string `json:"permissionDecision,omitempty"`
+	PermissionDecisionReason string `json:"permissionDecisionReason,omitempty"`
+}
+
+// PermissionOutput 建立 PreToolUse 的權限決策輸出
+func PermissionOutput(decision, reason string) *Output {
+	return &Output{
+		HookSpecificOutput: &SpecificOutput{
+			HookEventName:            EventPreToolUse,
+			PermissionDecision:       decision,
+			PermissionDecisionReason: reason,
+		},
+	}
+}
+
+// Write 將輸出以單行 JSON 寫出
+func (o *Output) Write(w io.Writer) error {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hook output: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
@@ -0,0 +1,29 @@
+package hook
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOutput_Write(t *testing.T) {
+	cases := []struct {
+		output *Output
+		want   string
+	}{
+		{&Output{SuppressOutput: true}, `{"suppressOutput":true}`},
+		{&Output{SuppressOutput: true, SystemMessage: "update available"}, `{"suppressOutput":true,"systemMessage":"update available"}`},
+		{
+			PermissionOutput(DecisionDeny, "no force push"),
+			`{"hookSpecificOutput":{"hookEventName":"PreToolUse","permissionDecision":"deny","permissionDecisionReason":"no force push"}}`,
+		},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := c.output.Write(&buf); err != nil {
+			t.Fatalf("Write error: %v", err)
+		}
+		if got := buf.String(); got != c.want+"\n" {
+			t.Errorf("got %s, want %s", got, c.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package hook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParsePythonLiteral 解析 Python 字面量（dict/list/tuple/str/數字/True/False/None），
+// 返回與 encoding/json 解碼結果相同的 Go 型別。
+// 與字串替換不同，字串內容中的引號、True/None 等字樣都會被原樣保留。
+func ParsePythonLiteral(s string) (interface{}, error) {
+	p := &pyParser{src: s}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.src) {
+		return nil, p.errorf("unexpected trailing characters")
+	}
+	return value, nil
+}
+
+type pyParser struct {
+	src string
+	pos int
+}
+
+func (p *pyParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("python literal at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *pyParser) skipSpace() {
+	for p.pos < len(p.src) && strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *pyParser) parseValue() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	switch c := p.src[p.pos]; {
+	case c == '{':
+		return p.parseDict()
+	case c == '[':
+		return p.parseSequence('[', ']')
+	case c == '(':
+		return p.parseSequence('(', ')')
+	case c == '\'' || c == '"':
+		return p.parseString()
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	default:
+		return p.parseKeyword()
+	}
+}
+
+func (p *pyParser) parseDict() (interface{}, error) {
+	p.pos++ // '{'
+	result := make(map[string]interface{})
+	for {
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '}' {
+			p.pos++
+			return result, nil
+		}
+		key, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != ':' {
+			return nil, p.errorf("expected ':' after dict key")
+		}
+		p.pos++
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		result[fmt.Sprint(key)] = value
+
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated dict")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return result, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in dict")
+		}
+	}
+}
+
+func (p *pyParser) parseSequence(open, close byte) (interface{}, error) {
+	p.pos++ // open
+	result := []interface{}{}
+	for {
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == close {
+			p.pos++
+			return result, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated %c", open)
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case close:
+			p.pos++
+			return result, nil
+		default:
+			return nil, p.errorf("expected ',' or '%c'", close)
+		}
+	}
+}
+
+func (p *pyParser) parseString() (interface{}, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\\':
+			if err := p.parseEscape(&b); err != nil {
+				return nil, err
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+// parseEscape 處理 Python 字串中的跳脫序列，游標位於反斜線
+func (p *pyParser) parseEscape(b *strings.Builder) error {
+	p.pos++
+	if p.pos >= len(p.src) {
+		return p.errorf("unterminated escape sequence")
+	}
+	c := p.src[p.pos]
+	p.pos++
+	simple := map[byte]string{
+		'\\': "\\", '\'': "'", '"': "\"", 'n': "\n", 't': "\t",
+		'r': "\r", 'b': "\b", 'f': "\f", 'v': "\v", 'a': "\a", '0': "\x00",
+	}
+	if s, ok := simple[c]; ok {
+		b.WriteString(s)
+		return nil
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}
+	n, ok := digits[c]
+	if !ok {
+		// 未知跳脫序列在 Python 中保持原樣
+		b.WriteByte('\\')
+		b.WriteByte(c)
+		return nil
+	}
+	if p.pos+n > len(p.src) {
+		return p.errorf("truncated \\%c escape", c)
+	}
+	code, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+	if err != nil {
+		return p.errorf("invalid \\%c escape", c)
+	}
+	p.pos += n
+	b.WriteRune(rune(code))
+	return nil
+}
+
+func (p *pyParser) parseNumber() (interface{}, error) {
+	start := p.pos
+	for p.pos < len(p.src) && strings.ContainsRune("+-.0123456789eE_", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	literal := strings.ReplaceAll(p.src[start:p.pos], "_", "")
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %q", literal)
+	}
+	return f, nil
+}
+
+func (p *pyParser) parseKeyword() (interface{}, error) {
+	keywords := map[string]interface{}{"True": true, "False": false, "None": nil}
+	for word, value := range keywords {
+		if strings.HasPrefix(p.src[p.pos:], word) {
+			p.pos += len(word)
+			return value, nil
+		}
+	}
+	return nil, p.errorf("unexpected character %q", p.src[p.pos])
+}
@@ -0,0 +1,119 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Proxy - 明確的代理設定；未設定的欄位沿用 HTTP_PROXY、HTTPS_PROXY 與 NO_PROXY 環境變數
+type Proxy struct {
+	URL      string `json:"url"`      // 同時用於 http 與 https 請求，例如 http://proxy.example.com:8080
+	NoProxy  string `json:"no_proxy"` // 逗號分隔的主機、網域或 CIDR，格式同 NO_PROXY
+	Username string `json:"username"` // 代理認證，URL 已含帳號時忽略
+	Password string `json:"password"`
+}
+
+// Options - 建立 HTTP client 的選項
+type Options struct {
+	Timeout            time.Duration
+	InsecureSkipVerify bool
+	Certificates       []tls.Certificate // mTLS 客戶端憑證
+	DisableKeepAlives  bool
+	Proxy              Proxy
+}
+
+// ProxyFromEnv 從 CLAUDE_ANALYSIS_PROXY、CLAUDE_ANALYSIS_NO_PROXY、
+// CLAUDE_ANALYSIS_PROXY_USERNAME 與 CLAUDE_ANALYSIS_PROXY_PASSWORD 讀取明確的代理設定
+func ProxyFromEnv() Proxy {
+	return Proxy{
+		URL:      strings.TrimSpace(os.Getenv("CLAUDE_ANALYSIS_PROXY")),
+		NoProxy:  strings.TrimSpace(os.Getenv("CLAUDE_ANALYSIS_NO_PROXY")),
+		Username: os.Getenv("CLAUDE_ANALYSIS_PROXY_USERNAME"),
+		Password: os.Getenv("CLAUDE_ANALYSIS_PROXY_PASSWORD"),
+	}
+}
+
+// New 建立套用代理、TLS 與逾時設定的 HTTP client
+func New(opts Options) *http.Client {
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: NewTransport(opts),
+	}
+}
+
+// NewTransport 以 http.DefaultTransport 為基礎建立 transport，保留其連線逾時與 HTTP/2 設定
+func NewTransport(opts Options) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = opts.Proxy.Func()
+	transport.DisableKeepAlives = opts.DisableKeepAlives
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		Certificates:       opts.Certificates,
+	}
+	return transport
+}
+
+// Config 合併明確設定與環境變數，返回帶有認證資訊的代理設定
+func (p Proxy) Config() *httpproxy.Config {
+	cfg := httpproxy.FromEnvironment()
+	if p.URL != "" {
+		cfg.HTTPProxy, cfg.HTTPSProxy = p.URL, p.URL
+	}
+	if p.NoProxy != "" {
+		cfg.NoProxy = p.NoProxy
+	}
+	cfg.HTTPProxy = withCredentials(cfg.HTTPProxy, p.Username, p.Password)
+	cfg.HTTPSProxy = withCredentials(cfg.HTTPSProxy, p.Username, p.Password)
+	return cfg
+}
+
+// Func 返回 http.Transport 使用的代理選擇函數；代理 URL 中的帳號密碼由 transport 轉為 Proxy-Authorization
+func (p Proxy) Func() func(*http.Request) (*url.URL, error) {
+	proxyFunc := p.Config().ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// NpmEnv 返回讓 npm 使用相同代理的環境變數；以環境變數傳遞可避免密碼出現在命令列與日誌中
+func (p Proxy) NpmEnv() []string {
+	cfg := p.Config()
+	var env []string
+	if cfg.HTTPProxy != "" {
+		env = append(env, "npm_config_proxy="+withScheme(cfg.HTTPProxy))
+	}
+	if cfg.HTTPSProxy != "" {
+		env = append(env, "npm_config_https_proxy="+withScheme(cfg.HTTPSProxy))
+	}
+	if cfg.NoProxy != "" {
+		env = append(env, "npm_config_noproxy="+cfg.NoProxy)
+	}
+	return env
+}
+
+// withCredentials 將帳號密碼加入代理 URL；URL 為空、已含帳號或無法解析時原樣返回
+func withCredentials(proxyURL, username, password string) string {
+	if proxyURL == "" || username == "" {
+		return proxyURL
+	}
+	u, err := url.Parse(withScheme(proxyURL))
+	if err != nil || u.User != nil {
+		return proxyURL
+	}
+	u.User = url.UserPassword(username, password)
+	return u.String()
+}
+
+// withScheme 為沒有 scheme 的代理位址（例如 proxy:8080）補上 http://，與 httpproxy 的解析方式一致
+func withScheme(proxyURL string) string {
+	if strings.Contains(proxyURL, "://") {
+		return proxyURL
+	}
+	return "http://" + proxyURL
+}
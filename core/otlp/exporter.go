@@ -0,0 +1,249 @@
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/httpclient"
+	"claude_analysis/core/telemetry"
+)
+
+// scopeName 是匯出資料的 instrumentation scope
+const scopeName = "claude_analysis"
+
+// stateRetention 是未收到 SessionEnd 的匯出狀態保留時間
+const stateRetention = 30 * 24 * time.Hour
+
+// Enabled 判斷是否明確啟用了 OTLP 匯出並設定了任何 endpoint
+func Enabled(cfg config.OTLPConfig) bool {
+	return cfg.Enabled && (cfg.Endpoint != "" || cfg.TracesEndpoint != "" || cfg.MetricsEndpoint != "")
+}
+
+// Supported 判斷設定的協定是否支援（目前只支援 http/json）
+//
+// 不支援時只在協定改變後第一次呼叫時記錄警告：OTEL_EXPORTER_OTLP_PROTOCOL 通常是為 Claude Code
+// 本身設定的 grpc 或 http/protobuf，不應讓每次 Stop 都回報錯誤
+func Supported(cfg *config.Config) bool {
+	protocol := cfg.OTLP.Protocol
+	if protocol == "" || protocol == "http/json" {
+		return true
+	}
+	marker := filepath.Join(StateDir(cfg.DataDir), "unsupported_protocol")
+	if data, err := os.ReadFile(marker); err != nil || string(data) != protocol {
+		log.Printf("[WARN] OTLP protocol %q is not supported, only http/json; skipping OTLP export", protocol)
+		if err := os.MkdirAll(StateDir(cfg.DataDir), 0o755); err == nil {
+			_ = os.WriteFile(marker, []byte(protocol), 0o600)
+		}
+	}
+	return false
+}
+
+// Exporter - OTLP/HTTP JSON 匯出器
+type Exporter struct {
+	TracesURL  string // 空字串表示不匯出 traces
+	MetricsURL string // 空字串表示不匯出 metrics
+
+	headers     map[string]string
+	compression string
+	resource    Resource
+	scope       Scope
+	client      *http.Client
+}
+
+// NewExporter 依設定建立匯出器；目前只支援 http/json
+func NewExporter(cfg *config.Config) (*Exporter, error) {
+	otlp := cfg.OTLP
+	if otlp.Protocol != "" && otlp.Protocol != "http/json" {
+		return nil, fmt.Errorf("unsupported OTLP protocol %q, only http/json is supported", otlp.Protocol)
+	}
+	return &Exporter{
+		TracesURL:   signalURL(otlp.TracesEndpoint, otlp.Endpoint, "v1/traces"),
+		MetricsURL:  signalURL(otlp.MetricsEndpoint, otlp.Endpoint, "v1/metrics"),
+		headers:     otlp.Headers,
+		compression: otlp.Compression,
+		resource:    newResource(cfg),
+		scope:       Scope{Name: scopeName, Version: cfg.InsightsVersion},
+		client:      httpclient.New(httpclient.Options{Timeout: time.Duration(otlp.Timeout), Proxy: cfg.Proxy}),
+	}, nil
+}
+
+// signalURL 依 OpenTelemetry 規範決定 URL：指定訊號的 endpoint 原樣使用，通用 endpoint 附加訊號路徑
+func signalURL(signalEndpoint, endpoint, path string) string {
+	if signalEndpoint != "" {
+		return signalEndpoint
+	}
+	if endpoint == "" {
+		return ""
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/" + path
+}
+
+// newResource 建立 resource 屬性，OTEL_RESOURCE_ATTRIBUTES 可覆蓋預設值
+func newResource(cfg *config.Config) Resource {
+	values := map[string]string{
+		"service.name":    cfg.OTLP.ServiceName,
+		"service.version": cfg.InsightsVersion,
+		"host.id":         cfg.MachineID,
+		"user.name":       cfg.UserName,
+	}
+	for key, value := range cfg.OTLP.ResourceAttributes {
+		values[key] = value
+	}
+	var resource Resource
+	for _, key := range sortedKeys(values) {
+		resource.Attributes = append(resource.Attributes, String(key, values[key]))
+	}
+	return resource
+}
+
+// ExportSpans 匯出 spans
+func (e *Exporter) ExportSpans(spans []Span) error {
+	if e.TracesURL == "" || len(spans) == 0 {
+		return nil
+	}
+	return e.post(e.TracesURL, TracesRequest{ResourceSpans: []ResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []ScopeSpans{{Scope: e.scope, Spans: spans}},
+	}}})
+}
+
+// ExportMetrics 匯出指標
+func (e *Exporter) ExportMetrics(metrics []Metric) error {
+	if e.MetricsURL == "" || len(metrics) == 0 {
+		return nil
+	}
+	return e.post(e.MetricsURL, MetricsRequest{ResourceMetrics: []ResourceMetrics{{
+		Resource:     e.resource,
+		ScopeMetrics: []ScopeMetrics{{Scope: e.scope, Metrics: metrics}},
+	}}})
+}
+
+func (e *Exporter) post(url string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal OTLP request: %w", err)
+	}
+	if e.compression == "gzip" {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(data); err != nil {
+			return fmt.Errorf("failed to compress OTLP request: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress OTLP request: %w", err)
+		}
+		data = buf.Bytes()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.compression == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send OTLP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("OTLP endpoint %s returned status %d: %s", url, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// state - 單一工作階段的匯出進度，避免每次 Stop 重複匯出相同的 span
+type state struct {
+	ExportedTurns int `json:"exportedTurns"`
+}
+
+// ExportSession 匯出工作階段
+//
+// 每次 Stop 時匯出新完成的回合（含工具呼叫）與累計指標；final 為 true（SessionEnd）時
+// 再匯出涵蓋整個工作階段的根 span 並清除進度。指標以工作階段開始時間為起點累計，重複匯出不會重複計數。
+func ExportSession(cfg *config.Config, logs []telemetry.ClaudeCodeLog, final bool) error {
+	exporter, err := NewExporter(cfg)
+	if err != nil {
+		return err
+	}
+	session := NewSession(logs)
+	if session.ID == "" {
+		return fmt.Errorf("transcript has no session id")
+	}
+	switch cfg.OTLP.Redaction {
+	case "", config.RedactNone, config.RedactContent, config.RedactStrict:
+		session.Redaction = cfg.OTLP.Redaction
+	default:
+		return fmt.Errorf("unknown redaction level %q", cfg.OTLP.Redaction)
+	}
+
+	path := statePath(cfg.DataDir, session.ID)
+	var st state
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &st)
+	}
+
+	spans := session.TurnSpans(st.ExportedTurns)
+	if final {
+		spans = append(spans, session.RootSpan())
+	}
+	if err := exporter.ExportSpans(spans); err != nil {
+		return err
+	}
+	if final {
+		_ = os.Remove(path)
+	} else if err := saveState(cfg.DataDir, path, state{ExportedTurns: len(session.Turns)}); err != nil {
+		return err
+	}
+	return exporter.ExportMetrics(session.Metrics())
+}
+
+// StateDir 返回匯出進度目錄
+func StateDir(dataDir string) string {
+	return filepath.Join(dataDir, "otlp")
+}
+
+func statePath(dataDir, sessionID string) string {
+	// session id 來自外部輸入，避免路徑穿越
+	name := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(sessionID)
+	return filepath.Join(StateDir(dataDir), name+".json")
+}
+
+// saveState 寫入進度，並順便刪除很久沒有更新（沒有收到 SessionEnd）的進度檔
+func saveState(dataDir, path string, st state) error {
+	if err := os.MkdirAll(StateDir(dataDir), 0o755); err != nil {
+		return fmt.Errorf("failed to create OTLP state dir: %w", err)
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to marshal OTLP state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write OTLP state: %w", err)
+	}
+
+	entries, _ := os.ReadDir(StateDir(dataDir))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > stateRetention {
+			_ = os.Remove(filepath.Join(StateDir(dataDir), entry.Name()))
+		}
+	}
+	return nil
+}
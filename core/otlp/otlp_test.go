@@ -0,0 +1,311 @@
+package otlp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/telemetry"
+)
+
+var transcript = []string{
+	`{"type":"user","sessionId":"sess-1","uuid":"u1","cwd":"/work/api","gitBranch":"main","version":"1.0.83","timestamp":"2025-01-01T00:00:00.000Z","message":{"role":"user","content":"fix the build"}}`,
+	`{"type":"assistant","sessionId":"sess-1","uuid":"a1","timestamp":"2025-01-01T00:00:02.000Z","message":{"id":"msg_1","model":"claude-sonnet-4","content":[{"type":"tool_use","id":"toolu_bash","name":"Bash","input":{"command":"make"}}],"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100}}}`,
+	`{"type":"user","sessionId":"sess-1","uuid":"r1","parentUuid":"a1","timestamp":"2025-01-01T00:00:05.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_bash","content":"make: *** error","is_error":true}]}}`,
+	`{"type":"assistant","sessionId":"sess-1","uuid":"a2","timestamp":"2025-01-01T00:00:06.000Z","message":{"id":"msg_2","model":"claude-sonnet-4","content":[{"type":"tool_use","id":"toolu_edit","name":"Edit","input":{"file_path":"/work/api/main.go"}}],"usage":{"input_tokens":20,"output_tokens":15}}}`,
+	`{"type":"user","sessionId":"sess-1","uuid":"r2","parentUuid":"a2","timestamp":"2025-01-01T00:00:07.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_edit","content":"ok"}]},"toolUseResult":{"filePath":"/work/api/main.go","oldString":"a\nb","newString":"a\nb\nc"}}`,
+	`{"type":"user","sessionId":"sess-1","uuid":"m1","isMeta":true,"timestamp":"2025-01-01T00:00:08.000Z","message":{"role":"user","content":"Caveat: generated by local commands"}}`,
+	`{"type":"user","sessionId":"sess-1","uuid":"u2","timestamp":"2025-01-01T00:01:00.000Z","message":{"role":"user","content":"add a test"}}`,
+	`{"type":"assistant","sessionId":"sess-1","uuid":"a3","timestamp":"2025-01-01T00:01:03.000Z","message":{"id":"msg_3","model":"claude-sonnet-4","content":[{"type":"tool_use","id":"toolu_write","name":"Write","input":{"file_path":"/work/api/main_test.go"}}],"usage":{"input_tokens":30,"output_tokens":25}}}`,
+	`{"type":"user","sessionId":"sess-1","uuid":"r3","parentUuid":"a3","timestamp":"2025-01-01T00:01:04.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_write","content":"ok"}]},"toolUseResult":{"type":"create","filePath":"/work/api/main_test.go","content":"package main\n\nfunc TestX() {}"}}`,
+}
+
+var followUp = []string{
+	`{"type":"user","sessionId":"sess-1","uuid":"u3","timestamp":"2025-01-01T00:02:00.000Z","message":{"role":"user","content":"thanks"}}`,
+	`{"type":"assistant","sessionId":"sess-1","uuid":"a4","timestamp":"2025-01-01T00:02:01.000Z","message":{"id":"msg_4","model":"claude-3-5-haiku","content":[{"type":"text","text":"You're welcome"}],"usage":{"input_tokens":1,"output_tokens":2}}}`,
+}
+
+func decode(t *testing.T, lines []string) []telemetry.ClaudeCodeLog {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range lines {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("invalid test line %s: %v", line, err)
+		}
+		records = append(records, obj)
+	}
+	return telemetry.DecodeLogs(records)
+}
+
+// receiver 是記錄收到的 OTLP 請求的本地 collector
+type receiver struct {
+	mu      sync.Mutex
+	traces  []TracesRequest
+	metrics []MetricsRequest
+	headers http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	body, _ := io.ReadAll(req.Body)
+	r.headers = req.Header
+	switch req.URL.Path {
+	case "/v1/traces":
+		var tr TracesRequest
+		if json.Unmarshal(body, &tr) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.traces = append(r.traces, tr)
+	case "/v1/metrics":
+		var mr MetricsRequest
+		if json.Unmarshal(body, &mr) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.metrics = append(r.metrics, mr)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (r *receiver) spans(i int) []Span {
+	return r.traces[i].ResourceSpans[0].ScopeSpans[0].Spans
+}
+
+func attribute(attributes []KeyValue, key string) string {
+	for _, kv := range attributes {
+		if kv.Key != key {
+			continue
+		}
+		switch {
+		case kv.Value.StringValue != nil:
+			return *kv.Value.StringValue
+		case kv.Value.IntValue != nil:
+			return *kv.Value.IntValue
+		case kv.Value.BoolValue != nil && *kv.Value.BoolValue:
+			return "true"
+		case kv.Value.BoolValue != nil:
+			return "false"
+		}
+	}
+	return ""
+}
+
+func testConfig(t *testing.T, endpoint string) *config.Config {
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	cfg.OTLP = config.OTLPConfig{
+		Enabled:     true,
+		Endpoint:    endpoint,
+		Protocol:    "http/json",
+		Headers:     map[string]string{"x-tenant": "dev"},
+		ServiceName: "claude_analysis",
+		Timeout:     5e9,
+	}
+	return cfg
+}
+
+func TestExportSession_IncrementalTurnsAndRootSpan(t *testing.T) {
+	recv := &receiver{}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+	cfg := testConfig(t, server.URL+"/")
+
+	if err := ExportSession(cfg, decode(t, transcript), false); err != nil {
+		t.Fatalf("ExportSession error: %v", err)
+	}
+	if len(recv.traces) != 1 || len(recv.metrics) != 1 || recv.headers.Get("x-tenant") != "dev" {
+		t.Fatalf("unexpected requests: %d traces, %d metrics, headers %v", len(recv.traces), len(recv.metrics), recv.headers)
+	}
+	if attribute(recv.traces[0].ResourceSpans[0].Resource.Attributes, "service.name") != "claude_analysis" {
+		t.Errorf("missing service.name resource attribute")
+	}
+
+	spans := recv.spans(0)
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name
+		if tool := attribute(span.Attributes, "gen_ai.tool.name"); tool != "" {
+			names[i] += " " + tool
+		}
+	}
+	want := "claude_analysis.turn,claude_analysis.tool_call Bash,claude_analysis.tool_call Edit,claude_analysis.turn,claude_analysis.tool_call Write"
+	if strings.Join(names, ",") != want {
+		t.Fatalf("unexpected spans: %v", names)
+	}
+	session := NewSession(decode(t, transcript))
+	turn, bash := spans[0], spans[1]
+	if turn.TraceID != session.TraceID() || len(turn.TraceID) != 32 || turn.ParentSpanID != session.rootSpanID() || bash.ParentSpanID != turn.SpanID {
+		t.Errorf("unexpected span hierarchy: %+v / %+v", turn, bash)
+	}
+	if turn.StartTimeUnixNano != "1735689600000000000" || turn.EndTimeUnixNano != "1735689608000000000" {
+		t.Errorf("unexpected turn timing: %s - %s", turn.StartTimeUnixNano, turn.EndTimeUnixNano)
+	}
+	if attribute(turn.Attributes, "gen_ai.usage.output_tokens") != "20" || attribute(turn.Attributes, "gen_ai.request.model") != "claude-sonnet-4" {
+		t.Errorf("unexpected turn attributes: %+v", turn.Attributes)
+	}
+	if bash.Status == nil || bash.Status.Code != statusCodeError || attribute(bash.Attributes, "claude_analysis.tool.command") != "make" {
+		t.Errorf("unexpected Bash span: %+v", bash)
+	}
+
+	metrics := map[string]Metric{}
+	for _, metric := range recv.metrics[0].ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[metric.Name] = metric
+	}
+	values := func(name string, keys ...string) map[string]string {
+		result := map[string]string{}
+		for _, point := range metrics[name].Sum.DataPoints {
+			var parts []string
+			for _, key := range keys {
+				parts = append(parts, attribute(point.Attributes, key))
+			}
+			result[strings.Join(parts, "/")] = point.AsInt
+		}
+		return result
+	}
+	if v := values("claude_analysis.token.usage", "model", "type"); v["claude-sonnet-4/input"] != "60" || v["claude-sonnet-4/cacheRead"] != "100" {
+		t.Errorf("unexpected token usage: %v", v)
+	}
+	if v := values("claude_analysis.tool_call.count", "tool_name", "success"); v["Bash/false"] != "1" || v["Edit/true"] != "1" || v["Write/true"] != "1" {
+		t.Errorf("unexpected tool calls: %v", v)
+	}
+	if v := values("claude_analysis.lines_of_code.count", "type"); v["added"] != "6" || v["removed"] != "2" {
+		t.Errorf("unexpected lines of code: %v", v)
+	}
+	if metrics["claude_analysis.session.count"].Sum.AggregationTemporality != temporalityCumulative {
+		t.Errorf("metrics should be cumulative")
+	}
+
+	// 下一次 Stop 只匯出新的回合；SessionEnd 時加上根 span 並清除進度
+	if err := ExportSession(cfg, decode(t, append(transcript, followUp...)), true); err != nil {
+		t.Fatalf("final ExportSession error: %v", err)
+	}
+	spans = recv.spans(1)
+	if len(spans) != 2 || spans[0].Name != "claude_analysis.turn" || spans[1].Name != "claude_analysis.session" {
+		t.Fatalf("unexpected final spans: %+v", spans)
+	}
+	root := spans[1]
+	if root.SpanID != session.rootSpanID() || attribute(root.Attributes, "claude_analysis.turns") != "3" || root.EndTimeUnixNano != "1735689721000000000" {
+		t.Errorf("unexpected root span: %+v", root)
+	}
+	if _, err := os.Stat(statePath(cfg.DataDir, "sess-1")); !os.IsNotExist(err) {
+		t.Errorf("state should be removed after SessionEnd")
+	}
+}
+
+func TestToolSpan_Redaction(t *testing.T) {
+	lines := append([]string{}, transcript...)
+	lines = append(lines,
+		`{"type":"assistant","sessionId":"sess-1","uuid":"a5","timestamp":"2025-01-01T00:01:05.000Z","message":{"id":"msg_5","model":"claude-sonnet-4","content":[{"type":"tool_use","id":"toolu_curl","name":"Bash","input":{"command":"TOKEN=secret curl https://api.example.com/v1?key=abc"}},{"type":"tool_use","id":"toolu_fetch","name":"WebFetch","input":{"url":"https://docs.example.com/private/page?token=abc"}}]}}`,
+	)
+	session := NewSession(decode(t, lines))
+	spanFor := func(level, id string) Span {
+		session.Redaction = level
+		for _, call := range session.Calls {
+			if call.ID() == id {
+				return session.toolSpan(call, "")
+			}
+		}
+		t.Fatalf("no tool call %s", id)
+		return Span{}
+	}
+
+	tests := []struct {
+		level, id, key, want string
+	}{
+		{config.RedactNone, "toolu_curl", "claude_analysis.tool.command", "TOKEN=secret curl https://api.example.com/v1?key=abc"},
+		{config.RedactContent, "toolu_curl", "claude_analysis.tool.command", "curl"},
+		{config.RedactStrict, "toolu_curl", "claude_analysis.tool.command", ""},
+		{config.RedactNone, "toolu_fetch", "claude_analysis.tool.url", "https://docs.example.com/private/page?token=abc"},
+		{config.RedactContent, "toolu_fetch", "claude_analysis.tool.url", "https://docs.example.com"},
+		{config.RedactStrict, "toolu_fetch", "claude_analysis.tool.url", ""},
+		{config.RedactContent, "toolu_edit", "claude_analysis.tool.file_path", "/work/api/main.go"},
+	}
+	for _, tt := range tests {
+		if got := attribute(spanFor(tt.level, tt.id).Attributes, tt.key); got != tt.want {
+			t.Errorf("%s %s: %s = %q, want %q", tt.level, tt.id, tt.key, got, tt.want)
+		}
+	}
+	if path := attribute(spanFor(config.RedactStrict, "toolu_edit").Attributes, "claude_analysis.tool.file_path"); !strings.HasPrefix(path, "sha256:") || !strings.HasSuffix(path, ".go") {
+		t.Errorf("strict redaction should hash the file path, got %q", path)
+	}
+	// 工具的錯誤輸出只在不脫敏時匯出
+	if bash := spanFor(config.RedactContent, "toolu_bash"); bash.Status == nil || bash.Status.Message != "" {
+		t.Errorf("error message should be dropped when redacting: %+v", bash.Status)
+	}
+	if bash := spanFor(config.RedactNone, "toolu_bash"); bash.Status == nil || bash.Status.Message != "make: *** error" {
+		t.Errorf("error message should be kept without redaction: %+v", bash.Status)
+	}
+	session.Redaction = config.RedactStrict
+	if folder := attribute(session.RootSpan().Attributes, "claude_analysis.folder_path"); !strings.HasPrefix(folder, "sha256:") {
+		t.Errorf("strict redaction should hash the folder path, got %q", folder)
+	}
+}
+
+func TestNewExporter(t *testing.T) {
+	cfg := testConfig(t, "http://collector:4318")
+	cfg.OTLP.MetricsEndpoint = "http://metrics:9090/otlp"
+	exporter, err := NewExporter(cfg)
+	if err != nil {
+		t.Fatalf("NewExporter error: %v", err)
+	}
+	if exporter.TracesURL != "http://collector:4318/v1/traces" || exporter.MetricsURL != "http://metrics:9090/otlp" {
+		t.Errorf("unexpected URLs: %s / %s", exporter.TracesURL, exporter.MetricsURL)
+	}
+
+	cfg.OTLP.Protocol = "grpc"
+	if _, err := NewExporter(cfg); err == nil {
+		t.Errorf("expected grpc protocol to be rejected")
+	}
+}
+
+func TestEnabledAndSupported(t *testing.T) {
+	cfg := testConfig(t, "http://collector:4318")
+	if !Enabled(cfg.OTLP) || !Supported(cfg) {
+		t.Fatalf("expected explicitly enabled http/json export to be used")
+	}
+	// Claude Code 本身的遙測也設定 OTEL_EXPORTER_OTLP_ENDPOINT，沒有明確啟用時不匯出
+	cfg.OTLP.Enabled = false
+	if Enabled(cfg.OTLP) {
+		t.Errorf("expected export to require an explicit opt-in")
+	}
+
+	cfg.OTLP.Protocol = "grpc"
+	marker := filepath.Join(StateDir(cfg.DataDir), "unsupported_protocol")
+	if Supported(cfg) {
+		t.Fatalf("expected grpc to be unsupported")
+	}
+	if data, err := os.ReadFile(marker); err != nil || string(data) != "grpc" {
+		t.Errorf("expected the warning to be remembered, got %q (%v)", data, err)
+	}
+	if Supported(cfg) {
+		t.Errorf("expected grpc to stay unsupported")
+	}
+}
+
+func TestExportSession_CollectorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	cfg := testConfig(t, server.URL)
+
+	err := ExportSession(cfg, decode(t, transcript), false)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected collector error, got %v", err)
+	}
+	// 匯出失敗時不記錄進度，下一次會重新匯出這些回合
+	if _, err := os.Stat(statePath(cfg.DataDir, "sess-1")); !os.IsNotExist(err) {
+		t.Errorf("state should not be saved when export fails")
+	}
+}
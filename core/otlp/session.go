@@ -0,0 +1,384 @@
+package otlp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/sink"
+	"claude_analysis/core/telemetry"
+)
+
+// maxAttributeLength 限制命令、錯誤訊息等屬性的長度
+const maxAttributeLength = 200
+
+// Session - 由 transcript 還原的工作階段結構
+type Session struct {
+	ID         string
+	CWD        string
+	GitBranch  string
+	Version    string // Claude Code 版本
+	Start, End time.Time
+	Turns      []*Turn
+	Calls      []*telemetry.ToolCall
+	Usage      map[string]telemetry.ClaudeCodeAnalysisTokenUsage // 按模型統計
+	Redaction  string                                            // span 屬性的脫敏等級（config.Redact*），空字串表示不脫敏
+}
+
+// Turn - 一次使用者輸入，直到下一次輸入之前的所有回應與工具呼叫
+type Turn struct {
+	Index        int
+	UUID         string
+	Start, End   time.Time
+	PromptLength int
+	Usage        map[string]telemetry.ClaudeCodeAnalysisTokenUsage
+	Calls        []*telemetry.ToolCall
+
+	logs []telemetry.ClaudeCodeLog
+}
+
+// NewSession 將 transcript 切分為回合，並把工具呼叫歸入發起它的回合
+func NewSession(logs []telemetry.ClaudeCodeLog) *Session {
+	session := &Session{}
+	var current *Turn
+	var sessionLogs []telemetry.ClaudeCodeLog
+	for _, entry := range logs {
+		if entry.Type == "summary" {
+			continue
+		}
+		sessionLogs = append(sessionLogs, entry)
+		if entry.SessionID != "" {
+			session.ID = entry.SessionID
+		}
+		if session.CWD == "" {
+			session.CWD = entry.CWD
+		}
+		if entry.GitBranch != "" {
+			session.GitBranch = entry.GitBranch
+		}
+		if entry.Version != "" {
+			session.Version = entry.Version
+		}
+
+		ts := parseTime(entry.Timestamp)
+		session.extend(ts)
+		if length, ok := promptLength(entry); ok {
+			current = &Turn{Index: len(session.Turns), UUID: entry.UUID, Start: ts, End: ts, PromptLength: length}
+			session.Turns = append(session.Turns, current)
+		}
+		if current != nil {
+			current.logs = append(current.logs, entry)
+			current.extend(ts)
+		}
+	}
+
+	session.Usage = telemetry.TokenUsageByModel(sessionLogs)
+	for _, turn := range session.Turns {
+		turn.Usage = telemetry.TokenUsageByModel(turn.logs)
+	}
+	for _, call := range telemetry.LinkToolCalls(sessionLogs) {
+		if call.Use == nil {
+			continue
+		}
+		session.Calls = append(session.Calls, call)
+		if turn := session.turnAt(call.Timestamp); turn != nil {
+			turn.Calls = append(turn.Calls, call)
+			turn.extend(call.ResultTimestamp)
+		}
+	}
+	return session
+}
+
+// promptLength 判斷是否為使用者輸入（而非工具結果或自動插入的訊息），並返回輸入長度
+func promptLength(entry telemetry.ClaudeCodeLog) (int, bool) {
+	if entry.Type != "user" || entry.IsSidechain || entry.IsMeta || entry.Message == nil {
+		return 0, false
+	}
+	length, isPrompt := 0, false
+	for _, block := range entry.Message.Content {
+		switch {
+		case block.ToolResult != nil:
+			return 0, false
+		case block.Text != nil:
+			length += utf8.RuneCountInString(block.Text.Text)
+			isPrompt = true
+		case block.Image != nil:
+			isPrompt = true
+		}
+	}
+	return length, isPrompt
+}
+
+// turnAt 返回 ts 所在的回合；第一次輸入之前的呼叫不屬於任何回合
+func (s *Session) turnAt(ts time.Time) *Turn {
+	if ts.IsZero() {
+		return nil
+	}
+	i := sort.Search(len(s.Turns), func(i int) bool { return s.Turns[i].Start.After(ts) })
+	if i == 0 {
+		return nil
+	}
+	return s.Turns[i-1]
+}
+
+func (s *Session) extend(ts time.Time) {
+	if ts.IsZero() {
+		return
+	}
+	if s.Start.IsZero() || ts.Before(s.Start) {
+		s.Start = ts
+	}
+	if ts.After(s.End) {
+		s.End = ts
+	}
+}
+
+func (t *Turn) extend(ts time.Time) {
+	if ts.After(t.End) {
+		t.End = ts
+	}
+}
+
+// TraceID 由 session id 推導，同一工作階段多次匯出時屬於同一個 trace
+func (s *Session) TraceID() string {
+	return hashID("trace:"+s.ID, 16)
+}
+
+func (s *Session) rootSpanID() string {
+	return hashID("session:"+s.ID, 8)
+}
+
+// RootSpan 返回代表整個工作階段的 span
+func (s *Session) RootSpan() Span {
+	return Span{
+		TraceID:           s.TraceID(),
+		SpanID:            s.rootSpanID(),
+		Name:              "claude_analysis.session",
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: unixNano(s.Start),
+		EndTimeUnixNano:   unixNano(s.End),
+		Attributes: append([]KeyValue{
+			String("session.id", s.ID),
+			String("claude_analysis.claude_code_version", s.Version),
+			String("claude_analysis.folder_path", sink.RedactValue("cwd", s.CWD, s.Redaction)),
+			String("claude_analysis.git_branch", s.GitBranch),
+			Int("claude_analysis.turns", int64(len(s.Turns))),
+			Int("claude_analysis.tool_calls", int64(len(s.Calls))),
+		}, usageAttributes(s.Usage)...),
+	}
+}
+
+// TurnSpans 返回從第 from 個回合開始的回合 span 及其工具呼叫 span
+func (s *Session) TurnSpans(from int) []Span {
+	var spans []Span
+	for _, turn := range s.Turns[min(from, len(s.Turns)):] {
+		spanID := hashID("turn:"+s.ID+":"+turn.UUID, 8)
+		attributes := []KeyValue{
+			Int("claude_analysis.turn.index", int64(turn.Index)),
+			Int("claude_analysis.prompt.length", int64(turn.PromptLength)),
+			Int("claude_analysis.tool_calls", int64(len(turn.Calls))),
+		}
+		if model := mainModel(turn.Usage); model != "" {
+			attributes = append(attributes, String("gen_ai.request.model", model))
+		}
+		attributes = append(attributes, usageAttributes(turn.Usage)...)
+		spans = append(spans, Span{
+			TraceID:           s.TraceID(),
+			SpanID:            spanID,
+			ParentSpanID:      s.rootSpanID(),
+			Name:              "claude_analysis.turn",
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(turn.Start),
+			EndTimeUnixNano:   unixNano(turn.End),
+			Attributes:        attributes,
+		})
+		for _, call := range turn.Calls {
+			spans = append(spans, s.toolSpan(call, spanID))
+		}
+	}
+	return spans
+}
+
+// toolSpan 返回一次工具呼叫的 span，屬性名稱參照 GenAI semantic conventions
+func (s *Session) toolSpan(call *telemetry.ToolCall, parentID string) Span {
+	end := call.ResultTimestamp
+	if end.IsZero() || end.Before(call.Timestamp) {
+		end = call.Timestamp
+	}
+	attributes := []KeyValue{
+		String("gen_ai.operation.name", "execute_tool"),
+		String("gen_ai.tool.name", call.Name()),
+		String("gen_ai.tool.call.id", call.ID()),
+	}
+	for _, field := range []struct{ input, attribute string }{
+		{"file_path", "claude_analysis.tool.file_path"},
+		{"notebook_path", "claude_analysis.tool.file_path"},
+		{"command", "claude_analysis.tool.command"},
+		{"url", "claude_analysis.tool.url"},
+		{"pattern", "claude_analysis.tool.pattern"},
+	} {
+		if value := sink.RedactValue(field.input, call.Use.InputString(field.input), s.Redaction); value != "" {
+			attributes = append(attributes, String(field.attribute, truncate(value)))
+		}
+	}
+
+	span := Span{
+		TraceID:           s.TraceID(),
+		SpanID:            hashID("tool:"+s.ID+":"+call.ID(), 8),
+		ParentSpanID:      parentID,
+		Name:              "claude_analysis.tool_call",
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: unixNano(call.Timestamp),
+		EndTimeUnixNano:   unixNano(end),
+		Attributes:        attributes,
+	}
+	if call.IsError() {
+		span.Status = &Status{Code: statusCodeError}
+		// 錯誤訊息是工具輸出，可能包含檔案內容或命令參數，脫敏時不匯出
+		if s.Redaction == "" || s.Redaction == config.RedactNone {
+			span.Status.Message = truncate(call.Result.Content.String())
+		}
+	}
+	return span
+}
+
+// Metrics 返回工作階段的累計指標；以工作階段開始時間為起點，重複匯出時數值只會增加
+func (s *Session) Metrics() []Metric {
+	start, end := unixNano(s.Start), unixNano(s.End)
+	point := func(value int, attributes ...KeyValue) NumberDataPoint {
+		return NumberDataPoint{
+			Attributes:        append([]KeyValue{String("session.id", s.ID)}, attributes...),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      end,
+			AsInt:             strconv.Itoa(value),
+		}
+	}
+	counter := func(name, description, unit string, points []NumberDataPoint) Metric {
+		return Metric{Name: name, Description: description, Unit: unit, Sum: &Sum{
+			DataPoints:             points,
+			AggregationTemporality: temporalityCumulative,
+			IsMonotonic:            true,
+		}}
+	}
+
+	var tokens []NumberDataPoint
+	for _, model := range sortedKeys(s.Usage) {
+		usage := s.Usage[model]
+		for _, kind := range []struct {
+			name  string
+			value int
+		}{
+			{"input", usage.InputTokens},
+			{"output", usage.OutputTokens},
+			{"cacheRead", usage.CacheReadInputTokens},
+			{"cacheCreation", usage.CacheCreationInputTokens},
+		} {
+			tokens = append(tokens, point(kind.value, String("model", model), String("type", kind.name)))
+		}
+	}
+
+	type toolKey struct {
+		name    string
+		success bool
+	}
+	toolCounts := make(map[toolKey]int)
+	added, removed := 0, 0
+	for _, call := range s.Calls {
+		toolCounts[toolKey{call.Name(), !call.IsError()}]++
+		if output := call.Output; output != nil {
+			if output.Type == "create" {
+				added += telemetry.CountLines(output.Content)
+			}
+			if output.IsEdit() {
+				added += telemetry.CountLines(output.NewString)
+				removed += telemetry.CountLines(output.OldString)
+			}
+		}
+	}
+	keys := make([]toolKey, 0, len(toolCounts))
+	for key := range toolCounts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].success
+	})
+	var tools []NumberDataPoint
+	for _, key := range keys {
+		tools = append(tools, point(toolCounts[key], String("tool_name", key.name), Bool("success", key.success)))
+	}
+
+	return []Metric{
+		counter("claude_analysis.session.count", "Number of sessions analyzed by claude_analysis", "{session}", []NumberDataPoint{point(1)}),
+		counter("claude_analysis.token.usage", "Tokens used, by model and token type", "{token}", tokens),
+		counter("claude_analysis.tool_call.count", "Tool calls, by tool and outcome", "{call}", tools),
+		counter("claude_analysis.lines_of_code.count", "Lines written or edited by Claude", "{line}", []NumberDataPoint{
+			point(added, String("type", "added")),
+			point(removed, String("type", "removed")),
+		}),
+	}
+}
+
+// mainModel 返回回合中訊息最多的模型
+func mainModel(usage map[string]telemetry.ClaudeCodeAnalysisTokenUsage) string {
+	best := ""
+	for _, model := range sortedKeys(usage) {
+		if best == "" || usage[model].Messages > usage[best].Messages {
+			best = model
+		}
+	}
+	return best
+}
+
+// usageAttributes 返回所有模型合計的 token 用量屬性
+func usageAttributes(usage map[string]telemetry.ClaudeCodeAnalysisTokenUsage) []KeyValue {
+	var total telemetry.ClaudeCodeAnalysisTokenUsage
+	for _, u := range usage {
+		total.InputTokens += u.InputTokens
+		total.OutputTokens += u.OutputTokens
+		total.CacheReadInputTokens += u.CacheReadInputTokens
+		total.CacheCreationInputTokens += u.CacheCreationInputTokens
+	}
+	return []KeyValue{
+		Int("gen_ai.usage.input_tokens", int64(total.InputTokens)),
+		Int("gen_ai.usage.output_tokens", int64(total.OutputTokens)),
+		Int("claude_analysis.usage.cache_read_input_tokens", int64(total.CacheReadInputTokens)),
+		Int("claude_analysis.usage.cache_creation_input_tokens", int64(total.CacheCreationInputTokens)),
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hashID 以雜湊產生固定長度的十六進位 ID，同一輸入總是得到相同 ID
+func hashID(seed string, size int) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:size])
+}
+
+func parseTime(ts string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func truncate(s string) string {
+	if utf8.RuneCountInString(s) <= maxAttributeLength {
+		return s
+	}
+	return string([]rune(s)[:maxAttributeLength]) + "..."
+}
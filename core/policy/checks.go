@@ -0,0 +1,188 @@
+package policy
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// commandSeparator 切分串接的 shell 命令（;、&&、||、|、換行）
+var commandSeparator = regexp.MustCompile(`[;&|\n]+`)
+
+// forcePushesProtected 判斷命令中是否有強制推送到受保護分支的 git push
+func forcePushesProtected(command, cwd string, protected []string) bool {
+	for _, segment := range commandSeparator.Split(command, -1) {
+		args, ok := gitPushArgs(strings.Fields(segment))
+		if !ok {
+			continue
+		}
+		force, branches := parsePushArgs(args)
+		if !force {
+			continue
+		}
+		for _, branch := range branches {
+			if branch == "" {
+				branch = currentBranch(cwd)
+			}
+			// 無法判斷目前分支時從嚴處理
+			if branch == "" || isProtected(branch, protected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gitPushArgs 若命令為 git push，返回 push 之後的參數
+func gitPushArgs(fields []string) ([]string, bool) {
+	for i := 0; i < len(fields); i++ {
+		if path.Base(unquote(fields[i])) != "git" {
+			continue
+		}
+		// 略過 git 的全域選項，例如 git -C dir push
+		for j := i + 1; j < len(fields); j++ {
+			arg := unquote(fields[j])
+			switch {
+			case arg == "-C" || arg == "-c":
+				j++
+			case strings.HasPrefix(arg, "-"):
+			case arg == "push":
+				return fields[j+1:], true
+			default:
+				j = len(fields)
+			}
+		}
+	}
+	return nil, false
+}
+
+// parsePushArgs 解析 git push 參數，返回是否強制推送及目標分支
+// 目標分支為空字串代表目前分支
+func parsePushArgs(args []string) (bool, []string) {
+	force, all := false, false
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := unquote(args[i])
+		switch {
+		case strings.HasPrefix(arg, "--force"):
+			force = true
+		case arg == "--all" || arg == "--mirror":
+			all = true
+		case arg == "-o" || arg == "--push-option" || arg == "--repo":
+			i++
+		case strings.HasPrefix(arg, "--"):
+		case strings.HasPrefix(arg, "-"):
+			if strings.Contains(arg, "f") {
+				force = true
+			}
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if all {
+		// --all/--mirror 會推送所有分支，視為包含受保護分支
+		return force, []string{"*"}
+	}
+	if len(positional) <= 1 {
+		return force, []string{""}
+	}
+	var branches []string
+	for _, refspec := range positional[1:] {
+		if strings.HasPrefix(refspec, "+") {
+			force = true
+			refspec = refspec[1:]
+		}
+		if idx := strings.LastIndex(refspec, ":"); idx >= 0 {
+			refspec = refspec[idx+1:]
+		}
+		branch := strings.TrimPrefix(refspec, "refs/heads/")
+		if branch == "HEAD" {
+			branch = ""
+		}
+		branches = append(branches, branch)
+	}
+	return force, branches
+}
+
+func isProtected(branch string, protected []string) bool {
+	if branch == "*" {
+		return true
+	}
+	for _, pattern := range protected {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func unquote(s string) string {
+	return strings.Trim(s, `"'`)
+}
+
+// currentBranch 讀取 cwd 所在 repo 的目前分支，detached HEAD 或非 git repo 時返回空字串
+func currentBranch(cwd string) string {
+	root := repoRoot(cwd)
+	if root == "" {
+		return ""
+	}
+	gitDir := filepath.Join(root, ".git")
+	// worktree/submodule 中 .git 是指向實際 git 目錄的檔案
+	if data, err := os.ReadFile(gitDir); err == nil {
+		if dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:"); ok {
+			gitDir = strings.TrimSpace(dir)
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(root, gitDir)
+			}
+		}
+	}
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+	if !ok {
+		return ""
+	}
+	return ref
+}
+
+// outsideRepo 判斷檔案路徑是否位於 cwd 所在 repo 之外；不在 git repo 中時以 cwd 為界
+func outsideRepo(file, cwd string) bool {
+	if cwd == "" {
+		return false
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(cwd, file)
+	}
+	root := repoRoot(cwd)
+	if root == "" {
+		root = cwd
+	}
+	rel, err := filepath.Rel(root, filepath.Clean(file))
+	if err != nil {
+		return true
+	}
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// repoRoot 向上尋找包含 .git 的目錄
+func repoRoot(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,216 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"unicode/utf8"
+
+	"claude_analysis/core/hook"
+)
+
+// 內建檢查，用於無法以單一正規表示式描述的規則
+const (
+	CheckForcePushProtected = "force_push_protected"
+	CheckOutsideRepo        = "outside_repo"
+)
+
+// maxTargetLength 限制記錄到事件日誌的命令/路徑長度
+const maxTargetLength = 200
+
+// defaultProtectedBranches 是未設定 protectedBranches 時受保護的分支
+var defaultProtectedBranches = []string{"main", "master"}
+
+// Rule - 一條宣告式規則，所有已設定的條件都符合時才生效
+type Rule struct {
+	ID       string   `json:"id"`
+	Tools    []string `json:"tools,omitempty"`   // 適用的工具名稱，支援 * 萬用字元；空表示所有工具
+	Command  string   `json:"command,omitempty"` // Bash 命令的正規表示式
+	Path     string   `json:"path,omitempty"`    // 檔案路徑的正規表示式
+	Check    string   `json:"check,omitempty"`   // 內建檢查：force_push_protected / outside_repo
+	Decision string   `json:"decision"`          // allow / deny / ask
+	Reason   string   `json:"reason,omitempty"`
+
+	command *regexp.Regexp
+	path    *regexp.Regexp
+}
+
+// Policy - 規則檔內容
+type Policy struct {
+	ProtectedBranches []string `json:"protectedBranches,omitempty"`
+	Rules             []Rule   `json:"rules"`
+}
+
+// Decision - 一次 PreToolUse 的策略決策
+type Decision struct {
+	RuleID   string
+	Decision string
+	Reason   string
+	Target   string // 觸發規則的命令或檔案路徑
+}
+
+// Load 讀取規則檔；檔案不存在時返回 nil，表示不做任何攔截
+func Load(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse 解析並驗證規則內容
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %w", err)
+	}
+	for i := range p.Rules {
+		if err := p.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, p.Rules[i].ID, err)
+		}
+	}
+	if len(p.ProtectedBranches) == 0 {
+		p.ProtectedBranches = defaultProtectedBranches
+	}
+	return &p, nil
+}
+
+func (r *Rule) compile() error {
+	switch r.Decision {
+	case hook.DecisionAllow, hook.DecisionDeny, hook.DecisionAsk:
+	default:
+		return fmt.Errorf("invalid decision %q", r.Decision)
+	}
+	switch r.Check {
+	case "", CheckForcePushProtected, CheckOutsideRepo:
+	default:
+		return fmt.Errorf("unknown check %q", r.Check)
+	}
+	var err error
+	if r.Command != "" {
+		if r.command, err = regexp.Compile(r.Command); err != nil {
+			return fmt.Errorf("invalid command pattern: %w", err)
+		}
+	}
+	if r.Path != "" {
+		if r.path, err = regexp.Compile(r.Path); err != nil {
+			return fmt.Errorf("invalid path pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// Evaluate 對一次工具呼叫套用所有規則，取最嚴格的決策（deny > ask > allow）
+// 沒有規則符合時返回 nil，交由 Claude Code 原本的權限流程處理
+func (p *Policy) Evaluate(input *hook.Input) *Decision {
+	if p == nil {
+		return nil
+	}
+	call := newToolCall(input)
+
+	var result *Decision
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		target, ok := p.match(rule, call)
+		if !ok {
+			continue
+		}
+		if result == nil || severity(rule.Decision) > severity(result.Decision) {
+			result = &Decision{
+				RuleID:   rule.ID,
+				Decision: rule.Decision,
+				Reason:   rule.Reason,
+				Target:   truncate(target, maxTargetLength),
+			}
+		}
+	}
+	if result != nil && result.Reason == "" {
+		result.Reason = fmt.Sprintf("blocked by policy rule %s", result.RuleID)
+	}
+	return result
+}
+
+// match 判斷規則是否符合，並返回觸發規則的命令或路徑
+func (p *Policy) match(rule *Rule, call *toolCall) (string, bool) {
+	if !matchTool(rule.Tools, call.tool) {
+		return "", false
+	}
+	target := call.command
+	if target == "" {
+		target = call.path
+	}
+	if rule.command != nil && (call.command == "" || !rule.command.MatchString(call.command)) {
+		return "", false
+	}
+	if rule.path != nil && (call.path == "" || !rule.path.MatchString(call.path)) {
+		return "", false
+	}
+	switch rule.Check {
+	case CheckForcePushProtected:
+		if call.command == "" || !forcePushesProtected(call.command, call.cwd, p.ProtectedBranches) {
+			return "", false
+		}
+	case CheckOutsideRepo:
+		if call.path == "" || !outsideRepo(call.path, call.cwd) {
+			return "", false
+		}
+	}
+	return target, true
+}
+
+func matchTool(patterns []string, tool string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func severity(decision string) int {
+	switch decision {
+	case hook.DecisionDeny:
+		return 2
+	case hook.DecisionAsk:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "..."
+}
+
+// toolCall - 規則比對所需的工具呼叫資訊
+type toolCall struct {
+	tool    string
+	command string // Bash 命令
+	path    string // 檔案工具的目標路徑
+	cwd     string
+}
+
+func newToolCall(input *hook.Input) *toolCall {
+	call := &toolCall{tool: input.ToolName, cwd: input.CWD}
+	if input.ToolName == "Bash" {
+		call.command = input.ToolInputString("command")
+	}
+	for _, key := range []string{"file_path", "notebook_path"} {
+		if p := input.ToolInputString(key); p != "" {
+			call.path = p
+			break
+		}
+	}
+	return call
+}
@@ -0,0 +1,248 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"claude_analysis/core/filelock"
+	"claude_analysis/core/telemetry"
+)
+
+const (
+	stateName        = "counters.json"
+	lockStaleAfter   = time.Minute
+	lockTimeout      = 5 * time.Second
+	sessionRetention = 30 * 24 * time.Hour
+)
+
+// 計數器名稱與說明
+var families = map[string]string{
+	"claude_code_sessions_total":   "Claude Code sessions analyzed",
+	"claude_code_tool_calls_total": "Claude Code tool calls, by tool",
+	"claude_code_tokens_total":     "Claude Code tokens, by model and token type",
+	"claude_code_lines_total":      "Lines written (Write) or diffed (Edit) by Claude Code",
+}
+
+// Counters - 以 series（例如 name{label="value"}）為 key 的計數器值
+type Counters map[string]float64
+
+// Dir 返回計數器狀態目錄
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "prometheus")
+}
+
+// Store - 保存在磁碟上、跨 hook 執行累加的計數器
+//
+// 同一工作階段每次 Stop 都會重新分析整個 transcript，因此記錄每個工作階段上次的貢獻，只累加差額。
+// 工作階段超過 sessionRetention 未更新且 transcript 已不存在時才移除紀錄；
+// transcript 還在就可能被繼續（resume），移除後再分析會重複計入整個工作階段。
+type Store struct {
+	Dir string
+	// Transcripts 是 Claude Code 保存 transcript 的目錄（~/.claude/projects），其下每個專案目錄中有 <session id>.jsonl
+	Transcripts string
+	now         func() time.Time
+}
+
+// NewStore 建立保存在 dir 的計數器
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir, Transcripts: defaultTranscriptsDir(), now: time.Now}
+}
+
+func defaultTranscriptsDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".claude", "projects")
+}
+
+// state - 計數器檔案內容
+type state struct {
+	Totals   Counters                 `json:"totals"`
+	Sessions map[string]sessionCounts `json:"sessions"`
+}
+
+// sessionCounts - 單一工作階段目前為止的貢獻
+type sessionCounts struct {
+	Seen   time.Time `json:"seen"`
+	Values Counters  `json:"values"`
+}
+
+// Update 將分析結果累加到計數器並返回更新後的總計
+func (s *Store) Update(analysis *telemetry.ClaudeCodeAnalysis) (Counters, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create counters dir: %w", err)
+	}
+	// 多個工作階段可能同時結束，讀改寫期間持有鎖
+	lock, err := filelock.Acquire(filepath.Join(s.Dir, ".lock"), lockStaleAfter, lockTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock counters: %w", err)
+	}
+	defer lock.Release()
+
+	st, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	for _, record := range analysis.Records {
+		values := RecordCounters(analysis.User, &record)
+		previous := st.Sessions[record.TaskID]
+		for series, value := range values {
+			// 計數器只增不減：transcript 被截斷時保留之前較大的值
+			if delta := value - previous.Values[series]; delta > 0 {
+				st.Totals[series] += delta
+			} else {
+				values[series] = previous.Values[series]
+			}
+		}
+		// 這次為 0 而沒有出現的 series 同樣保留之前的值，之後再出現時只累加差額
+		for series, value := range previous.Values {
+			if _, ok := values[series]; !ok {
+				values[series] = value
+			}
+		}
+		st.Sessions[record.TaskID] = sessionCounts{Seen: now, Values: values}
+	}
+	s.prune(st, now)
+	if err := s.save(st); err != nil {
+		return nil, err
+	}
+	return st.Totals, nil
+}
+
+// prune 移除很久沒有更新、transcript 也已刪除的工作階段，總計保持不變
+func (s *Store) prune(st *state, now time.Time) {
+	var transcripts map[string]bool
+	for id, session := range st.Sessions {
+		if now.Sub(session.Seen) <= sessionRetention {
+			continue
+		}
+		if transcripts == nil {
+			transcripts = s.transcriptIDs()
+		}
+		if !transcripts[id] {
+			delete(st.Sessions, id)
+		}
+	}
+}
+
+// transcriptIDs 返回 Transcripts 下仍存在的 transcript 的工作階段 ID
+func (s *Store) transcriptIDs() map[string]bool {
+	ids := map[string]bool{}
+	if s.Transcripts == "" {
+		return ids
+	}
+	paths, _ := filepath.Glob(filepath.Join(s.Transcripts, "*", "*.jsonl"))
+	for _, path := range paths {
+		ids[strings.TrimSuffix(filepath.Base(path), ".jsonl")] = true
+	}
+	return ids
+}
+
+// Load 返回目前的總計
+func (s *Store) Load() (Counters, error) {
+	st, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return st.Totals, nil
+}
+
+func (s *Store) load() (*state, error) {
+	st := &state{Totals: Counters{}, Sessions: map[string]sessionCounts{}}
+	data, err := os.ReadFile(filepath.Join(s.Dir, stateName))
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read counters: %w", err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse counters: %w", err)
+	}
+	if st.Totals == nil {
+		st.Totals = Counters{}
+	}
+	if st.Sessions == nil {
+		st.Sessions = map[string]sessionCounts{}
+	}
+	return st, nil
+}
+
+func (s *Store) save(st *state) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to marshal counters: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(s.Dir, stateName), data)
+}
+
+// RecordCounters 返回單一工作階段記錄對各計數器的貢獻，以 repo 與 user 為標籤
+func RecordCounters(user string, record *telemetry.ClaudeCodeAnalysisRecord) Counters {
+	base := []string{"repo", telemetry.RepoName(record), "user", user}
+	counters := Counters{}
+	add := func(name string, value float64, labels ...string) {
+		if value > 0 {
+			counters[series(name, append(append([]string{}, base...), labels...)...)] += value
+		}
+	}
+
+	add("claude_code_sessions_total", 1)
+
+	calls := record.ToolCallCounts
+	for tool, count := range map[string]int{
+		"Read": calls.Read, "Write": calls.Write, "Edit": calls.Edit, "TodoWrite": calls.TodoWrite,
+		"Bash": calls.Bash, "WebFetch": calls.WebFetch, "WebSearch": calls.WebSearch,
+	} {
+		add("claude_code_tool_calls_total", float64(count), "tool", tool)
+	}
+
+	for model, usage := range record.TokenUsage {
+		add("claude_code_tokens_total", float64(usage.InputTokens), "model", model, "type", "input")
+		add("claude_code_tokens_total", float64(usage.OutputTokens), "model", model, "type", "output")
+		add("claude_code_tokens_total", float64(usage.CacheReadInputTokens), "model", model, "type", "cache_read")
+		add("claude_code_tokens_total", float64(usage.CacheCreationInputTokens), "model", model, "type", "cache_creation")
+	}
+
+	diffed := 0
+	for _, detail := range record.ApplyDiffDetails {
+		diffed += detail.LineCount
+	}
+	add("claude_code_lines_total", float64(record.TotalWriteLines), "kind", "written")
+	add("claude_code_lines_total", float64(diffed), "kind", "diffed")
+	return counters
+}
+
+// series 以排序後的標籤組成 series key，格式與 exposition format 相同
+func series(name string, labels ...string) string {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], escapeLabel(labels[i+1])))
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabel 依 exposition format 跳脫標籤值中的反斜線、雙引號與換行
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// writeFileAtomic 以暫存檔加 rename 寫入，讀取端（例如 node_exporter）不會看到寫到一半的檔案
+func writeFileAtomic(path string, data []byte) error {
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
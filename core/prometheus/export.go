@@ -0,0 +1,79 @@
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TextfileName 是寫入 textfile collector 目錄的檔名，node_exporter 只讀取 *.prom
+const TextfileName = "claude_analysis.prom"
+
+// contentType 是 text exposition format 的 Content-Type
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// WriteText 以 Prometheus text exposition format 輸出計數器，依名稱排序
+func WriteText(w io.Writer, counters Counters) error {
+	keys := make([]string, 0, len(counters))
+	for key := range counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	family := ""
+	for _, key := range keys {
+		name := key
+		if i := strings.IndexByte(key, '{'); i >= 0 {
+			name = key[:i]
+		}
+		if name != family {
+			family = name
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", name, families[name], name)
+		}
+		fmt.Fprintf(&b, "%s %s\n", key, strconv.FormatFloat(counters[key], 'f', -1, 64))
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteTextfile 將計數器寫入 node_exporter textfile collector 目錄
+func WriteTextfile(dir string, counters Counters) error {
+	var b bytes.Buffer
+	if err := WriteText(&b, counters); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, TextfileName), b.Bytes())
+}
+
+// Push 以 PUT 取代 Pushgateway 上 job/instance 分組的所有計數器
+func Push(client *http.Client, gatewayURL, job, instance string, counters Counters) error {
+	var b bytes.Buffer
+	if err := WriteText(&b, counters); err != nil {
+		return err
+	}
+	target := fmt.Sprintf("%s/metrics/job/%s/instance/%s",
+		strings.TrimSuffix(gatewayURL, "/"), url.PathEscape(job), url.PathEscape(instance))
+	req, err := http.NewRequest(http.MethodPut, target, &b)
+	if err != nil {
+		return fmt.Errorf("failed to create Pushgateway request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push to Pushgateway: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Pushgateway returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package report
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// html 目錄下的樣板、樣式與腳本編譯進執行檔，輸出的 HTML 不引用任何外部資源
+//
+//go:embed html/report.html.tmpl html/report.css html/report.js
+var htmlAssets embed.FS
+
+// Page - HTML 報表中報表資料以外的資訊
+type Page struct {
+	Title       string
+	GeneratedAt time.Time
+	Transcripts int
+	Failed      int // 無法分析的 transcript 數量
+	Version     string
+}
+
+// pageMeta 是嵌入頁面的 meta 資料
+type pageMeta struct {
+	Title       string `json:"title"`
+	GeneratedAt string `json:"generatedAt"`
+	Transcripts int    `json:"transcripts"`
+	Failed      int    `json:"failed"`
+	Version     string `json:"version"`
+}
+
+// WriteHTML 輸出單一檔案的 HTML 報表，CSS、JS 與資料都內嵌在頁面中，可以離線開啟
+//
+// 報表應以不截斷的 Options 建立，頁面自行決定各圖表顯示的數量
+func WriteHTML(w io.Writer, r *Report, page Page) error {
+	tmpl, err := template.ParseFS(htmlAssets, "html/report.html.tmpl")
+	if err != nil {
+		return fmt.Errorf("failed to parse report template: %w", err)
+	}
+	css, err := htmlAssets.ReadFile("html/report.css")
+	if err != nil {
+		return err
+	}
+	script, err := htmlAssets.ReadFile("html/report.js")
+	if err != nil {
+		return err
+	}
+	// json.Marshal 會跳脫 <、> 與 &，標題中的 </script> 無法結束資料區塊
+	data, err := json.Marshal(struct {
+		Meta   pageMeta `json:"meta"`
+		Report *Report  `json:"report"`
+	}{
+		Meta: pageMeta{
+			Title:       page.Title,
+			GeneratedAt: page.GeneratedAt.Format("2006-01-02 15:04"),
+			Transcripts: page.Transcripts,
+			Failed:      page.Failed,
+			Version:     page.Version,
+		},
+		Report: r,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	return tmpl.Execute(w, map[string]interface{}{
+		"Title":   page.Title,
+		"Version": page.Version,
+		"CSS":     template.CSS(css),
+		"Script":  template.JS(script),
+		"Data":    template.JS(data),
+	})
+}
@@ -0,0 +1,432 @@
+// Renders the claude_analysis HTML report from the JSON embedded in #report-data.
+// Everything is drawn with plain DOM and SVG so the file works offline.
+(function () {
+  'use strict';
+
+  const data = JSON.parse(document.getElementById('report-data').textContent);
+  const meta = data.meta;
+  const report = data.report;
+  const SVG = 'http://www.w3.org/2000/svg';
+
+  // ---- helpers ----
+
+  function el(tag, attrs, children) {
+    const node = document.createElement(tag);
+    setAttrs(node, attrs);
+    append(node, children);
+    return node;
+  }
+
+  function svg(tag, attrs, children) {
+    const node = document.createElementNS(SVG, tag);
+    setAttrs(node, attrs);
+    append(node, children);
+    return node;
+  }
+
+  function setAttrs(node, attrs) {
+    Object.keys(attrs || {}).forEach(function (key) {
+      const value = attrs[key];
+      if (value === undefined || value === null || value === false) return;
+      if (key === 'text') node.textContent = value;
+      else if (key === 'onclick') node.addEventListener('click', value);
+      else node.setAttribute(key, value);
+    });
+  }
+
+  function append(node, children) {
+    [].concat(children || []).forEach(function (child) {
+      if (child === null || child === undefined) return;
+      node.appendChild(typeof child === 'string' ? document.createTextNode(child) : child);
+    });
+  }
+
+  function clear(node) {
+    while (node.firstChild) node.removeChild(node.firstChild);
+    return node;
+  }
+
+  function fmtInt(n) {
+    return Math.round(n || 0).toLocaleString('en-US');
+  }
+
+  function fmtTokens(n) {
+    n = n || 0;
+    if (n >= 1e9) return (n / 1e9).toFixed(1) + 'B';
+    if (n >= 1e6) return (n / 1e6).toFixed(1) + 'M';
+    if (n >= 1e3) return (n / 1e3).toFixed(1) + 'k';
+    return String(n);
+  }
+
+  function fmtCost(n) {
+    return '$' + (n || 0).toFixed(2);
+  }
+
+  function pad(n) {
+    return n < 10 ? '0' + n : String(n);
+  }
+
+  function dayKey(date) {
+    return date.getFullYear() + '-' + pad(date.getMonth() + 1) + '-' + pad(date.getDate());
+  }
+
+  function fmtTime(ts) {
+    if (!ts) return '-';
+    const date = new Date(ts * 1000);
+    return dayKey(date) + ' ' + pad(date.getHours()) + ':' + pad(date.getMinutes());
+  }
+
+  function parseDay(day) {
+    const parts = day.split('-').map(Number);
+    return new Date(parts[0], parts[1] - 1, parts[2]);
+  }
+
+  function sum(list, fn) {
+    return list.reduce(function (total, item) { return total + fn(item); }, 0);
+  }
+
+  function niceMax(value) {
+    if (value <= 0) return 1;
+    const magnitude = Math.pow(10, Math.floor(Math.log10(value)));
+    const steps = [1, 2, 2.5, 5, 10];
+    for (let i = 0; i < steps.length; i++) {
+      if (steps[i] * magnitude >= value) return steps[i] * magnitude;
+    }
+    return 10 * magnitude;
+  }
+
+  // ---- state shared by the charts and the session list ----
+
+  const state = { day: null, repo: null, search: '', sort: 'timestamp', asc: false, open: {} };
+
+  function setFilter(key, value) {
+    state[key] = state[key] === value ? null : value;
+    renderTimeline();
+    renderRepos();
+    renderSessions();
+    if (state[key]) document.getElementById('sessions-section').scrollIntoView({ behavior: 'smooth' });
+  }
+
+  // ---- header and cards ----
+
+  function renderHeader() {
+    const parts = [fmtInt(report.sessions) + ' sessions from ' + fmtInt(meta.transcripts) + ' transcripts'];
+    if (report.firstActivity) parts.push(fmtTime(report.firstActivity).slice(0, 10) + ' to ' + fmtTime(report.lastActivity).slice(0, 10));
+    if (meta.failed) parts.push(fmtInt(meta.failed) + ' transcripts could not be analyzed');
+    parts.push('generated ' + meta.generatedAt);
+    document.getElementById('meta').textContent = parts.join(' · ');
+
+    const tokens = report.tokens;
+    const totalTokens = tokens.inputTokens + tokens.outputTokens + tokens.cacheWriteTokens + tokens.cacheReadTokens;
+    const cards = [
+      ['Sessions', fmtInt(report.sessions)],
+      ['Active days', fmtInt((report.days || []).length)],
+      ['Tool calls', fmtInt(report.toolCalls)],
+      ['Tokens', fmtTokens(totalTokens)],
+      ['Est. cost', fmtCost(tokens.cost)],
+      ['Lines written', fmtInt(report.changes.linesWritten)],
+      ['Lines diffed', fmtInt(report.changes.linesDiffed)],
+      ['Files changed', fmtInt(report.changes.filesChanged)],
+    ];
+    append(clear(document.getElementById('cards')), cards.map(function (card) {
+      return el('div', { class: 'card' }, [el('div', { class: 'value', text: card[1] }), el('div', { class: 'label', text: card[0] })]);
+    }));
+  }
+
+  // ---- activity timeline ----
+
+  const metrics = [
+    { key: 'sessions', label: 'Sessions', value: function (d) { return d.sessions; }, fmt: fmtInt },
+    { key: 'toolCalls', label: 'Tool calls', value: function (d) { return d.toolCalls; }, fmt: fmtInt },
+    { key: 'lines', label: 'Lines changed', value: function (d) { return d.linesWritten + d.linesDiffed; }, fmt: fmtInt },
+    { key: 'cost', label: 'Est. cost', value: function (d) { return d.cost; }, fmt: fmtCost },
+  ];
+  let metric = metrics[0];
+
+  // timelineDays fills the days without sessions so gaps are visible
+  function timelineDays() {
+    const byDay = {};
+    (report.days || []).forEach(function (d) { byDay[d.day] = d; });
+    if (!report.days || report.days.length === 0) return [];
+    const days = [];
+    const last = parseDay(report.days[report.days.length - 1].day);
+    for (let date = parseDay(report.days[0].day); date <= last; date.setDate(date.getDate() + 1)) {
+      const key = dayKey(date);
+      days.push(byDay[key] || { day: key, sessions: 0, toolCalls: 0, linesWritten: 0, linesDiffed: 0, cost: 0 });
+    }
+    return days;
+  }
+
+  function renderMetricToggle() {
+    append(clear(document.getElementById('timeline-metrics')), metrics.map(function (m) {
+      return el('button', {
+        type: 'button',
+        class: m === metric ? 'active' : null,
+        text: m.label,
+        onclick: function () { metric = m; renderMetricToggle(); renderTimeline(); },
+      });
+    }));
+  }
+
+  function renderTimeline() {
+    const container = clear(document.getElementById('timeline'));
+    const days = timelineDays();
+    if (days.length === 0) {
+      append(container, el('p', { class: 'empty', text: 'No dated sessions.' }));
+      return;
+    }
+    const width = 960, height = 220, left = 48, bottom = 24, top = 8;
+    const plotWidth = width - left, plotHeight = height - top - bottom;
+    const max = niceMax(Math.max.apply(null, days.map(metric.value)));
+    const step = plotWidth / days.length;
+    const chart = svg('svg', { viewBox: '0 0 ' + width + ' ' + height, role: 'img', 'aria-label': metric.label + ' per day' });
+
+    for (let i = 0; i <= 4; i++) {
+      const y = top + plotHeight - (plotHeight * i) / 4;
+      append(chart, [
+        svg('line', { class: 'grid-line', x1: left, x2: width, y1: y, y2: y }),
+        svg('text', { x: left - 6, y: y + 4, 'text-anchor': 'end', text: metric.fmt((max * i) / 4) }),
+      ]);
+    }
+    const labelEvery = Math.max(1, Math.ceil(days.length / 12));
+    days.forEach(function (d, i) {
+      const value = metric.value(d);
+      const barHeight = (plotHeight * value) / max;
+      const x = left + i * step;
+      const group = svg('g', { class: value > 0 ? 'clickable' : null }, [
+        svg('title', { text: d.day + ': ' + metric.fmt(value) + ' (' + fmtInt(d.sessions) + ' sessions)' }),
+        // transparent column so thin bars are still easy to hover and click
+        svg('rect', { x: x, y: top, width: step, height: plotHeight, fill: 'transparent' }),
+        svg('rect', {
+          class: 'bar' + (state.day === d.day ? ' selected' : ''),
+          x: x + step * 0.1, y: top + plotHeight - barHeight, width: Math.max(1, step * 0.8), height: barHeight,
+        }),
+      ]);
+      if (value > 0) group.addEventListener('click', function () { setFilter('day', d.day); });
+      append(chart, group);
+      if (i % labelEvery === 0) {
+        append(chart, svg('text', { x: x + step / 2, y: height - 6, 'text-anchor': 'middle', text: d.day.slice(5) }));
+      }
+    });
+    append(container, chart);
+  }
+
+  // ---- horizontal bar charts ----
+
+  function barChart(containerId, rows, valueLabel) {
+    const container = clear(document.getElementById(containerId));
+    if (rows.length === 0) {
+      append(container, el('p', { class: 'empty', text: 'No data.' }));
+      return;
+    }
+    const rowHeight = 22, labelWidth = 170, valueWidth = 120, width = 560;
+    const barWidth = width - labelWidth - valueWidth;
+    const max = Math.max.apply(null, rows.map(function (r) { return r.value; })) || 1;
+    const chart = svg('svg', { viewBox: '0 0 ' + width + ' ' + rows.length * rowHeight, role: 'img', 'aria-label': valueLabel });
+    rows.forEach(function (r, i) {
+      const y = i * rowHeight;
+      const label = r.label.length > 26 ? r.label.slice(0, 25) + '…' : r.label;
+      append(chart, svg('g', {}, [
+        svg('title', { text: r.label + ': ' + r.title }),
+        svg('text', { x: labelWidth - 8, y: y + 15, 'text-anchor': 'end', text: label }),
+        svg('rect', { class: 'bar' + (r.alt ? ' alt' : ''), x: labelWidth, y: y + 4, height: rowHeight - 8, width: Math.max(1, (barWidth * r.value) / max), rx: 2 }),
+        svg('text', { x: labelWidth + barWidth + 8, y: y + 15, text: r.text }),
+      ]));
+    });
+    append(container, chart);
+  }
+
+  function renderTools() {
+    const tools = report.tools || [];
+    const shown = tools.slice(0, 12);
+    const rest = tools.slice(12);
+    const rows = shown.map(function (t) { return { label: t.tool, value: t.calls }; });
+    if (rest.length > 0) rows.push({ label: 'Other (' + rest.length + ' tools)', value: sum(rest, function (t) { return t.calls; }), alt: true });
+    rows.forEach(function (r) {
+      const share = report.toolCalls ? (100 * r.value) / report.toolCalls : 0;
+      r.text = fmtInt(r.value) + ' · ' + share.toFixed(1) + '%';
+      r.title = fmtInt(r.value) + ' calls';
+    });
+    barChart('tools', rows, 'Tool calls by tool');
+  }
+
+  function renderLanguages() {
+    const rows = (report.languages || []).slice(0, 12).map(function (l) {
+      const changes = l.writes + l.edits;
+      return {
+        label: l.language,
+        value: changes,
+        alt: l.language === 'Other',
+        text: fmtInt(changes) + ' changes',
+        title: fmtInt(l.files) + ' files, ' + fmtInt(l.writes) + ' writes, ' + fmtInt(l.edits) + ' edits, ' + fmtInt(l.lines) + ' lines',
+      };
+    });
+    barChart('languages', rows, 'File changes by language');
+  }
+
+  // ---- tables ----
+
+  function table(columns, rows, options) {
+    options = options || {};
+    const head = el('tr', {}, columns.map(function (c) {
+      const sortable = options.onSort && c.sort;
+      const classes = [c.num ? 'num' : '', sortable ? 'sortable' : '', options.sort === c.sort ? 'sorted' : '', options.sort === c.sort && options.asc ? 'asc' : ''];
+      return el('th', {
+        class: classes.join(' ').trim() || null,
+        text: c.label,
+        onclick: sortable ? function () { options.onSort(c.sort); } : null,
+      });
+    }));
+    const body = el('tbody');
+    rows.forEach(function (row) {
+      const tr = el('tr', { class: row.className || null }, columns.map(function (c) {
+        const cell = c.cell(row.item);
+        return el('td', { class: c.className || (c.num ? 'num' : null) }, cell);
+      }));
+      if (row.onclick) tr.addEventListener('click', row.onclick);
+      append(body, tr);
+      if (row.detail) append(body, row.detail);
+    });
+    return el('table', {}, [el('thead', {}, head), body]);
+  }
+
+  function renderRepos() {
+    const repos = report.repos || [];
+    const max = Math.max.apply(null, repos.map(function (r) { return r.sessions; }).concat([1]));
+    const columns = [
+      { label: 'Repository', cell: function (r) { return r.repo; } },
+      { label: '', className: 'inline-bar', cell: function (r) { return el('span', { style: 'width:' + (100 * r.sessions) / max + '%' }); } },
+      { label: 'Sessions', num: true, cell: function (r) { return fmtInt(r.sessions); } },
+      { label: 'Tool calls', num: true, cell: function (r) { return fmtInt(r.toolCalls); } },
+      { label: 'Written', num: true, cell: function (r) { return fmtInt(r.linesWritten); } },
+      { label: 'Diffed', num: true, cell: function (r) { return fmtInt(r.linesDiffed); } },
+      { label: 'Tokens', num: true, cell: function (r) { return fmtTokens(r.tokens); } },
+      { label: 'Est. cost', num: true, cell: function (r) { return fmtCost(r.cost); } },
+    ];
+    const rows = repos.map(function (r) {
+      return {
+        item: r,
+        className: 'clickable' + (state.repo === r.repo ? ' open' : ''),
+        onclick: function () { setFilter('repo', r.repo); },
+      };
+    });
+    append(clear(document.getElementById('repos')), repos.length ? table(columns, rows) : el('p', { class: 'empty', text: 'No data.' }));
+  }
+
+  function renderModels() {
+    const models = (report.models || []).concat([report.tokens]);
+    const columns = [
+      { label: 'Model', cell: function (m) { return m.model; } },
+      { label: 'Messages', num: true, cell: function (m) { return fmtInt(m.messages); } },
+      { label: 'Input', num: true, cell: function (m) { return fmtTokens(m.inputTokens); } },
+      { label: 'Output', num: true, cell: function (m) { return fmtTokens(m.outputTokens); } },
+      { label: 'Cache write', num: true, cell: function (m) { return fmtTokens(m.cacheWriteTokens); } },
+      { label: 'Cache read', num: true, cell: function (m) { return fmtTokens(m.cacheReadTokens); } },
+      { label: 'Est. cost', num: true, cell: function (m) { return m.priced ? fmtCost(m.cost) : '-'; } },
+    ];
+    append(clear(document.getElementById('models')), table(columns, models.map(function (m) { return { item: m }; })));
+  }
+
+  function renderFiles() {
+    const files = (report.files || []).slice(0, 20);
+    const columns = [
+      { label: 'File', className: 'path', cell: function (f) { return f.path; } },
+      { label: 'Edits', num: true, cell: function (f) { return fmtInt(f.edits); } },
+      { label: 'Writes', num: true, cell: function (f) { return fmtInt(f.writes); } },
+      { label: 'Lines', num: true, cell: function (f) { return fmtInt(f.lines); } },
+    ];
+    append(clear(document.getElementById('files')), files.length ? table(columns, files.map(function (f) { return { item: f }; })) : el('p', { class: 'empty', text: 'No files changed.' }));
+  }
+
+  // ---- session drill-down ----
+
+  function sessionDay(s) {
+    return s.timestamp ? dayKey(new Date(s.timestamp * 1000)) : '';
+  }
+
+  function matchesSearch(s, query) {
+    if (!query) return true;
+    const haystack = [s.title, s.repo, s.id, s.folder].concat(s.files || []).join('\n').toLowerCase();
+    return haystack.indexOf(query) >= 0;
+  }
+
+  function sessionDetail(s, span) {
+    const tools = Object.keys(s.tools || {}).sort(function (a, b) { return s.tools[b] - s.tools[a] || (a < b ? -1 : 1); });
+    const files = s.files || [];
+    const grid = el('div', { class: 'detail-grid' }, [
+      el('div', { text: 'Session ID' }), el('div', { class: 'path', text: s.id || '-' }),
+      el('div', { text: 'Folder' }), el('div', { class: 'path', text: s.folder || '-' }),
+      el('div', { text: 'Models' }), el('div', {}, (s.models || []).map(function (m) { return el('span', { class: 'tag', text: m }); })),
+      el('div', { text: 'Tools' }), el('div', {}, tools.map(function (t) { return el('span', { class: 'tag', text: t + ' × ' + s.tools[t] }); })),
+      el('div', { text: 'Files changed' }),
+      el('div', {}, files.length ? el('ul', {}, files.map(function (f) { return el('li', { class: 'path', text: f }); })) : '-'),
+    ]);
+    return el('tr', { class: 'detail' }, el('td', { colspan: span }, grid));
+  }
+
+  function renderSessions() {
+    const filters = clear(document.getElementById('session-filters'));
+    if (state.day) append(filters, el('button', { type: 'button', text: 'Day: ' + state.day + ' ✕', onclick: function () { setFilter('day', state.day); } }));
+    if (state.repo) append(filters, el('button', { type: 'button', text: 'Repo: ' + state.repo + ' ✕', onclick: function () { setFilter('repo', state.repo); } }));
+
+    const query = state.search.trim().toLowerCase();
+    const sessions = (report.sessionList || []).filter(function (s) {
+      return (!state.day || sessionDay(s) === state.day) && (!state.repo || s.repo === state.repo) && matchesSearch(s, query);
+    });
+    const key = state.sort;
+    sessions.sort(function (a, b) {
+      const x = key === 'lines' ? a.linesWritten + a.linesDiffed : a[key];
+      const y = key === 'lines' ? b.linesWritten + b.linesDiffed : b[key];
+      const order = typeof x === 'string' ? x.localeCompare(y) : x - y;
+      return state.asc ? order : -order;
+    });
+
+    const columns = [
+      { label: 'Last activity', sort: 'timestamp', cell: function (s) { return fmtTime(s.timestamp); } },
+      { label: 'Title', sort: 'title', cell: function (s) { return s.title || '(untitled)'; } },
+      { label: 'Repository', sort: 'repo', cell: function (s) { return s.repo; } },
+      { label: 'Tool calls', sort: 'toolCalls', num: true, cell: function (s) { return fmtInt(s.toolCalls); } },
+      { label: 'Lines', sort: 'lines', num: true, cell: function (s) { return fmtInt(s.linesWritten + s.linesDiffed); } },
+      { label: 'Tokens', sort: 'tokens', num: true, cell: function (s) { return fmtTokens(s.tokens); } },
+      { label: 'Est. cost', sort: 'cost', num: true, cell: function (s) { return fmtCost(s.cost); } },
+    ];
+    const rows = sessions.map(function (s, i) {
+      const id = s.id || String(i);
+      return {
+        item: s,
+        className: 'clickable' + (state.open[id] ? ' open' : ''),
+        onclick: function () { state.open[id] = !state.open[id]; renderSessions(); },
+        detail: state.open[id] ? sessionDetail(s, columns.length) : null,
+      };
+    });
+    const container = clear(document.getElementById('sessions'));
+    if (rows.length === 0) {
+      append(container, el('p', { class: 'empty', text: 'No matching sessions.' }));
+      return;
+    }
+    append(container, table(columns, rows, {
+      sort: state.sort,
+      asc: state.asc,
+      onSort: function (sortKey) {
+        state.asc = state.sort === sortKey ? !state.asc : sortKey === 'title' || sortKey === 'repo';
+        state.sort = sortKey;
+        renderSessions();
+      },
+    }));
+  }
+
+  document.getElementById('session-search').addEventListener('input', function (event) {
+    state.search = event.target.value;
+    renderSessions();
+  });
+
+  renderHeader();
+  renderMetricToggle();
+  renderTimeline();
+  renderTools();
+  renderLanguages();
+  renderRepos();
+  renderModels();
+  renderFiles();
+  renderSessions();
+})();
@@ -0,0 +1,102 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteHTML(t *testing.T) {
+	builder := NewBuilder()
+	records := testRecords()
+	records[0].Title = `fix </script><script>alert(1)</script>`
+	for i := range records {
+		builder.Add(&records[i])
+	}
+	var buf bytes.Buffer
+	page := Page{Title: "Usage <report>", GeneratedAt: time.Date(2025, 8, 3, 9, 0, 0, 0, time.Local), Transcripts: 3, Failed: 1, Version: "1.2.3"}
+	if err := WriteHTML(&buf, builder.Build(Options{}), page); err != nil {
+		t.Fatalf("WriteHTML error: %v", err)
+	}
+	html := buf.String()
+
+	// 單一檔案：不引用任何外部的腳本、樣式、字型或圖片
+	for _, external := range []string{`src="http`, `href="http`, `<link`, `url(http`, `@import`} {
+		if strings.Contains(html, external) {
+			t.Errorf("HTML references an external resource (%s)", external)
+		}
+	}
+	if strings.Count(html, "<script") != 2 || !strings.Contains(html, "<style>:root") {
+		t.Errorf("expected inline CSS, one data block and one script")
+	}
+	if !strings.Contains(html, "<title>Usage &lt;report&gt;</title>") {
+		t.Errorf("title is not escaped")
+	}
+
+	match := regexp.MustCompile(`(?s)<script id="report-data" type="application/json">(.*?)</script>`).FindStringSubmatch(html)
+	if match == nil {
+		t.Fatalf("data block not found")
+	}
+	var data struct {
+		Meta   pageMeta `json:"meta"`
+		Report Report   `json:"report"`
+	}
+	if err := json.Unmarshal([]byte(match[1]), &data); err != nil {
+		t.Fatalf("embedded data is not valid JSON: %v", err)
+	}
+	if data.Meta.Failed != 1 || data.Meta.GeneratedAt != "2025-08-03 09:00" || data.Report.Sessions != 3 {
+		t.Errorf("unexpected data: %+v", data.Meta)
+	}
+	var titles []string
+	for _, session := range data.Report.SessionList {
+		titles = append(titles, session.Title)
+	}
+	if !strings.Contains(strings.Join(titles, "\n"), records[0].Title) {
+		t.Errorf("session titles were not preserved: %q", titles)
+	}
+}
+
+func TestBuilder_SessionsAndLanguages(t *testing.T) {
+	builder := NewBuilder()
+	records := testRecords()
+	for i := range records {
+		records[i].TaskID = string(rune('a' + i))
+		builder.Add(&records[i])
+	}
+	report := builder.Build(Options{Top: 1})
+
+	if len(report.SessionList) != 3 || report.SessionList[2].ID != "a" {
+		t.Fatalf("sessions are not listed newest first: %+v", report.SessionList)
+	}
+	first := report.SessionList[2]
+	if first.ToolCalls != 5 || first.Tools["Edit"] != 3 || first.LinesDiffed != 6 || len(first.Models) != 1 {
+		t.Errorf("unexpected session: %+v", first)
+	}
+	if strings.Join(first.Files, ",") != "/etc/hosts,app/main.go" {
+		t.Errorf("session files = %v", first.Files)
+	}
+
+	if len(report.Languages) != 2 || report.Languages[0].Language != "Go" || report.Languages[0].Edits != 3 || report.Languages[1].Language != "Other" {
+		t.Errorf("languages = %+v", report.Languages)
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	tests := map[string]string{
+		"/work/app/main.go":        "Go",
+		"/work/app/web/App.TSX":    "TypeScript",
+		"/work/app/Dockerfile":     "Dockerfile",
+		"/work/app/Dockerfile.dev": "Dockerfile",
+		"/work/app/Makefile":       "Makefile",
+		"C:/work/app/script.ps1":   "PowerShell",
+		"/etc/hosts":               "Other",
+	}
+	for file, want := range tests {
+		if got := Language(file); got != want {
+			t.Errorf("Language(%q) = %q, want %q", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"path"
+	"strings"
+)
+
+// languages 依副檔名判斷檔案的語言，只涵蓋常見的類型，其餘歸為 Other
+var languages = map[string]string{
+	".go":     "Go",
+	".py":     "Python",
+	".ipynb":  "Python",
+	".js":     "JavaScript",
+	".jsx":    "JavaScript",
+	".mjs":    "JavaScript",
+	".cjs":    "JavaScript",
+	".ts":     "TypeScript",
+	".tsx":    "TypeScript",
+	".java":   "Java",
+	".kt":     "Kotlin",
+	".kts":    "Kotlin",
+	".scala":  "Scala",
+	".rs":     "Rust",
+	".c":      "C",
+	".h":      "C",
+	".cc":     "C++",
+	".cpp":    "C++",
+	".cxx":    "C++",
+	".hpp":    "C++",
+	".cs":     "C#",
+	".swift":  "Swift",
+	".m":      "Objective-C",
+	".rb":     "Ruby",
+	".php":    "PHP",
+	".dart":   "Dart",
+	".lua":    "Lua",
+	".r":      "R",
+	".sh":     "Shell",
+	".bash":   "Shell",
+	".zsh":    "Shell",
+	".ps1":    "PowerShell",
+	".sql":    "SQL",
+	".html":   "HTML",
+	".htm":    "HTML",
+	".css":    "CSS",
+	".scss":   "CSS",
+	".less":   "CSS",
+	".vue":    "Vue",
+	".svelte": "Svelte",
+	".md":     "Markdown",
+	".mdx":    "Markdown",
+	".rst":    "reStructuredText",
+	".json":   "JSON",
+	".yaml":   "YAML",
+	".yml":    "YAML",
+	".toml":   "TOML",
+	".xml":    "XML",
+	".proto":  "Protocol Buffers",
+	".tf":     "Terraform",
+	".hcl":    "Terraform",
+	".gradle": "Gradle",
+	".cmake":  "CMake",
+}
+
+// languageFiles 依檔名判斷沒有副檔名的常見檔案
+var languageFiles = map[string]string{
+	"dockerfile":     "Dockerfile",
+	"makefile":       "Makefile",
+	"gnumakefile":    "Makefile",
+	"cmakelists.txt": "CMake",
+	"go.mod":         "Go",
+	"go.sum":         "Go",
+}
+
+// Language 返回檔案的語言名稱，無法判斷時返回 "Other"
+func Language(filePath string) string {
+	name := strings.ToLower(path.Base(filePath))
+	if language, ok := languageFiles[name]; ok {
+		return language
+	}
+	if strings.HasPrefix(name, "dockerfile.") || strings.HasSuffix(name, ".dockerfile") {
+		return "Dockerfile"
+	}
+	if language, ok := languages[path.Ext(name)]; ok {
+		return language
+	}
+	return "Other"
+}
@@ -0,0 +1,47 @@
+package report
+
+import (
+	"strings"
+
+	"claude_analysis/core/telemetry"
+)
+
+// Price - 模型每百萬 token 的牌價（美元）
+type Price struct {
+	Input      float64
+	Output     float64
+	CacheWrite float64 // 5 分鐘快取寫入
+	CacheRead  float64
+}
+
+// prices 依模型名稱片段比對，由上而下第一個符合的生效，因此較新、較具體的名稱放在前面
+var prices = []struct {
+	match string
+	price Price
+}{
+	{"opus-4-5", Price{Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.50}},
+	{"opus-4", Price{Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50}},
+	{"3-opus", Price{Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50}},
+	{"sonnet", Price{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30}},
+	{"haiku-4", Price{Input: 1, Output: 5, CacheWrite: 1.25, CacheRead: 0.10}},
+	{"3-5-haiku", Price{Input: 0.80, Output: 4, CacheWrite: 1, CacheRead: 0.08}},
+	{"3-haiku", Price{Input: 0.25, Output: 1.25, CacheWrite: 0.30, CacheRead: 0.03}},
+}
+
+// PriceFor 返回模型的牌價；未知的模型（例如 <synthetic>）返回 false
+func PriceFor(model string) (Price, bool) {
+	for _, entry := range prices {
+		if strings.Contains(model, entry.match) {
+			return entry.price, true
+		}
+	}
+	return Price{}, false
+}
+
+// Cost 以牌價估算 token 用量的費用（美元），不考慮批次或訂閱方案的折扣
+func (p Price) Cost(usage telemetry.ClaudeCodeAnalysisTokenUsage) float64 {
+	return (float64(usage.InputTokens)*p.Input +
+		float64(usage.OutputTokens)*p.Output +
+		float64(usage.CacheCreationInputTokens)*p.CacheWrite +
+		float64(usage.CacheReadInputTokens)*p.CacheRead) / 1e6
+}
@@ -0,0 +1,377 @@
+package report
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"claude_analysis/core/telemetry"
+)
+
+// DayStat - 一天的活動，以工作階段最後一則訊息的本地日期歸類
+type DayStat struct {
+	Day          string  `json:"day"` // YYYY-MM-DD
+	Sessions     int     `json:"sessions"`
+	ToolCalls    int     `json:"toolCalls"`
+	LinesWritten int     `json:"linesWritten"`
+	LinesDiffed  int     `json:"linesDiffed"`
+	Cost         float64 `json:"cost"`
+}
+
+// RepoStat - 一個 repo（見 telemetry.RepoName）的活動
+type RepoStat struct {
+	Repo         string  `json:"repo"`
+	Sessions     int     `json:"sessions"`
+	ToolCalls    int     `json:"toolCalls"`
+	LinesWritten int     `json:"linesWritten"`
+	LinesDiffed  int     `json:"linesDiffed"`
+	Tokens       int     `json:"tokens"` // 含快取的 input 與 output token
+	Cost         float64 `json:"cost"`
+}
+
+// ToolStat - 一個工具的呼叫次數
+type ToolStat struct {
+	Tool  string `json:"tool"`
+	Calls int    `json:"calls"`
+}
+
+// ModelStat - 一個模型的 token 用量與估算費用
+type ModelStat struct {
+	Model            string  `json:"model"`
+	Messages         int     `json:"messages"`
+	InputTokens      int     `json:"inputTokens"`
+	OutputTokens     int     `json:"outputTokens"`
+	CacheWriteTokens int     `json:"cacheWriteTokens"`
+	CacheReadTokens  int     `json:"cacheReadTokens"`
+	Cost             float64 `json:"cost"`
+	Priced           bool    `json:"priced"` // false 表示沒有此模型的牌價，Cost 為 0
+}
+
+// FileStat - 一個檔案被 Write 與 Edit 的次數
+type FileStat struct {
+	Path   string `json:"path"` // 在工作目錄內時顯示為 <目錄名稱>/<相對路徑>
+	Writes int    `json:"writes"`
+	Edits  int    `json:"edits"`
+	Lines  int    `json:"lines"` // 寫入與修改的行數
+}
+
+// LanguageStat - 一種語言（見 Language）的檔案變更
+type LanguageStat struct {
+	Language string `json:"language"`
+	Files    int    `json:"files"`
+	Writes   int    `json:"writes"`
+	Edits    int    `json:"edits"`
+	Lines    int    `json:"lines"`
+}
+
+// SessionStat - 一個工作階段的摘要，供 HTML 報表逐一檢視
+type SessionStat struct {
+	ID           string         `json:"id"`
+	Title        string         `json:"title"`
+	Repo         string         `json:"repo"`
+	Folder       string         `json:"folder"`
+	Timestamp    int64          `json:"timestamp"` // 最後一則訊息的 Unix 秒
+	ToolCalls    int            `json:"toolCalls"`
+	Tools        map[string]int `json:"tools"`
+	Models       []string       `json:"models"`
+	Tokens       int            `json:"tokens"`
+	Cost         float64        `json:"cost"`
+	LinesWritten int            `json:"linesWritten"`
+	LinesDiffed  int            `json:"linesDiffed"`
+	Files        []string       `json:"files"` // 寫入或修改的檔案，依路徑排序
+}
+
+// Changes - 程式碼變更的總計
+type Changes struct {
+	FilesChanged    int `json:"filesChanged"`
+	LinesWritten    int `json:"linesWritten"` // Write 建立的行數
+	WriteCharacters int `json:"writeCharacters"`
+	LinesDiffed     int `json:"linesDiffed"` // Edit 新內容的行數
+	DiffCharacters  int `json:"diffCharacters"`
+}
+
+// Report - 個人使用報表
+type Report struct {
+	Sessions      int             `json:"sessions"`
+	FirstActivity int64           `json:"firstActivity,omitempty"` // Unix 秒
+	LastActivity  int64           `json:"lastActivity,omitempty"`  // Unix 秒
+	ToolCalls     int             `json:"toolCalls"`
+	Days          []*DayStat      `json:"days"` // 依日期排序
+	Repos         []*RepoStat     `json:"repos"`
+	Tools         []*ToolStat     `json:"tools"`
+	Models        []*ModelStat    `json:"models"`
+	Tokens        ModelStat       `json:"tokens"` // 所有模型的總計
+	Changes       Changes         `json:"changes"`
+	Files         []*FileStat     `json:"files"`
+	Languages     []*LanguageStat `json:"languages"`
+	SessionList   []*SessionStat  `json:"sessionList"` // 依最後活動時間由新到舊，不受 Options 截斷
+}
+
+// Options 控制報表各表格的長度，0 表示不限制
+type Options struct {
+	Top  int // repo、工具與檔案只保留前 Top 名（語言與工作階段不截斷）
+	Days int // 只保留最近 Days 個有活動的日期
+}
+
+// Builder 累加工作階段記錄
+type Builder struct {
+	report Report
+	days   map[string]*DayStat
+	repos  map[string]*RepoStat
+	tools  map[string]*ToolStat
+	models map[string]*ModelStat
+	files  map[string]*FileStat
+	// sessions 依加入順序保存，Build 時排序
+	sessions []*SessionStat
+}
+
+// NewBuilder 建立空的報表
+func NewBuilder() *Builder {
+	return &Builder{
+		days:   make(map[string]*DayStat),
+		repos:  make(map[string]*RepoStat),
+		tools:  make(map[string]*ToolStat),
+		models: make(map[string]*ModelStat),
+		files:  make(map[string]*FileStat),
+	}
+}
+
+// Add 將一個工作階段加入報表
+func (b *Builder) Add(record *telemetry.ClaudeCodeAnalysisRecord) {
+	r := &b.report
+	r.Sessions++
+	if record.Timestamp > r.LastActivity {
+		r.LastActivity = record.Timestamp
+	}
+	if record.Timestamp > 0 && (r.FirstActivity == 0 || record.Timestamp < r.FirstActivity) {
+		r.FirstActivity = record.Timestamp
+	}
+
+	session := &SessionStat{
+		ID:        record.TaskID,
+		Title:     record.Title,
+		Repo:      telemetry.RepoName(record),
+		Folder:    record.FolderPath,
+		Timestamp: record.Timestamp,
+		Tools:     make(map[string]int, len(record.ToolLatencies)),
+		Models:    make([]string, 0, len(record.TokenUsage)),
+	}
+	b.sessions = append(b.sessions, session)
+
+	// 與 query、batch 相同，以耗時統計計算工具呼叫次數（包含 MCP 等所有工具）
+	toolCalls := 0
+	for name, latency := range record.ToolLatencies {
+		session.Tools[name] += latency.Count
+		entry(b.tools, name, func() *ToolStat { return &ToolStat{Tool: name} }).Calls += latency.Count
+		toolCalls += latency.Count
+	}
+	r.ToolCalls += toolCalls
+
+	tokens, cost := 0, 0.0
+	for model, usage := range record.TokenUsage {
+		price, priced := PriceFor(model)
+		stat := entry(b.models, model, func() *ModelStat { return &ModelStat{Model: model, Priced: priced} })
+		addUsage(stat, usage)
+		if priced {
+			stat.Cost += price.Cost(usage)
+			cost += price.Cost(usage)
+		}
+		addUsage(&r.Tokens, usage)
+		session.Models = append(session.Models, model)
+		tokens += usage.InputTokens + usage.OutputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+	}
+	r.Tokens.Cost += cost
+
+	linesDiffed := 0
+	changed := make(map[string]bool)
+	for i := range record.ApplyDiffDetails {
+		detail := &record.ApplyDiffDetails[i]
+		linesDiffed += detail.LineCount
+		r.Changes.DiffCharacters += detail.CharacterCount
+		file := b.file(record.FolderPath, detail.FilePath, detail.LineCount)
+		file.Edits++
+		changed[file.Path] = true
+	}
+	for i := range record.WriteToFileDetails {
+		detail := &record.WriteToFileDetails[i]
+		r.Changes.WriteCharacters += detail.CharacterCount
+		file := b.file(record.FolderPath, detail.FilePath, detail.LineCount)
+		file.Writes++
+		changed[file.Path] = true
+	}
+	r.Changes.LinesWritten += record.TotalWriteLines
+	r.Changes.LinesDiffed += linesDiffed
+
+	session.Files = make([]string, 0, len(changed))
+	for file := range changed {
+		session.Files = append(session.Files, file)
+	}
+	sort.Strings(session.Files)
+	sort.Strings(session.Models)
+	session.ToolCalls = toolCalls
+	session.Tokens = tokens
+	session.Cost = cost
+	session.LinesWritten = record.TotalWriteLines
+	session.LinesDiffed = linesDiffed
+
+	repoStat := entry(b.repos, session.Repo, func() *RepoStat { return &RepoStat{Repo: session.Repo} })
+	repoStat.Sessions++
+	repoStat.ToolCalls += toolCalls
+	repoStat.LinesWritten += record.TotalWriteLines
+	repoStat.LinesDiffed += linesDiffed
+	repoStat.Tokens += tokens
+	repoStat.Cost += cost
+
+	if record.Timestamp > 0 {
+		day := time.Unix(record.Timestamp, 0).Format("2006-01-02")
+		dayStat := entry(b.days, day, func() *DayStat { return &DayStat{Day: day} })
+		dayStat.Sessions++
+		dayStat.ToolCalls += toolCalls
+		dayStat.LinesWritten += record.TotalWriteLines
+		dayStat.LinesDiffed += linesDiffed
+		dayStat.Cost += cost
+	}
+}
+
+// file 返回檔案的統計並累加行數；同一個檔案在不同工作階段中以絕對路徑合併
+func (b *Builder) file(folder, filePath string, lines int) *FileStat {
+	stat := entry(b.files, filePath, func() *FileStat { return &FileStat{Path: displayPath(folder, filePath)} })
+	stat.Lines += lines
+	return stat
+}
+
+// Build 依 options 排序並截斷各表格，返回報表
+func (b *Builder) Build(options Options) *Report {
+	r := b.report
+	r.Tokens.Model = "TOTAL"
+	r.Tokens.Priced = true
+	r.Changes.FilesChanged = len(b.files)
+
+	r.Days = values(b.days)
+	sort.Slice(r.Days, func(i, j int) bool { return r.Days[i].Day < r.Days[j].Day })
+	if options.Days > 0 && len(r.Days) > options.Days {
+		r.Days = r.Days[len(r.Days)-options.Days:]
+	}
+
+	r.Repos = values(b.repos)
+	sort.Slice(r.Repos, func(i, j int) bool {
+		a, c := r.Repos[i], r.Repos[j]
+		if a.Sessions != c.Sessions {
+			return a.Sessions > c.Sessions
+		}
+		if a.Tokens != c.Tokens {
+			return a.Tokens > c.Tokens
+		}
+		return a.Repo < c.Repo
+	})
+	r.Repos = top(r.Repos, options.Top)
+
+	r.Tools = values(b.tools)
+	sort.Slice(r.Tools, func(i, j int) bool {
+		if r.Tools[i].Calls != r.Tools[j].Calls {
+			return r.Tools[i].Calls > r.Tools[j].Calls
+		}
+		return r.Tools[i].Tool < r.Tools[j].Tool
+	})
+	r.Tools = top(r.Tools, options.Top)
+
+	// 模型數量不多，全部列出
+	r.Models = values(b.models)
+	sort.Slice(r.Models, func(i, j int) bool {
+		a, c := r.Models[i], r.Models[j]
+		if a.Cost != c.Cost {
+			return a.Cost > c.Cost
+		}
+		if a.OutputTokens != c.OutputTokens {
+			return a.OutputTokens > c.OutputTokens
+		}
+		return a.Model < c.Model
+	})
+
+	r.Files = values(b.files)
+	sort.Slice(r.Files, func(i, j int) bool {
+		a, c := r.Files[i], r.Files[j]
+		if a.Writes+a.Edits != c.Writes+c.Edits {
+			return a.Writes+a.Edits > c.Writes+c.Edits
+		}
+		if a.Lines != c.Lines {
+			return a.Lines > c.Lines
+		}
+		return a.Path < c.Path
+	})
+	r.Files = top(r.Files, options.Top)
+
+	r.Languages = b.languages()
+	r.SessionList = make([]*SessionStat, len(b.sessions))
+	copy(r.SessionList, b.sessions)
+	sort.SliceStable(r.SessionList, func(i, j int) bool { return r.SessionList[i].Timestamp > r.SessionList[j].Timestamp })
+	return &r
+}
+
+// languages 依語言彙總所有變更過的檔案，依變更次數排序
+func (b *Builder) languages() []*LanguageStat {
+	stats := make(map[string]*LanguageStat)
+	for filePath, file := range b.files {
+		language := Language(filePath)
+		stat := entry(stats, language, func() *LanguageStat { return &LanguageStat{Language: language} })
+		stat.Files++
+		stat.Writes += file.Writes
+		stat.Edits += file.Edits
+		stat.Lines += file.Lines
+	}
+	list := values(stats)
+	sort.Slice(list, func(i, j int) bool {
+		a, c := list[i], list[j]
+		if a.Writes+a.Edits != c.Writes+c.Edits {
+			return a.Writes+a.Edits > c.Writes+c.Edits
+		}
+		return a.Language < c.Language
+	})
+	return list
+}
+
+func addUsage(stat *ModelStat, usage telemetry.ClaudeCodeAnalysisTokenUsage) {
+	stat.Messages += usage.Messages
+	stat.InputTokens += usage.InputTokens
+	stat.OutputTokens += usage.OutputTokens
+	stat.CacheWriteTokens += usage.CacheCreationInputTokens
+	stat.CacheReadTokens += usage.CacheReadInputTokens
+}
+
+// displayPath 將工作目錄內的檔案顯示為 <目錄名稱>/<相對路徑>，其他檔案保留原路徑
+// 路徑已經過 telemetry.NormalizePaths，一律使用正斜槓
+func displayPath(folder, filePath string) string {
+	if folder == "" || folder == "/" {
+		return filePath
+	}
+	if rel, ok := strings.CutPrefix(filePath, strings.TrimSuffix(folder, "/")+"/"); ok {
+		return path.Join(path.Base(folder), rel)
+	}
+	return filePath
+}
+
+// entry 返回 m[key]，不存在時以 create 建立
+func entry[T any](m map[string]*T, key string, create func() *T) *T {
+	value, ok := m[key]
+	if !ok {
+		value = create()
+		m[key] = value
+	}
+	return value
+}
+
+func values[T any](m map[string]*T) []*T {
+	list := make([]*T, 0, len(m))
+	for _, value := range m {
+		list = append(list, value)
+	}
+	return list
+}
+
+func top[T any](list []*T, n int) []*T {
+	if n > 0 && len(list) > n {
+		return list[:n]
+	}
+	return list
+}
@@ -0,0 +1,146 @@
+package report
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"claude_analysis/core/telemetry"
+)
+
+func detail(filePath string, lines int) telemetry.ClaudeCodeAnalysisDetailBase {
+	return telemetry.ClaudeCodeAnalysisDetailBase{FilePath: filePath, LineCount: lines, CharacterCount: lines * 10}
+}
+
+func testRecords() []telemetry.ClaudeCodeAnalysisRecord {
+	day1 := time.Date(2025, 8, 1, 10, 0, 0, 0, time.Local).Unix()
+	day2 := time.Date(2025, 8, 2, 23, 30, 0, 0, time.Local).Unix()
+	return []telemetry.ClaudeCodeAnalysisRecord{
+		{
+			Timestamp:    day1,
+			FolderPath:   "/work/app",
+			GitRemoteURL: "git@github.com:acme/app.git",
+			ToolLatencies: map[string]telemetry.ClaudeCodeAnalysisToolLatency{
+				"Edit": {Count: 3}, "Bash": {Count: 2},
+			},
+			TokenUsage: map[string]telemetry.ClaudeCodeAnalysisTokenUsage{
+				"claude-sonnet-4-20250514": {Messages: 4, InputTokens: 1_000_000, OutputTokens: 100_000},
+			},
+			TotalWriteLines: 20,
+			WriteToFileDetails: []telemetry.ClaudeCodeAnalysisWriteDetail{
+				{ClaudeCodeAnalysisDetailBase: detail("/work/app/main.go", 20)},
+			},
+			ApplyDiffDetails: []telemetry.ClaudeCodeAnalysisApplyDiffDetail{
+				{ClaudeCodeAnalysisDetailBase: detail("/work/app/main.go", 2)},
+				{ClaudeCodeAnalysisDetailBase: detail("/work/app/main.go", 3)},
+				{ClaudeCodeAnalysisDetailBase: detail("/etc/hosts", 1)},
+			},
+		},
+		{
+			Timestamp:    day2,
+			FolderPath:   "/work/app",
+			GitRemoteURL: "https://github.com/acme/app",
+			ToolLatencies: map[string]telemetry.ClaudeCodeAnalysisToolLatency{
+				"Edit": {Count: 1},
+			},
+			TokenUsage: map[string]telemetry.ClaudeCodeAnalysisTokenUsage{
+				"claude-opus-4-1-20250805": {Messages: 1, OutputTokens: 1_000_000, CacheReadInputTokens: 1_000_000},
+				"<synthetic>":              {Messages: 1},
+			},
+			ApplyDiffDetails: []telemetry.ClaudeCodeAnalysisApplyDiffDetail{
+				{ClaudeCodeAnalysisDetailBase: detail("/work/app/main.go", 4)},
+			},
+		},
+		{
+			Timestamp:  day2,
+			FolderPath: "/work/tool",
+		},
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	builder := NewBuilder()
+	records := testRecords()
+	for i := range records {
+		builder.Add(&records[i])
+	}
+	report := builder.Build(Options{})
+
+	if report.Sessions != 3 || report.ToolCalls != 6 {
+		t.Errorf("sessions/tool calls = %d/%d, want 3/6", report.Sessions, report.ToolCalls)
+	}
+	if len(report.Days) != 2 || report.Days[0].Day != "2025-08-01" || report.Days[1].Sessions != 2 {
+		t.Errorf("days = %+v", report.Days)
+	}
+	if len(report.Repos) != 2 || report.Repos[0].Repo != "github.com/acme/app" || report.Repos[0].Sessions != 2 || report.Repos[1].Repo != "tool" {
+		t.Errorf("repos = %+v", report.Repos)
+	}
+	if report.Tools[0].Tool != "Edit" || report.Tools[0].Calls != 4 {
+		t.Errorf("tools = %+v", report.Tools)
+	}
+
+	// sonnet：1M input × $3 + 0.1M output × $15；opus：1M output × $75 + 1M cache read × $1.5
+	if math.Abs(report.Tokens.Cost-(3+1.5+75+1.5)) > 1e-9 {
+		t.Errorf("total cost = %v", report.Tokens.Cost)
+	}
+	if report.Models[0].Model != "claude-opus-4-1-20250805" || report.Models[2].Model != "<synthetic>" || report.Models[2].Priced {
+		t.Errorf("models = %+v", report.Models)
+	}
+	if report.Tokens.Messages != 6 {
+		t.Errorf("total messages = %d, want 6", report.Tokens.Messages)
+	}
+
+	changes := report.Changes
+	if changes.FilesChanged != 2 || changes.LinesWritten != 20 || changes.LinesDiffed != 10 || changes.DiffCharacters != 100 {
+		t.Errorf("changes = %+v", changes)
+	}
+	main := report.Files[0]
+	if main.Path != "app/main.go" || main.Writes != 1 || main.Edits != 3 || main.Lines != 29 {
+		t.Errorf("most edited file = %+v", main)
+	}
+	if report.Files[1].Path != "/etc/hosts" {
+		t.Errorf("files outside the folder keep their path: %+v", report.Files[1])
+	}
+}
+
+func TestBuilder_Options(t *testing.T) {
+	builder := NewBuilder()
+	records := testRecords()
+	for i := range records {
+		builder.Add(&records[i])
+	}
+	report := builder.Build(Options{Top: 1, Days: 1})
+	if len(report.Days) != 1 || report.Days[0].Day != "2025-08-02" {
+		t.Errorf("days = %+v, want only the most recent", report.Days)
+	}
+	if len(report.Repos) != 1 || len(report.Tools) != 1 || len(report.Files) != 1 {
+		t.Errorf("top 1 kept %d repos, %d tools, %d files", len(report.Repos), len(report.Tools), len(report.Files))
+	}
+	if len(report.Models) != 3 || report.Changes.FilesChanged != 2 {
+		t.Errorf("models and totals are not truncated: %d models, %d files changed", len(report.Models), report.Changes.FilesChanged)
+	}
+}
+
+func TestPriceFor(t *testing.T) {
+	tests := []struct {
+		model string
+		input float64
+		ok    bool
+	}{
+		{"claude-opus-4-5-20251101", 5, true},
+		{"claude-opus-4-1-20250805", 15, true},
+		{"claude-3-opus-20240229", 15, true},
+		{"claude-sonnet-4-20250514", 3, true},
+		{"claude-3-7-sonnet-20250219", 3, true},
+		{"claude-haiku-4-5-20251001", 1, true},
+		{"claude-3-5-haiku-20241022", 0.80, true},
+		{"claude-3-haiku-20240307", 0.25, true},
+		{"<synthetic>", 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := PriceFor(tt.model)
+		if ok != tt.ok || price.Input != tt.input {
+			t.Errorf("PriceFor(%q) = %+v, %v", tt.model, price, ok)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"claude_analysis/core/telemetry"
+)
+
+// File - 以 NDJSON 追加到本地檔案的 Sink
+// Path 為既有目錄或以路徑分隔符結尾時，每天寫入一個 claude_analysis-YYYY-MM-DD.ndjson
+type File struct {
+	Path string
+	now  func() time.Time
+}
+
+// Send 追加一行分析結果
+func (f *File) Send(analysis *telemetry.ClaudeCodeAnalysis) error {
+	line, err := jsonMarshal(analysis)
+	if err != nil {
+		return err
+	}
+	target := f.target()
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", target, err)
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", target, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return nil
+}
+
+func (f *File) target() string {
+	info, err := os.Stat(f.Path)
+	isDir := (err == nil && info.IsDir()) || strings.HasSuffix(f.Path, "/") || strings.HasSuffix(f.Path, string(filepath.Separator))
+	if !isDir {
+		return f.Path
+	}
+	now := time.Now
+	if f.now != nil {
+		now = f.now
+	}
+	return filepath.Join(f.Path, "claude_analysis-"+now().Format("2006-01-02")+".ndjson")
+}
+
+// Writer - 將分析結果以 NDJSON 寫到 io.Writer 的 Sink（stdout sink）
+type Writer struct {
+	W io.Writer
+}
+
+// Send 寫出一行分析結果
+func (w *Writer) Send(analysis *telemetry.ClaudeCodeAnalysis) error {
+	if w.W == nil {
+		return nil
+	}
+	line, err := jsonMarshal(analysis)
+	if err != nil {
+		return err
+	}
+	_, err = w.W.Write(append(line, '\n'))
+	return err
+}
+
+func jsonMarshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal analysis: %w", err)
+	}
+	return data, nil
+}
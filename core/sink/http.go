@@ -0,0 +1,90 @@
+package sink
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/spool"
+	"claude_analysis/core/telemetry"
+)
+
+// HTTP - 上傳到 o11y collector 的 Sink，超過大小上限時拆分上傳，可重送的失敗寫入離線佇列
+type HTTP struct {
+	Endpoint string
+	client   *telemetry.Client
+	maxBytes int
+	spool    *spool.Spool
+}
+
+// NewHTTP 建立 HTTP Sink；endpoint 為空時使用 cfg.API.Endpoint
+func NewHTTP(cfg *config.Config, endpoint string, sp *spool.Spool) *HTTP {
+	sinkCfg := *cfg
+	if endpoint != "" {
+		sinkCfg.API.Endpoint = endpoint
+	}
+	return &HTTP{
+		Endpoint: sinkCfg.API.Endpoint,
+		client:   telemetry.New(&sinkCfg),
+		maxBytes: sinkCfg.API.MaxPayloadBytes,
+		spool:    sp,
+	}
+}
+
+// Send 上傳分析結果
+func (h *HTTP) Send(analysis *telemetry.ClaudeCodeAnalysis) error {
+	// 超过上限时拆分为多个部分，各部分以 part/totalParts 标记，由 collector 合并
+	parts, err := telemetry.SplitAnalysis(analysis, h.maxBytes)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	var lastErr error
+	for _, part := range parts {
+		// 不符合 schema 的负载重送也不会成功，不写入离线队列
+		if err := telemetry.Validate(part); err != nil {
+			log.Printf("[ERROR] Invalid payload (part %d/%d): %v", part.Part, part.TotalParts, err)
+			failed++
+			lastErr = err
+			continue
+		}
+		// 每个部分的 header 使用独立的幂等键，body 中的 idempotencyKey 用于合并
+		key := part.IdempotencyKey
+		if part.TotalParts > 1 && key != "" {
+			key = fmt.Sprintf("%s-%d", key, part.Part)
+		}
+		if _, err := h.client.SubmitWithKey(part, key); err != nil {
+			log.Printf("[ERROR] API call failed (endpoint: %s, part %d/%d): %v", h.Endpoint, part.Part, part.TotalParts, err)
+			// 只有网络错误、429 与 5xx 值得稍后重送
+			if telemetry.IsRetryable(err) {
+				h.spoolPart(part, key)
+			}
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d request(s) to %s failed: %w", failed, len(parts), h.Endpoint, lastErr)
+	}
+	return nil
+}
+
+// spoolPart 将发送失败的部分写入离线队列，等待之后重送
+func (h *HTTP) spoolPart(part *telemetry.ClaudeCodeAnalysis, key string) {
+	if h.spool == nil {
+		return
+	}
+	data, err := jsonMarshal(part)
+	if err != nil {
+		log.Printf("[ERROR] Failed to marshal payload for spool: %v", err)
+		return
+	}
+	entry, err := h.spool.Add(h.Endpoint, key, data, time.Now())
+	if err != nil {
+		log.Printf("[ERROR] Failed to spool payload, telemetry is lost: %v", err)
+		return
+	}
+	log.Printf("[INFO] Spooled payload %s for later retry", entry.ID)
+}
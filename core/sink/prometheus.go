@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/httpclient"
+	"claude_analysis/core/prometheus"
+	"claude_analysis/core/telemetry"
+)
+
+// defaultJob 是未設定 job 時 Pushgateway 使用的 job 名稱
+const defaultJob = "claude_analysis"
+
+// Prometheus - 將跨 hook 執行累加的計數器寫入 textfile collector 目錄，及/或推送到 Pushgateway
+type Prometheus struct {
+	TextfileDir    string
+	PushgatewayURL string
+	Job            string
+	instance       string
+	store          *prometheus.Store
+	client         *http.Client
+}
+
+// NewPrometheus 建立 Prometheus Sink，計數器保存在 cfg.DataDir 下；
+// 有名稱的 Sink 各自累加，避免不同過濾條件的計數器互相混合
+func NewPrometheus(cfg *config.Config, sc config.SinkConfig) *Prometheus {
+	job := sc.Job
+	if job == "" {
+		job = defaultJob
+	}
+	dir := prometheus.Dir(cfg.DataDir)
+	if sc.Name != "" {
+		dir = filepath.Join(dir, strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(sc.Name))
+	}
+	return &Prometheus{
+		TextfileDir:    expandHome(sc.Path),
+		PushgatewayURL: sc.Endpoint,
+		Job:            job,
+		instance:       cfg.MachineID,
+		store:          prometheus.NewStore(dir),
+		client:         httpclient.New(httpclient.Options{Timeout: time.Duration(cfg.API.Timeout), Proxy: cfg.Proxy}),
+	}
+}
+
+// Send 累加計數器並輸出完整的總計
+func (p *Prometheus) Send(analysis *telemetry.ClaudeCodeAnalysis) error {
+	counters, err := p.store.Update(analysis)
+	if err != nil {
+		return err
+	}
+	if p.TextfileDir != "" {
+		if err := prometheus.WriteTextfile(p.TextfileDir, counters); err != nil {
+			return err
+		}
+	}
+	if p.PushgatewayURL != "" {
+		return prometheus.Push(p.client, p.PushgatewayURL, p.Job, p.instance, counters)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package sink
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/telemetry"
+)
+
+// Redact 依脫敏等級就地修改分析結果
+//
+// content：移除寫入內容、diff、命令參數、搜尋查詢、URL 路徑與策略決策的原因，保留行數與字數等統計
+// strict：在 content 的基礎上，將檔案路徑、專案路徑、git remote 與網域雜湊化，並移除標題、命令與 URL
+func Redact(analysis *telemetry.ClaudeCodeAnalysis, level string) {
+	if level != config.RedactContent && level != config.RedactStrict {
+		return
+	}
+	strict := level == config.RedactStrict
+
+	for i := range analysis.Records {
+		record := &analysis.Records[i]
+		for j := range record.WriteToFileDetails {
+			detail := &record.WriteToFileDetails[j]
+			detail.Content = ""
+			redactBase(&detail.ClaudeCodeAnalysisDetailBase, strict)
+		}
+		for j := range record.ReadFileDetails {
+			redactBase(&record.ReadFileDetails[j].ClaudeCodeAnalysisDetailBase, strict)
+		}
+		for j := range record.ApplyDiffDetails {
+			detail := &record.ApplyDiffDetails[j]
+			detail.OldString = ""
+			detail.NewString = ""
+			redactBase(&detail.ClaudeCodeAnalysisDetailBase, strict)
+		}
+		for j := range record.RunCommandDetails {
+			detail := &record.RunCommandDetails[j]
+			detail.Command = commandName(detail.Command)
+			detail.Description = ""
+			if strict {
+				detail.Command = ""
+			}
+			redactBase(&detail.ClaudeCodeAnalysisDetailBase, strict)
+		}
+		for j := range record.WebAccessDetails {
+			detail := &record.WebAccessDetails[j]
+			detail.Query = ""
+			detail.URL = siteURL(detail.URL)
+			if strict {
+				detail.URL = ""
+				detail.Domain = hashValue(detail.Domain)
+			}
+			redactBase(&detail.ClaudeCodeAnalysisDetailBase, strict)
+		}
+		for j := range record.PolicyDecisions {
+			// 規則的原因可能引用被拒絕的命令或路徑
+			record.PolicyDecisions[j].Target = ""
+			record.PolicyDecisions[j].Reason = ""
+		}
+
+		if strict {
+			record.FolderPath = hashValue(record.FolderPath)
+			record.GitRemoteURL = hashValue(record.GitRemoteURL)
+			record.Title = ""
+			if record.WebDomainCounts != nil {
+				counts := make(map[string]int, len(record.WebDomainCounts))
+				for domain, count := range record.WebDomainCounts {
+					counts[hashValue(domain)] += count
+				}
+				record.WebDomainCounts = counts
+			}
+			if record.CodeSurvival != nil {
+				for j := range record.CodeSurvival.Files {
+					record.CodeSurvival.Files[j].FilePath = hashPath(record.CodeSurvival.Files[j].FilePath)
+				}
+			}
+		}
+	}
+}
+
+// RedactedFields 說明各脫敏等級如何修改欄位，鍵為 JSON 路徑（格式同 telemetry.FieldSources）
+func RedactedFields(level string) map[string]string {
+	if level != config.RedactContent && level != config.RedactStrict {
+		return nil
+	}
+	fields := map[string]string{
+		"records[].writeToFileDetails[].content":    "removed",
+		"records[].applyDiffDetails[].old_string":   "removed",
+		"records[].applyDiffDetails[].new_string":   "removed",
+		"records[].runCommandDetails[].command":     "reduced to the program name, environment assignments dropped",
+		"records[].runCommandDetails[].description": "removed",
+		"records[].webAccessDetails[].query":        "removed",
+		"records[].webAccessDetails[].url":          "reduced to scheme and host",
+		"records[].policyDecisions[].target":        "removed",
+		"records[].policyDecisions[].reason":        "removed",
+	}
+	if level == config.RedactStrict {
+		for _, key := range []string{"writeToFileDetails", "readFileDetails", "applyDiffDetails", "runCommandDetails", "webAccessDetails"} {
+			fields["records[]."+key+"[].filePath"] = "replaced by a SHA-256 prefix, file extension kept"
+		}
+		fields["records[].codeSurvival.files[].filePath"] = "replaced by a SHA-256 prefix, file extension kept"
+		fields["records[].folderPath"] = "replaced by a SHA-256 prefix"
+		fields["records[].gitRemoteUrl"] = "replaced by a SHA-256 prefix"
+		fields["records[].title"] = "removed"
+		fields["records[].runCommandDetails[].command"] = "removed"
+		fields["records[].webAccessDetails[].url"] = "removed"
+		fields["records[].webAccessDetails[].domain"] = "replaced by a SHA-256 prefix"
+		fields["records[].webDomainCounts"] = "keys replaced by a SHA-256 prefix"
+	}
+	return fields
+}
+
+// RedactValue 依脫敏等級處理單一值，規則與 Redact 相同，供不經過 Sink 的輸出（如 OTLP span 屬性）使用
+//
+// field 為工具輸入的欄位名稱（file_path、notebook_path、command、url、pattern、query），或代表專案路徑的 cwd；
+// 返回空字串表示應移除該值
+func RedactValue(field, value, level string) string {
+	if level != config.RedactContent && level != config.RedactStrict {
+		return value
+	}
+	strict := level == config.RedactStrict
+	switch field {
+	case "command":
+		if strict {
+			return ""
+		}
+		return commandName(value)
+	case "url":
+		if strict {
+			return ""
+		}
+		return siteURL(value)
+	case "pattern", "query":
+		return ""
+	case "file_path", "notebook_path":
+		if strict {
+			return hashPath(value)
+		}
+	case "cwd":
+		if strict {
+			return hashValue(value)
+		}
+	}
+	return value
+}
+
+func redactBase(base *telemetry.ClaudeCodeAnalysisDetailBase, strict bool) {
+	if strict {
+		base.FilePath = hashPath(base.FilePath)
+	}
+}
+
+// commandName 只保留命令的程式名稱，略過前置的環境變數設定（可能包含密鑰）
+func commandName(command string) string {
+	for _, field := range strings.Fields(command) {
+		if !strings.Contains(field, "=") {
+			return filepath.Base(field)
+		}
+	}
+	return ""
+}
+
+// siteURL 只保留 URL 的 scheme 與 host
+func siteURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// hashPath 雜湊路徑但保留副檔名，仍可統計語言分布
+func hashPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	return hashValue(p) + filepath.Ext(p)
+}
+
+func hashValue(s string) string {
+	if s == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// clone 以 JSON 往返深拷貝分析結果，避免各 Sink 的脫敏互相影響
+func clone(analysis *telemetry.ClaudeCodeAnalysis) (*telemetry.ClaudeCodeAnalysis, error) {
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy analysis: %w", err)
+	}
+	var copied telemetry.ClaudeCodeAnalysis
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, fmt.Errorf("failed to copy analysis: %w", err)
+	}
+	return &copied, nil
+}
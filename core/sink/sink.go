@@ -0,0 +1,223 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/spool"
+	"claude_analysis/core/telemetry"
+)
+
+// Sink - 分析結果的輸出目的地
+type Sink interface {
+	Send(analysis *telemetry.ClaudeCodeAnalysis) error
+}
+
+// Previewer - 可以預覽實際送出內容的 Sink，用於 dry-run
+type Previewer interface {
+	Preview(analysis *telemetry.ClaudeCodeAnalysis) ([]byte, error)
+}
+
+// Configured - 套用了過濾與脫敏的 Sink
+type Configured struct {
+	Name        string
+	Type        string
+	Destination string // 送出的位置（URL 或路徑），供日誌與 dry-run 顯示
+	Sink        Sink
+	Filter      config.SinkFilter
+	Redaction   string
+	SampleRate  float64 // 明細保留的比例，1 表示不採樣
+}
+
+// Result - 單一 Sink 的發送結果
+type Result struct {
+	Name    string
+	Type    string
+	Skipped bool // 過濾後沒有任何記錄
+	Err     error
+}
+
+// Options - 建立 Sink 時由呼叫端提供的環境
+type Options struct {
+	Stdout io.Writer    // stdout sink 的輸出；hook 模式下 stdout 保留給 hook 協議
+	Spool  *spool.Spool // http sink 遇到可重送錯誤時寫入的離線佇列，nil 表示不保留
+}
+
+// Build 依設定建立所有 Sink；未設定任何 Sink 時使用 API 設定的 HTTP 上傳
+func Build(cfg *config.Config, opts Options) ([]*Configured, error) {
+	sinkConfigs := cfg.Sinks
+	if len(sinkConfigs) == 0 {
+		sinkConfigs = []config.SinkConfig{{Type: config.SinkHTTP}}
+	}
+
+	var sinks []*Configured
+	names := make(map[string]int)
+	for i, sc := range sinkConfigs {
+		s, err := build(cfg, sc, opts)
+		if err != nil {
+			return nil, fmt.Errorf("sink %d (%s): %w", i, sc.Type, err)
+		}
+		redaction := sc.Redaction
+		switch redaction {
+		case "":
+			redaction = config.RedactNone
+		case config.RedactNone, config.RedactContent, config.RedactStrict:
+		default:
+			return nil, fmt.Errorf("sink %d (%s): unknown redaction level %q", i, sc.Type, redaction)
+		}
+
+		name := sc.Name
+		if name == "" {
+			name = sc.Type
+		}
+		if names[name]++; names[name] > 1 {
+			name = fmt.Sprintf("%s#%d", name, names[name])
+		}
+		// Prometheus 以明細計算行數，只輸出計數器，不需要採樣
+		sampleRate := cfg.Submission.DetailSampleRate
+		if sc.Type == config.SinkPrometheus {
+			sampleRate = 1
+		}
+		sinks = append(sinks, &Configured{
+			Name:        name,
+			Type:        sc.Type,
+			Destination: destination(cfg, sc),
+			Sink:        s,
+			Filter:      sc.Filter,
+			Redaction:   redaction,
+			SampleRate:  sampleRate,
+		})
+	}
+	return sinks, nil
+}
+
+func build(cfg *config.Config, sc config.SinkConfig, opts Options) (Sink, error) {
+	switch sc.Type {
+	case config.SinkHTTP:
+		return NewHTTP(cfg, sc.Endpoint, opts.Spool), nil
+	case config.SinkFile:
+		if sc.Path == "" {
+			return nil, fmt.Errorf("file sink requires a path")
+		}
+		return &File{Path: expandHome(sc.Path)}, nil
+	case config.SinkStdout:
+		return &Writer{W: opts.Stdout}, nil
+	case config.SinkWebhook:
+		return NewWebhook(cfg, sc.Endpoint, sc.Template, sc.Headers)
+	case config.SinkPrometheus:
+		if sc.Path == "" && sc.Endpoint == "" {
+			return nil, fmt.Errorf("prometheus sink requires a textfile directory (path) or a Pushgateway URL (endpoint)")
+		}
+		return NewPrometheus(cfg, sc), nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", sc.Type)
+	}
+}
+
+// destination 描述 Sink 的輸出位置
+func destination(cfg *config.Config, sc config.SinkConfig) string {
+	switch sc.Type {
+	case config.SinkHTTP:
+		if sc.Endpoint != "" {
+			return sc.Endpoint
+		}
+		return cfg.API.Endpoint
+	case config.SinkFile:
+		return expandHome(sc.Path)
+	case config.SinkPrometheus:
+		return strings.Trim(expandHome(sc.Path)+" "+sc.Endpoint, " ")
+	default:
+		return sc.Endpoint
+	}
+}
+
+// Dispatch 將同一份分析結果送到每個 Sink；各 Sink 的失敗互不影響
+func Dispatch(sinks []*Configured, analysis *telemetry.ClaudeCodeAnalysis) []Result {
+	var results []Result
+	for _, s := range sinks {
+		result := Result{Name: s.Name, Type: s.Type}
+		prepared, err := s.Prepare(analysis)
+		switch {
+		case err != nil:
+			result.Err = err
+		case prepared == nil:
+			result.Skipped = true
+		default:
+			result.Err = s.Sink.Send(prepared)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Prepare 返回過濾、採樣並脫敏後的副本，即實際交給 Sink 的內容；沒有記錄符合過濾條件時返回 nil
+func (s *Configured) Prepare(analysis *telemetry.ClaudeCodeAnalysis) (*telemetry.ClaudeCodeAnalysis, error) {
+	copied, err := clone(analysis)
+	if err != nil {
+		return nil, err
+	}
+	var records []telemetry.ClaudeCodeAnalysisRecord
+	for _, record := range copied.Records {
+		if selected(s.Filter, record) {
+			records = append(records, record)
+		}
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	copied.Records = records
+	telemetry.SampleDetails(copied, s.SampleRate)
+	Redact(copied, s.Redaction)
+	return copied, nil
+}
+
+// selected 判斷記錄是否符合過濾條件：符合 Include（未設定時視為全部符合）且不符合 Exclude
+func selected(filter config.SinkFilter, record telemetry.ClaudeCodeAnalysisRecord) bool {
+	if len(filter.Include) > 0 && !matches(filter.Include, record) {
+		return false
+	}
+	return !matches(filter.Exclude, record)
+}
+
+// matches 判斷 folderPath 或 gitRemoteUrl 是否符合任一 pattern
+func matches(patterns []string, record telemetry.ClaudeCodeAnalysisRecord) bool {
+	for _, pattern := range patterns {
+		for _, value := range []string{record.FolderPath, record.GitRemoteURL} {
+			if value == "" {
+				continue
+			}
+			// glob 比對，或以目錄前綴比對子目錄
+			if globMatch(pattern, value) || strings.HasPrefix(value, strings.TrimSuffix(pattern, "/")+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globMatch 比對 glob，與 path.Match 不同的是 * 也會匹配路徑分隔符，方便比對 URL
+func globMatch(pattern, value string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+	ok, _ := regexp.MatchString("^"+expr+"$", value)
+	return ok
+}
+
+// expandHome 展開路徑開頭的 ~/，讓設定檔可以在不同使用者間共用
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	// 保留結尾的分隔符，它表示「每天一個檔案的目錄」
+	return home + string(filepath.Separator) + rest
+}
@@ -0,0 +1,363 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/spool"
+	"claude_analysis/core/telemetry"
+)
+
+func testAnalysis() *telemetry.ClaudeCodeAnalysis {
+	base := telemetry.ClaudeCodeAnalysisDetailBase{FilePath: "/work/acme/api/main.go", LineCount: 2}
+	analysis := &telemetry.ClaudeCodeAnalysis{
+		User:          "alice",
+		MachineID:     "m1",
+		SchemaVersion: telemetry.SchemaVersion,
+		Records: []telemetry.ClaudeCodeAnalysisRecord{
+			{
+				TaskID:             "s1",
+				FolderPath:         "/work/acme/api",
+				GitRemoteURL:       "https://github.com/acme/api.git",
+				Title:              "Fix login",
+				TotalWriteLines:    2,
+				WriteToFileDetails: []telemetry.ClaudeCodeAnalysisWriteDetail{{ClaudeCodeAnalysisDetailBase: base, Content: "package main\n"}},
+				ApplyDiffDetails:   []telemetry.ClaudeCodeAnalysisApplyDiffDetail{{ClaudeCodeAnalysisDetailBase: base, OldString: "a", NewString: "b"}},
+				RunCommandDetails:  []telemetry.ClaudeCodeAnalysisRunCommandDetail{{Command: "TOKEN=abc /usr/bin/curl -H secret https://x", Description: "call api"}},
+				WebAccessDetails:   []telemetry.ClaudeCodeAnalysisWebAccessDetail{{URL: "https://docs.example.com/a?q=1", Domain: "docs.example.com", Query: "secret"}},
+				WebDomainCounts:    map[string]int{"docs.example.com": 2},
+				PolicyDecisions:    []telemetry.ClaudeCodeAnalysisPolicyDecision{{ToolName: "Bash", RuleID: "no-rm", Decision: "deny", Reason: "blocked rm -rf /srv/secret", Target: "rm -rf /srv/secret"}},
+			},
+			{TaskID: "s2", FolderPath: "/home/alice/personal"},
+		},
+	}
+	// 與 parser 的輸出一致，未使用的明細為空列表而不是 null
+	for i := range analysis.Records {
+		fillEmpty(&analysis.Records[i])
+	}
+	return analysis
+}
+
+func fillEmpty(record *telemetry.ClaudeCodeAnalysisRecord) {
+	if record.WriteToFileDetails == nil {
+		record.WriteToFileDetails = []telemetry.ClaudeCodeAnalysisWriteDetail{}
+	}
+	if record.ReadFileDetails == nil {
+		record.ReadFileDetails = []telemetry.ClaudeCodeAnalysisReadDetail{}
+	}
+	if record.ApplyDiffDetails == nil {
+		record.ApplyDiffDetails = []telemetry.ClaudeCodeAnalysisApplyDiffDetail{}
+	}
+	if record.RunCommandDetails == nil {
+		record.RunCommandDetails = []telemetry.ClaudeCodeAnalysisRunCommandDetail{}
+	}
+	if record.WebAccessDetails == nil {
+		record.WebAccessDetails = []telemetry.ClaudeCodeAnalysisWebAccessDetail{}
+	}
+	if record.WebDomainCounts == nil {
+		record.WebDomainCounts = map[string]int{}
+	}
+	if record.ToolLatencies == nil {
+		record.ToolLatencies = map[string]telemetry.ClaudeCodeAnalysisToolLatency{}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	cfg := config.Default()
+	sinks, err := Build(cfg, Options{})
+	if err != nil || len(sinks) != 1 || sinks[0].Type != config.SinkHTTP || sinks[0].Redaction != config.RedactNone {
+		t.Fatalf("expected a single default HTTP sink, got %+v / %v", sinks, err)
+	}
+
+	cfg.Sinks = []config.SinkConfig{{Type: config.SinkStdout}, {Type: config.SinkStdout}, {Type: config.SinkFile, Path: t.TempDir()}}
+	sinks, err = Build(cfg, Options{})
+	if err != nil || sinks[0].Name != "stdout" || sinks[1].Name != "stdout#2" {
+		t.Errorf("unexpected names: %+v / %v", sinks, err)
+	}
+
+	for _, bad := range []config.SinkConfig{
+		{Type: "kafka"},
+		{Type: config.SinkFile},
+		{Type: config.SinkWebhook},
+		{Type: config.SinkWebhook, Endpoint: "http://x", Template: "{{.Missing"},
+		{Type: config.SinkStdout, Redaction: "partial"},
+		{Type: config.SinkPrometheus},
+	} {
+		cfg.Sinks = []config.SinkConfig{bad}
+		if _, err := Build(cfg, Options{}); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestDispatch_FilterAndRedaction(t *testing.T) {
+	dir := t.TempDir()
+	var stdout bytes.Buffer
+	cfg := config.Default()
+	cfg.Sinks = []config.SinkConfig{
+		{Type: config.SinkFile, Path: dir + "/", Filter: config.SinkFilter{Include: []string{"*github.com/acme/*"}}},
+		{Type: config.SinkStdout, Redaction: config.RedactStrict, Filter: config.SinkFilter{Exclude: []string{"/home/alice"}}},
+		{Type: config.SinkStdout, Name: "nothing", Filter: config.SinkFilter{Include: []string{"/nowhere"}}},
+	}
+	sinks, err := Build(cfg, Options{Stdout: &stdout})
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	sinks[0].Sink.(*File).now = func() time.Time { return time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC) }
+
+	analysis := testAnalysis()
+	results := Dispatch(sinks, analysis)
+	for _, result := range results[:2] {
+		if result.Err != nil || result.Skipped {
+			t.Errorf("sink %s: %+v", result.Name, result)
+		}
+	}
+	if !results[2].Skipped {
+		t.Errorf("sink with no matching records should be skipped")
+	}
+
+	// 本地副本保留完整內容，且只有符合 include 的記錄
+	data, err := os.ReadFile(filepath.Join(dir, "claude_analysis-2025-03-01.ndjson"))
+	if err != nil {
+		t.Fatalf("file sink output missing: %v", err)
+	}
+	var local telemetry.ClaudeCodeAnalysis
+	if err := json.Unmarshal(data, &local); err != nil {
+		t.Fatalf("invalid NDJSON line: %v", err)
+	}
+	if len(local.Records) != 1 || local.Records[0].WriteToFileDetails[0].Content != "package main\n" {
+		t.Errorf("unexpected local copy: %+v", local.Records)
+	}
+
+	var redacted telemetry.ClaudeCodeAnalysis
+	if err := json.Unmarshal(stdout.Bytes(), &redacted); err != nil {
+		t.Fatalf("invalid stdout line: %v", err)
+	}
+	if len(redacted.Records) != 1 || strings.Contains(stdout.String(), "package main") || strings.Contains(stdout.String(), "/work/acme") {
+		t.Errorf("strict redaction leaked content: %s", stdout.String())
+	}
+
+	// 各 sink 使用獨立副本，原始分析結果不受脫敏影響
+	if analysis.Records[0].WriteToFileDetails[0].Content == "" || len(analysis.Records) != 2 {
+		t.Errorf("input analysis was modified")
+	}
+}
+
+func TestRedact_Levels(t *testing.T) {
+	content := testAnalysis()
+	Redact(content, config.RedactContent)
+	record := content.Records[0]
+	if record.WriteToFileDetails[0].Content != "" || record.ApplyDiffDetails[0].NewString != "" {
+		t.Errorf("content not removed")
+	}
+	if record.RunCommandDetails[0].Command != "curl" || record.RunCommandDetails[0].Description != "" {
+		t.Errorf("command should keep only the program name: %+v", record.RunCommandDetails[0])
+	}
+	web := record.WebAccessDetails[0]
+	if web.URL != "https://docs.example.com" || web.Query != "" || web.Domain != "docs.example.com" {
+		t.Errorf("unexpected web redaction: %+v", web)
+	}
+	if record.WriteToFileDetails[0].FilePath != "/work/acme/api/main.go" || record.WriteToFileDetails[0].LineCount != 2 || record.Title == "" {
+		t.Errorf("content level should keep paths and statistics")
+	}
+	if decision := record.PolicyDecisions[0]; decision.Reason != "" || decision.Target != "" || decision.RuleID != "no-rm" {
+		t.Errorf("policy decision should keep only the rule and outcome: %+v", decision)
+	}
+
+	strict := testAnalysis()
+	Redact(strict, config.RedactStrict)
+	record = strict.Records[0]
+	path := record.WriteToFileDetails[0].FilePath
+	if !strings.HasPrefix(path, "sha256:") || !strings.HasSuffix(path, ".go") || path != record.ApplyDiffDetails[0].FilePath {
+		t.Errorf("file path should be hashed consistently with extension kept: %s", path)
+	}
+	if record.Title != "" || record.RunCommandDetails[0].Command != "" || record.WebAccessDetails[0].URL != "" || !strings.HasPrefix(record.FolderPath, "sha256:") {
+		t.Errorf("strict redaction incomplete: %+v", record)
+	}
+	domain := record.WebAccessDetails[0].Domain
+	if !strings.HasPrefix(domain, "sha256:") || record.WebDomainCounts[domain] != 2 || len(record.WebDomainCounts) != 1 {
+		t.Errorf("domains should be hashed consistently: %q %v", domain, record.WebDomainCounts)
+	}
+	fields := RedactedFields(config.RedactStrict)
+	for _, field := range []string{"records[].webAccessDetails[].domain", "records[].webDomainCounts", "records[].policyDecisions[].reason"} {
+		if fields[field] == "" {
+			t.Errorf("RedactedFields should list %s", field)
+		}
+	}
+}
+
+func TestWebhook_Template(t *testing.T) {
+	var body, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body, auth = string(data), r.Header.Get("X-Token")
+	}))
+	defer server.Close()
+
+	template := `{"text": {{printf "%s finished %d session(s)" .User (len .Records) | json}}}`
+	webhook, err := NewWebhook(config.Default(), server.URL, template, map[string]string{"X-Token": "t"})
+	if err != nil {
+		t.Fatalf("NewWebhook error: %v", err)
+	}
+	if err := webhook.Send(testAnalysis()); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if body != `{"text": "alice finished 2 session(s)"}` || auth != "t" {
+		t.Errorf("unexpected webhook request: %s / %s", body, auth)
+	}
+}
+
+func TestHTTP_SpoolsRetryableFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.API.MaxRetries = 0
+	sp := spool.New(t.TempDir())
+	analysis := testAnalysis()
+	analysis.IdempotencyKey = "key"
+	if err := NewHTTP(cfg, server.URL, sp).Send(analysis); err == nil {
+		t.Fatalf("expected an error from the failing collector")
+	}
+
+	entries, _ := sp.List()
+	if len(entries) != 1 || entries[0].Endpoint != server.URL || entries[0].IdempotencyKey != "key" {
+		t.Fatalf("expected the payload to be spooled, got %+v", entries)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(entries[0].Payload))
+	if !scanner.Scan() || !strings.Contains(scanner.Text(), `"taskId":"s1"`) {
+		t.Errorf("spooled payload mismatch")
+	}
+}
+
+func TestHTTP_RejectsInvalidPayload(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	sp := spool.New(t.TempDir())
+	analysis := testAnalysis()
+	analysis.Records[1].ReadFileDetails = nil
+	err := NewHTTP(config.Default(), server.URL, sp).Send(analysis)
+	var validationErr *telemetry.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if entries, _ := sp.List(); requests != 0 || len(entries) != 0 {
+		t.Errorf("invalid payload should be neither sent nor spooled: %d requests, %d spooled", requests, len(entries))
+	}
+}
+
+func TestPrometheus_Textfile(t *testing.T) {
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	textfileDir := t.TempDir()
+	cfg.Sinks = []config.SinkConfig{{Type: config.SinkPrometheus, Path: textfileDir, Filter: config.SinkFilter{Include: []string{"/work"}}}}
+	sinks, err := Build(cfg, Options{})
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+
+	// 同一工作階段重複送出不會重複計數
+	for i := 0; i < 2; i++ {
+		if result := Dispatch(sinks, testAnalysis())[0]; result.Err != nil {
+			t.Fatalf("Dispatch error: %v", result.Err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(textfileDir, "claude_analysis.prom"))
+	if err != nil {
+		t.Fatalf("textfile missing: %v", err)
+	}
+	if !strings.Contains(string(data), `claude_code_sessions_total{repo="github.com/acme/api",user="alice"} 1`) ||
+		!strings.Contains(string(data), `claude_code_lines_total{kind="written",repo="github.com/acme/api",user="alice"} 2`) {
+		t.Errorf("unexpected textfile:\n%s", data)
+	}
+	if strings.Contains(string(data), "personal") {
+		t.Errorf("filtered record should not be counted:\n%s", data)
+	}
+}
+
+func TestPrepare_Destination(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.API.Endpoint = "https://collector.example.com/upload"
+	cfg.Sinks = []config.SinkConfig{
+		{Type: config.SinkHTTP, Redaction: config.RedactStrict},
+		{Type: config.SinkHTTP, Name: "backup", Endpoint: "https://backup.example.com", Filter: config.SinkFilter{Include: []string{"nomatch"}}},
+	}
+	sinks, err := Build(cfg, Options{Stdout: io.Discard})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if sinks[0].Destination != cfg.API.Endpoint || sinks[1].Destination != "https://backup.example.com" {
+		t.Errorf("destinations = %q, %q", sinks[0].Destination, sinks[1].Destination)
+	}
+
+	analysis := testAnalysis()
+	prepared, err := sinks[0].Prepare(analysis)
+	if err != nil || prepared == nil || !strings.HasPrefix(prepared.Records[0].FolderPath, "sha256:") {
+		t.Fatalf("Prepare = %+v, %v", prepared, err)
+	}
+	if analysis.Records[0].FolderPath == prepared.Records[0].FolderPath {
+		t.Errorf("Prepare modified its input")
+	}
+	if prepared, err := sinks[1].Prepare(analysis); prepared != nil || err != nil {
+		t.Errorf("filtered sink should prepare nothing, got %+v, %v", prepared, err)
+	}
+}
+
+func TestRedactedFields(t *testing.T) {
+	if fields := RedactedFields(config.RedactNone); fields != nil {
+		t.Errorf("no redaction should report no fields, got %v", fields)
+	}
+	content, strict := RedactedFields(config.RedactContent), RedactedFields(config.RedactStrict)
+	for key := range content {
+		if _, ok := strict[key]; !ok {
+			t.Errorf("strict redaction should also change %s", key)
+		}
+		if _, ok := telemetry.FieldSources()[key]; !ok {
+			t.Errorf("%s is not a documented payload field", key)
+		}
+	}
+	if strict["records[].folderPath"] == "" || content["records[].folderPath"] != "" {
+		t.Errorf("folderPath is only hashed by strict redaction")
+	}
+}
+
+func TestPrepare_SamplesDetailsExceptPrometheus(t *testing.T) {
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	cfg.Submission.DetailSampleRate = 0.5
+	cfg.Sinks = []config.SinkConfig{{Type: config.SinkFile, Path: filepath.Join(t.TempDir(), "out.ndjson")}, {Type: config.SinkPrometheus, Path: t.TempDir()}}
+	sinks, err := Build(cfg, Options{})
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	if sinks[0].SampleRate != 0.5 || sinks[1].SampleRate != 1 {
+		t.Fatalf("sample rates = %v, %v", sinks[0].SampleRate, sinks[1].SampleRate)
+	}
+
+	sampled, _ := sinks[0].Prepare(testAnalysis())
+	if sampled.Records[0].DetailSampleRate != 0.5 || sampled.Records[0].TotalWriteLines != testAnalysis().Records[0].TotalWriteLines {
+		t.Errorf("file sink should receive sampled details with exact totals: %+v", sampled.Records[0])
+	}
+	// Prometheus 以明細計算行數，必須收到完整的明細
+	full, _ := sinks[1].Prepare(testAnalysis())
+	if full.Records[0].DetailSampleRate != 0 || len(full.Records[0].ApplyDiffDetails) != len(testAnalysis().Records[0].ApplyDiffDetails) {
+		t.Errorf("prometheus sink should not be sampled: %+v", full.Records[0])
+	}
+}
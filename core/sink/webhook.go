@@ -0,0 +1,97 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/httpclient"
+	"claude_analysis/core/telemetry"
+)
+
+// Webhook - 以自訂模板產生請求內容的 Sink，例如推送到聊天室
+// 模板的資料為 ClaudeCodeAnalysis，另外提供 json 與 join 函數；模板為空時送出完整的 JSON
+type Webhook struct {
+	URL      string
+	Headers  map[string]string
+	template *template.Template
+	client   *http.Client
+}
+
+// NewWebhook 建立 Webhook Sink
+func NewWebhook(cfg *config.Config, url, body string, headers map[string]string) (*Webhook, error) {
+	if url == "" {
+		return nil, fmt.Errorf("webhook sink requires an endpoint")
+	}
+	w := &Webhook{
+		URL:     url,
+		Headers: headers,
+		client: httpclient.New(httpclient.Options{
+			Timeout:            time.Duration(cfg.API.Timeout),
+			InsecureSkipVerify: cfg.API.SkipSSLVerify || cfg.API.InsecureSkipTLS,
+			Proxy:              cfg.Proxy,
+		}),
+	}
+	if body != "" {
+		tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+			"json": func(v interface{}) (string, error) {
+				data, err := json.Marshal(v)
+				return string(data), err
+			},
+			"join": strings.Join,
+		}).Parse(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook template: %w", err)
+		}
+		w.template = tmpl
+	}
+	return w, nil
+}
+
+// Send 渲染模板並 POST 到 webhook
+func (w *Webhook) Send(analysis *telemetry.ClaudeCodeAnalysis) error {
+	body, err := w.render(analysis)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// Preview 返回將會 POST 的請求內容
+func (w *Webhook) Preview(analysis *telemetry.ClaudeCodeAnalysis) ([]byte, error) {
+	return w.render(analysis)
+}
+
+func (w *Webhook) render(analysis *telemetry.ClaudeCodeAnalysis) ([]byte, error) {
+	if w.template == nil {
+		return jsonMarshal(analysis)
+	}
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, analysis); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
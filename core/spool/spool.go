@@ -0,0 +1,271 @@
+package spool
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"claude_analysis/core/filelock"
+)
+
+// 預設的重送策略
+const (
+	DefaultMaxAge      = 7 * 24 * time.Hour
+	DefaultMaxBytes    = 50 << 20
+	DefaultBaseBackoff = time.Minute
+	DefaultMaxBackoff  = 6 * time.Hour
+
+	lockName       = ".lock"
+	lockStaleAfter = 10 * time.Minute
+)
+
+// ErrBusy 表示另一個行程正在處理 spool
+var ErrBusy = errors.New("spool is being drained by another process")
+
+// ErrRejected 由 send 包裝返回，表示 payload 被永久拒絕，重送也不會成功，應直接刪除
+var ErrRejected = errors.New("payload rejected")
+
+// Entry - 一筆尚未送出的 payload
+type Entry struct {
+	ID             string          `json:"id"`
+	Endpoint       string          `json:"endpoint"`
+	IdempotencyKey string          `json:"idempotencyKey,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	LastError      string          `json:"lastError,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// Spool - 以目錄保存未送出 payload 的磁碟佇列，每筆一個檔案
+type Spool struct {
+	Dir         string
+	MaxAge      time.Duration // 超過此時間的 payload 直接丟棄
+	MaxBytes    int64         // 超過此大小時從最舊的 payload 開始丟棄
+	BaseBackoff time.Duration // 第一次失敗後的等待時間，之後每次加倍
+	MaxBackoff  time.Duration
+}
+
+// DrainResult - 一次 Drain 的結果
+type DrainResult struct {
+	Sent      int    `json:"sent"`
+	Failed    int    `json:"failed"`
+	Rejected  int    `json:"rejected"`
+	Expired   int    `json:"expired"`
+	Deferred  int    `json:"deferred"` // 尚未到重試時間
+	Remaining int    `json:"remaining"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Dir 返回 spool 目錄
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "spool")
+}
+
+// New 以預設策略建立位於 dataDir/spool 的 spool
+func New(dataDir string) *Spool {
+	return &Spool{
+		Dir:         Dir(dataDir),
+		MaxAge:      DefaultMaxAge,
+		MaxBytes:    DefaultMaxBytes,
+		BaseBackoff: DefaultBaseBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+	}
+}
+
+// Add 將 payload 寫入 spool，並在取得鎖時套用大小上限
+func (s *Spool) Add(endpoint, idempotencyKey string, payload []byte, now time.Time) (*Entry, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool dir: %w", err)
+	}
+	entry := &Entry{
+		ID:             newID(now),
+		Endpoint:       endpoint,
+		IdempotencyKey: idempotencyKey,
+		CreatedAt:      now,
+		NextAttemptAt:  now,
+		Payload:        json.RawMessage(payload),
+	}
+	if err := s.write(entry); err != nil {
+		return nil, err
+	}
+
+	// 其他行程正在 Drain 時略過清理，由下一次呼叫處理
+	if lock, err := filelock.TryLock(s.lockPath(), lockStaleAfter); err == nil {
+		defer lock.Release()
+		s.enforceLimits(now, &DrainResult{})
+	}
+	return entry, nil
+}
+
+// Drain 依序重送到期的 payload；成功或被永久拒絕即刪除，失敗時延長退避時間並停止本輪以免連續逾時
+// force 為 true 時忽略退避時間
+func (s *Spool) Drain(send func(*Entry) error, force bool, now time.Time) (*DrainResult, error) {
+	lock, err := filelock.TryLock(s.lockPath(), lockStaleAfter)
+	if err == filelock.ErrLocked {
+		return nil, ErrBusy
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	result := &DrainResult{}
+	s.enforceLimits(now, result)
+
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !force && now.Before(entry.NextAttemptAt) {
+			result.Deferred++
+			continue
+		}
+		err := send(entry)
+		if errors.Is(err, ErrRejected) {
+			result.Rejected++
+			result.LastError = err.Error()
+			_ = os.Remove(s.entryPath(entry.ID))
+			continue
+		}
+		if err != nil {
+			result.Failed++
+			result.LastError = err.Error()
+			entry.Attempts++
+			entry.LastError = err.Error()
+			entry.NextAttemptAt = now.Add(s.backoff(entry.Attempts))
+			if writeErr := s.write(entry); writeErr != nil {
+				return nil, writeErr
+			}
+			break
+		}
+		if err := os.Remove(s.entryPath(entry.ID)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove spool entry: %w", err)
+		}
+		result.Sent++
+	}
+	result.Remaining = len(entries) - result.Sent - result.Rejected
+	return result, nil
+}
+
+// List 返回所有 payload，最舊的在前；損壞的檔案會被略過
+func (s *Spool) List() ([]*Entry, error) {
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+	var entries []*Entry
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(s.Dir, file.Name()))
+		if err != nil {
+			continue
+		}
+		var entry Entry
+		if json.Unmarshal(data, &entry) != nil {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+// enforceLimits 刪除過期、損壞以及超過大小上限的 payload，需持有鎖
+func (s *Spool) enforceLimits(now time.Time, result *DrainResult) {
+	files, err := s.files()
+	if err != nil {
+		return
+	}
+	var total int64
+	kept := files[:0]
+	for _, file := range files {
+		path := filepath.Join(s.Dir, file.Name())
+		data, err := os.ReadFile(path)
+		var entry Entry
+		if err != nil || json.Unmarshal(data, &entry) != nil || now.Sub(entry.CreatedAt) > s.MaxAge {
+			_ = os.Remove(path)
+			result.Expired++
+			continue
+		}
+		total += int64(len(data))
+		kept = append(kept, file)
+	}
+	for i := 0; s.MaxBytes > 0 && total > s.MaxBytes && i < len(kept); i++ {
+		path := filepath.Join(s.Dir, kept[i].Name())
+		if info, err := os.Stat(path); err == nil {
+			total -= info.Size()
+		}
+		_ = os.Remove(path)
+		result.Expired++
+	}
+}
+
+// files 返回依名稱（即建立時間）排序的 payload 檔案
+func (s *Spool) files() ([]os.DirEntry, error) {
+	dirEntries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool dir: %w", err)
+	}
+	var files []os.DirEntry
+	for _, entry := range dirEntries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, entry)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	return files, nil
+}
+
+// write 以暫存檔加 rename 寫入，讀取端不會看到寫到一半的檔案
+func (s *Spool) write(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool entry: %w", err)
+	}
+	tmp := s.entryPath(entry.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	if err := os.Rename(tmp, s.entryPath(entry.ID)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write spool entry: %w", err)
+	}
+	return nil
+}
+
+// backoff 返回第 attempts 次失敗後的等待時間
+func (s *Spool) backoff(attempts int) time.Duration {
+	wait := s.BaseBackoff
+	for i := 1; i < attempts && wait < s.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > s.MaxBackoff {
+		wait = s.MaxBackoff
+	}
+	return wait
+}
+
+func (s *Spool) entryPath(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
+
+func (s *Spool) lockPath() string {
+	return filepath.Join(s.Dir, lockName)
+}
+
+// newID 以時間戳為前綴，使檔名排序即為建立順序
+func newID(now time.Time) string {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%020d-%s", now.UnixNano(), hex.EncodeToString(suffix))
+}
@@ -0,0 +1,129 @@
+package spool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claude_analysis/core/filelock"
+)
+
+func TestDrain_BackoffAndRetry(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, payload := range []string{`{"n":1}`, `{"n":2}`} {
+		if _, err := s.Add("http://collector", "key", []byte(payload), now.Add(time.Duration(i)*time.Millisecond)); err != nil {
+			t.Fatalf("Add error: %v", err)
+		}
+	}
+
+	// 第一次失敗後停止本輪，只嘗試最舊的一筆
+	var sent []string
+	failing := func(e *Entry) error { sent = append(sent, string(e.Payload)); return errors.New("offline") }
+	result, err := s.Drain(failing, false, now)
+	if err != nil {
+		t.Fatalf("Drain error: %v", err)
+	}
+	if result.Failed != 1 || result.Remaining != 2 || len(sent) != 1 {
+		t.Errorf("unexpected result %+v, sent %v", result, sent)
+	}
+	entries, _ := s.List()
+	if entries[0].Attempts != 1 || !entries[0].NextAttemptAt.Equal(now.Add(DefaultBaseBackoff)) || entries[0].LastError != "offline" {
+		t.Errorf("backoff not recorded: %+v", entries[0])
+	}
+
+	// 退避時間內不重試第一筆
+	sent = nil
+	ok := func(e *Entry) error { sent = append(sent, string(e.Payload)); return nil }
+	result, _ = s.Drain(ok, false, now.Add(time.Second))
+	if result.Sent != 1 || result.Deferred != 1 || result.Remaining != 1 || sent[0] != `{"n":2}` {
+		t.Errorf("unexpected result %+v, sent %v", result, sent)
+	}
+
+	// force 忽略退避
+	result, _ = s.Drain(ok, true, now.Add(2*time.Second))
+	if result.Sent != 1 || result.Remaining != 0 {
+		t.Errorf("unexpected forced result %+v", result)
+	}
+}
+
+func TestDrain_DropsRejected(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now()
+	for i, payload := range []string{`{"bad":true}`, `{"ok":true}`} {
+		if _, err := s.Add("e", "", []byte(payload), now.Add(time.Duration(i)*time.Millisecond)); err != nil {
+			t.Fatalf("Add error: %v", err)
+		}
+	}
+
+	send := func(e *Entry) error {
+		if string(e.Payload) == `{"bad":true}` {
+			return fmt.Errorf("%w: status 400", ErrRejected)
+		}
+		return nil
+	}
+	result, err := s.Drain(send, false, now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("Drain error: %v", err)
+	}
+	if result.Rejected != 1 || result.Sent != 1 || result.Remaining != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if entries, _ := s.List(); len(entries) != 0 {
+		t.Errorf("expected empty spool, got %d entries", len(entries))
+	}
+}
+
+func TestBackoff_DoublesAndCaps(t *testing.T) {
+	s := New(t.TempDir())
+	if s.backoff(1) != time.Minute || s.backoff(3) != 4*time.Minute || s.backoff(50) != DefaultMaxBackoff {
+		t.Errorf("unexpected backoff: %v %v %v", s.backoff(1), s.backoff(3), s.backoff(50))
+	}
+}
+
+func TestLimits_MaxAgeAndMaxBytes(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := s.Add("e", "", []byte(`{"old":true}`), now.Add(-8*24*time.Hour)); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	s.MaxBytes = 400
+	big := []byte(`{"data":"0123456789012345678901234567890123456789"}`)
+	for i := 0; i < 5; i++ {
+		if _, err := s.Add("e", "", big, now.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatalf("Add error: %v", err)
+		}
+	}
+
+	entries, _ := s.List()
+	if len(entries) == 0 || len(entries) >= 5 {
+		t.Fatalf("expected size cap to drop some entries, got %d", len(entries))
+	}
+	if !entries[len(entries)-1].CreatedAt.Equal(now.Add(4 * time.Second)) {
+		t.Errorf("newest entry should be kept")
+	}
+	for _, e := range entries {
+		if e.CreatedAt.Before(now) {
+			t.Errorf("expired entry kept: %+v", e)
+		}
+	}
+}
+
+func TestDrain_Locked(t *testing.T) {
+	s := New(t.TempDir())
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := filelock.TryLock(filepath.Join(s.Dir, lockName), time.Minute)
+	if err != nil {
+		t.Fatalf("TryLock error: %v", err)
+	}
+	defer lock.Release()
+
+	if _, err := s.Drain(func(*Entry) error { return nil }, true, time.Now()); err != ErrBusy {
+		t.Errorf("expected ErrBusy, got %v", err)
+	}
+}
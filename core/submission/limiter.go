@@ -0,0 +1,219 @@
+package submission
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/filelock"
+	"claude_analysis/core/hook"
+)
+
+const (
+	stateName      = "submissions.json"
+	lockName       = ".submissions.lock"
+	lockStaleAfter = time.Minute
+	lockTimeout    = 5 * time.Second
+
+	// 超過此時間的上傳紀錄與待上傳工作階段不再保留
+	retention = 7 * 24 * time.Hour
+)
+
+// 限流原因
+const (
+	ReasonMinInterval = "min_interval"
+	ReasonDailyLimit  = "daily_limit"
+)
+
+// Decision - 一次上傳請求的判斷結果
+type Decision struct {
+	Allowed bool
+	Reason  string        // 不允許時的原因
+	Folded  []*hook.Input // 允許時需要一併上傳的、先前被限流的其他工作階段
+}
+
+// Pending - 被限流、等待下一次上傳的工作階段
+type Pending struct {
+	Input       *hook.Input `json:"input"`
+	ThrottledAt time.Time   `json:"throttledAt"`
+}
+
+// state - 限流狀態檔內容
+type state struct {
+	Day      string               `json:"day"`      // 本地日期 YYYY-MM-DD
+	Count    int                  `json:"count"`    // Day 當天已上傳的次數
+	Sessions map[string]time.Time `json:"sessions"` // 各工作階段上次上傳的時間
+	Pending  map[string]Pending   `json:"pending"`
+}
+
+// Limiter - 依 SubmissionConfig 限制上傳頻率，狀態保存在 dataDir/submissions.json
+type Limiter struct {
+	Dir    string
+	Policy config.SubmissionConfig
+}
+
+// New 建立限流器
+func New(dataDir string, policy config.SubmissionConfig) *Limiter {
+	return &Limiter{Dir: dataDir, Policy: policy}
+}
+
+// Allow 判斷工作階段這次是否上傳並預留當天的一次上傳
+//
+// 不允許時將工作階段記為待上傳；允許時返回所有待上傳的其他工作階段，由呼叫者重新分析後放在同一次上傳中。
+// 每次分析的都是完整的 transcript，因此被略過的上傳不會遺漏任何統計。
+// 允許後呼叫者必須在上傳成功時呼叫 Commit、失敗時呼叫 Release：待上傳的工作階段在 Commit 之前都會保留。
+func (l *Limiter) Allow(input *hook.Input, now time.Time) (*Decision, error) {
+	var decision *Decision
+	err := l.update(now, func(st *state) {
+		decision = l.decide(st, input.SessionID, now)
+		if decision.Allowed {
+			decision.Folded = folded(st, input.SessionID)
+			st.Count++
+		} else {
+			st.Pending[input.SessionID] = Pending{Input: input, ThrottledAt: now}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decision, nil
+}
+
+// Commit 記錄上傳成功：input 與實際一併上傳的 included 不再待上傳，最小間隔從現在起算
+func (l *Limiter) Commit(input *hook.Input, included []*hook.Input, now time.Time) error {
+	return l.update(now, func(st *state) {
+		for _, uploaded := range append([]*hook.Input{input}, included...) {
+			st.Sessions[uploaded.SessionID] = now
+			delete(st.Pending, uploaded.SessionID)
+		}
+	})
+}
+
+// Release 記錄上傳失敗：歸還 Allow 預留的次數，並將 input 記為待上傳，隨下一次允許的上傳重送
+func (l *Limiter) Release(input *hook.Input, now time.Time) error {
+	return l.update(now, func(st *state) {
+		if st.Day == now.Format("2006-01-02") && st.Count > 0 {
+			st.Count--
+		}
+		st.Pending[input.SessionID] = Pending{Input: input, ThrottledAt: now}
+	})
+}
+
+// update 在持有鎖的情況下讀取、修改並保存狀態
+func (l *Limiter) update(now time.Time, fn func(st *state)) error {
+	if err := os.MkdirAll(l.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+	// 多個工作階段可能同時結束，讀改寫期間持有鎖
+	lock, err := filelock.Acquire(filepath.Join(l.Dir, lockName), lockStaleAfter, lockTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to lock submission state: %w", err)
+	}
+	defer lock.Release()
+
+	st, err := l.load()
+	if err != nil {
+		return err
+	}
+	prune(st, now)
+	fn(st)
+	return l.save(st)
+}
+
+// Check 判斷工作階段這次是否會上傳，不更新狀態（供 dry-run 使用）
+func (l *Limiter) Check(sessionID string, now time.Time) (*Decision, error) {
+	st, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	prune(st, now)
+	decision := l.decide(st, sessionID, now)
+	if decision.Allowed {
+		decision.Folded = folded(st, sessionID)
+	}
+	return decision, nil
+}
+
+// folded 返回 sessionID 以外的待上傳工作階段，依工作階段 ID 排序
+func folded(st *state, sessionID string) []*hook.Input {
+	var inputs []*hook.Input
+	for id, pending := range st.Pending {
+		if id != sessionID && pending.Input != nil {
+			inputs = append(inputs, pending.Input)
+		}
+	}
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].SessionID < inputs[j].SessionID })
+	return inputs
+}
+
+// decide 套用最小間隔與每日上限，並在日期改變時重設計數
+func (l *Limiter) decide(st *state, sessionID string, now time.Time) *Decision {
+	if day := now.Format("2006-01-02"); st.Day != day {
+		st.Day, st.Count = day, 0
+	}
+	if last, ok := st.Sessions[sessionID]; ok && l.Policy.MinInterval > 0 && now.Sub(last) < time.Duration(l.Policy.MinInterval) {
+		return &Decision{Reason: ReasonMinInterval}
+	}
+	if l.Policy.MaxPerDay > 0 && st.Count >= l.Policy.MaxPerDay {
+		return &Decision{Reason: ReasonDailyLimit}
+	}
+	return &Decision{Allowed: true}
+}
+
+// prune 移除過期的上傳紀錄與待上傳工作階段（其 transcript 很可能已不存在）
+func prune(st *state, now time.Time) {
+	for id, last := range st.Sessions {
+		if now.Sub(last) > retention {
+			delete(st.Sessions, id)
+		}
+	}
+	for id, pending := range st.Pending {
+		if now.Sub(pending.ThrottledAt) > retention {
+			delete(st.Pending, id)
+		}
+	}
+}
+
+func (l *Limiter) load() (*state, error) {
+	st := &state{Sessions: map[string]time.Time{}, Pending: map[string]Pending{}}
+	data, err := os.ReadFile(filepath.Join(l.Dir, stateName))
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read submission state: %w", err)
+	}
+	// 狀態檔損壞時重新開始，否則限流會一直失效
+	if json.Unmarshal(data, st) != nil {
+		return &state{Sessions: map[string]time.Time{}, Pending: map[string]Pending{}}, nil
+	}
+	if st.Sessions == nil {
+		st.Sessions = map[string]time.Time{}
+	}
+	if st.Pending == nil {
+		st.Pending = map[string]Pending{}
+	}
+	return st, nil
+}
+
+// save 以暫存檔加 rename 寫入
+func (l *Limiter) save(st *state) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to marshal submission state: %w", err)
+	}
+	file := filepath.Join(l.Dir, stateName)
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write submission state: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write submission state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package submission
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claude_analysis/core/config"
+	"claude_analysis/core/hook"
+)
+
+func input(session string) *hook.Input {
+	return &hook.Input{SessionID: session, TranscriptPath: "/tmp/" + session + ".jsonl", HookEventName: hook.EventStop}
+}
+
+// upload 模擬一次成功的上傳：允許時連同待上傳的工作階段一起 Commit
+func upload(t *testing.T, l *Limiter, in *hook.Input, now time.Time) *Decision {
+	t.Helper()
+	d, err := l.Allow(in, now)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if d.Allowed {
+		if err := l.Commit(in, d.Folded, now); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+	}
+	return d
+}
+
+func TestAllow_MinIntervalFoldsIntoNextUpload(t *testing.T) {
+	l := New(t.TempDir(), config.SubmissionConfig{MinInterval: config.Duration(10 * time.Minute)})
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+
+	if d := upload(t, l, input("a"), now); !d.Allowed {
+		t.Fatalf("first upload should be allowed: %+v", d)
+	}
+	d, err := l.Allow(input("a"), now.Add(time.Minute))
+	if err != nil || d.Allowed || d.Reason != ReasonMinInterval {
+		t.Fatalf("second upload within the interval should be throttled: %+v, %v", d, err)
+	}
+	// 其他工作階段不受影響，並帶上被限流的工作階段
+	d = upload(t, l, input("b"), now.Add(2*time.Minute))
+	if !d.Allowed || len(d.Folded) != 1 || d.Folded[0].SessionID != "a" || d.Folded[0].TranscriptPath != "/tmp/a.jsonl" {
+		t.Fatalf("expected session a to be folded into b's upload: %+v", d)
+	}
+	// a 已隨 b 上傳，間隔從那時重新計算
+	if d := upload(t, l, input("a"), now.Add(11*time.Minute)); d.Allowed {
+		t.Errorf("session a was just uploaded with b, expected throttling")
+	}
+	if d := upload(t, l, input("a"), now.Add(13*time.Minute)); !d.Allowed || len(d.Folded) != 0 {
+		t.Errorf("expected upload without folded sessions, got %+v", d)
+	}
+}
+
+func TestAllow_DailyLimit(t *testing.T) {
+	l := New(t.TempDir(), config.SubmissionConfig{MaxPerDay: 2})
+	day := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+
+	for i, session := range []string{"a", "b"} {
+		if d := upload(t, l, input(session), day.Add(time.Duration(i)*time.Hour)); !d.Allowed {
+			t.Fatalf("upload %d should be allowed", i)
+		}
+	}
+	d, _ := l.Allow(input("c"), day.Add(3*time.Hour))
+	if d.Allowed || d.Reason != ReasonDailyLimit {
+		t.Fatalf("third upload should hit the daily limit: %+v", d)
+	}
+	if d, _ := l.Check("c", day.Add(4*time.Hour)); d.Allowed {
+		t.Errorf("Check should report the daily limit")
+	}
+
+	d, _ = l.Allow(input("d"), day.Add(24*time.Hour))
+	if !d.Allowed || len(d.Folded) != 1 || d.Folded[0].SessionID != "c" {
+		t.Fatalf("limit should reset the next day and fold session c: %+v", d)
+	}
+}
+
+func TestRelease_KeepsPendingAndQuota(t *testing.T) {
+	l := New(t.TempDir(), config.SubmissionConfig{MinInterval: config.Duration(time.Hour), MaxPerDay: 2})
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	upload(t, l, input("a"), now)
+	l.Allow(input("a"), now.Add(time.Minute))
+
+	// b 的上傳失敗：a 仍待上傳，b 也成為待上傳，預留的次數歸還
+	d, err := l.Allow(input("b"), now.Add(2*time.Minute))
+	if err != nil || !d.Allowed || len(d.Folded) != 1 {
+		t.Fatalf("expected b to be allowed with a folded: %+v, %v", d, err)
+	}
+	if err := l.Release(input("b"), now.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	d = upload(t, l, input("c"), now.Add(3*time.Minute))
+	if !d.Allowed || len(d.Folded) != 2 || d.Folded[0].SessionID != "a" || d.Folded[1].SessionID != "b" {
+		t.Fatalf("failed upload should keep a and b pending and return its quota slot: %+v", d)
+	}
+	// c 成功後 a 與 b 都已上傳，當天兩次已用完
+	if d, _ := l.Check("d", now.Add(4*time.Minute)); d.Allowed || d.Reason != ReasonDailyLimit {
+		t.Errorf("expected the daily limit after two successful uploads, got %+v", d)
+	}
+}
+
+func TestCheck_DoesNotChangeState(t *testing.T) {
+	dir := t.TempDir()
+	l := New(dir, config.SubmissionConfig{MinInterval: config.Duration(time.Hour)})
+	now := time.Now()
+	upload(t, l, input("a"), now)
+	l.Allow(input("a"), now)
+
+	for i := 0; i < 2; i++ {
+		d, err := l.Check("b", now)
+		if err != nil || !d.Allowed || len(d.Folded) != 1 {
+			t.Fatalf("Check = %+v, %v", d, err)
+		}
+	}
+	if d, _ := l.Allow(input("b"), now); len(d.Folded) != 1 {
+		t.Errorf("pending session should still be folded after Check, got %+v", d)
+	}
+}
+
+func TestAllow_NoPolicyAndCorruptState(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, stateName), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l := New(dir, config.SubmissionConfig{})
+	for i := 0; i < 3; i++ {
+		if d, err := l.Allow(input("a"), time.Now()); err != nil || !d.Allowed {
+			t.Fatalf("without limits every upload is allowed: %+v, %v", d, err)
+		}
+	}
+}
+
+func TestAllow_PrunesOldPending(t *testing.T) {
+	l := New(t.TempDir(), config.SubmissionConfig{MinInterval: config.Duration(time.Hour)})
+	now := time.Now()
+	upload(t, l, input("a"), now)
+	l.Allow(input("a"), now.Add(time.Minute))
+
+	if d, _ := l.Allow(input("b"), now.Add(retention+time.Hour)); len(d.Folded) != 0 {
+		t.Errorf("pending sessions older than the retention should be dropped, got %+v", d.Folded)
+	}
+}
@@ -0,0 +1,93 @@
+package telemetry
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"claude_analysis/core/config"
+)
+
+// gaisfHeader 是 GAISF 閘道使用的 API key header
+const gaisfHeader = "api-key"
+
+// authenticator 在每個請求上套用設定的認證方式
+type authenticator struct {
+	header string
+	value  string
+}
+
+// newAuth 依設定準備認證：返回請求 header 以及 mTLS 用的客戶端憑證
+// 設定有誤時返回錯誤，Submit 不送出請求並回報為可重試錯誤：
+// 資料保留在離線佇列中，修正設定後即可重送，也不會送出未認證的資料
+func newAuth(auth config.AuthConfig) (*authenticator, []tls.Certificate, error) {
+	switch auth.Mode {
+	case "", config.AuthNone:
+		return nil, nil, nil
+	case config.AuthBearer:
+		if auth.Token == "" {
+			return nil, nil, fmt.Errorf("auth mode bearer requires a token")
+		}
+		return &authenticator{header: "Authorization", value: "Bearer " + auth.Token}, nil, nil
+	case config.AuthGAISF:
+		key, err := gaisfAPIKey(auth.SettingsFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &authenticator{header: gaisfHeader, value: key}, nil, nil
+	case config.AuthMTLS:
+		if auth.CertFile == "" || auth.KeyFile == "" {
+			return nil, nil, fmt.Errorf("auth mode mtls requires a client certificate and key")
+		}
+		cert, err := tls.LoadX509KeyPair(auth.CertFile, auth.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		return nil, []tls.Certificate{cert}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown auth mode %q", auth.Mode)
+	}
+}
+
+func (a *authenticator) apply(req *http.Request) {
+	if a != nil {
+		req.Header.Set(a.header, a.value)
+	}
+}
+
+// gaisfAPIKey 讀取 GAISF api-key：hook 繼承 Claude Code 的環境變數時直接使用，否則讀取 settings.json
+func gaisfAPIKey(settingsFile string) (string, error) {
+	if key := customHeaderValue(os.Getenv("ANTHROPIC_CUSTOM_HEADERS"), gaisfHeader); key != "" {
+		return key, nil
+	}
+	data, err := os.ReadFile(settingsFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read GAISF api-key from settings: %w", err)
+	}
+	var settings struct {
+		Env map[string]string `json:"env"`
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", settingsFile, err)
+	}
+	if key := customHeaderValue(settings.Env["ANTHROPIC_CUSTOM_HEADERS"], gaisfHeader); key != "" {
+		return key, nil
+	}
+	return "", fmt.Errorf("no %s found in ANTHROPIC_CUSTOM_HEADERS of %s", gaisfHeader, settingsFile)
+}
+
+// customHeaderValue 從 ANTHROPIC_CUSTOM_HEADERS（每行一個 "Name: value"）取出指定 header 的值
+func customHeaderValue(headers, name string) string {
+	scanner := bufio.NewScanner(strings.NewReader(headers))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.EqualFold(strings.TrimSpace(key), name) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package telemetry
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claude_analysis/core/config"
+)
+
+func TestAuth_HeaderModes(t *testing.T) {
+	settings := filepath.Join(t.TempDir(), "settings.json")
+	content := `{"env": {"ANTHROPIC_CUSTOM_HEADERS": "x-trace: 1\nAPI-Key: gaisf-token"}}`
+	if err := os.WriteFile(settings, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ANTHROPIC_CUSTOM_HEADERS", "")
+
+	cases := []struct {
+		auth   config.AuthConfig
+		header string
+		want   string
+	}{
+		{config.AuthConfig{Mode: config.AuthBearer, Token: "secret"}, "Authorization", "Bearer secret"},
+		{config.AuthConfig{Mode: config.AuthGAISF, SettingsFile: settings}, "api-key", "gaisf-token"},
+		{config.AuthConfig{Mode: config.AuthNone}, "Authorization", ""},
+	}
+	for _, c := range cases {
+		var got string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Get(c.header)
+		}))
+		client, _ := newTestClient(server.URL)
+		client.config.API.Auth = c.auth
+		client.auth, _, client.authErr = newAuth(c.auth)
+		_, err := client.Submit(map[string]string{})
+		server.Close()
+		if err != nil || got != c.want {
+			t.Errorf("mode %s: header %s = %q, err %v", c.auth.Mode, c.header, got, err)
+		}
+	}
+}
+
+func TestAuth_GAISFFromEnvironment(t *testing.T) {
+	t.Setenv("ANTHROPIC_CUSTOM_HEADERS", "api-key: from-env")
+	key, err := gaisfAPIKey(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || key != "from-env" {
+		t.Errorf("expected key from environment, got %q / %v", key, err)
+	}
+}
+
+func TestAuth_InvalidConfigFailsSubmit(t *testing.T) {
+	t.Setenv("ANTHROPIC_CUSTOM_HEADERS", "")
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	for _, auth := range []config.AuthConfig{
+		{Mode: config.AuthBearer},
+		{Mode: config.AuthGAISF, SettingsFile: filepath.Join(t.TempDir(), "missing.json")},
+		{Mode: config.AuthMTLS, CertFile: "missing.pem", KeyFile: "missing.key"},
+		{Mode: "kerberos"},
+	} {
+		cfg := config.Default()
+		cfg.API.Endpoint = server.URL
+		cfg.API.Auth = auth
+		_, err := New(cfg).Submit(map[string]string{})
+		var submitErr *SubmitError
+		if !errors.As(err, &submitErr) || !submitErr.Retryable || submitErr.StatusCode != 0 {
+			t.Errorf("mode %s: expected a retryable auth error, got %v", auth.Mode, err)
+		}
+		if !IsRetryable(err) {
+			t.Errorf("mode %s: invalid auth config must keep the payload in the spool", auth.Mode)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("expected no request with an invalid auth config, got %d", requests)
+	}
+}
+
+func TestAuth_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, cert := writeTestCertificate(t, dir)
+
+	var subject string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.API.Endpoint = server.URL
+	cfg.API.SkipSSLVerify = true
+	cfg.API.Auth = config.AuthConfig{Mode: config.AuthMTLS, CertFile: certFile, KeyFile: keyFile}
+	if _, err := New(cfg).Submit(map[string]string{}); err != nil {
+		t.Fatalf("Submit with client certificate failed: %v", err)
+	}
+	if subject != "claude-analysis-test" {
+		t.Errorf("unexpected client certificate subject %q", subject)
+	}
+
+	// 沒有客戶端憑證時伺服器拒絕連線
+	cfg.API.Auth = config.AuthConfig{Mode: config.AuthNone}
+	if _, err := New(cfg).Submit(map[string]string{}); err == nil {
+		t.Errorf("expected handshake failure without client certificate")
+	}
+}
+
+// writeTestCertificate 產生自簽的客戶端憑證並寫成 PEM 檔
+func writeTestCertificate(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "claude-analysis-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
@@ -2,73 +2,161 @@ package telemetry
 
 import (
 	"bytes"
-	"crypto/tls"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"claude_analysis/core/config"
+	"claude_analysis/core/httpclient"
 )
 
 // Client handles telemetry data submission to the API
 type Client struct {
 	httpClient *http.Client
 	config     *config.Config
+	auth       *authenticator
+	authErr    error               // 認證設定錯誤，Submit 時回報
+	sleep      func(time.Duration) // 測試時替換，避免真的等待
+}
+
+// SubmitError 描述一次失敗的提交，Retryable 表示稍後重送可能成功
+type SubmitError struct {
+	StatusCode int           // 0 表示沒有收到回應
+	Retryable  bool          // 網路錯誤、429 與 5xx 可重送，其餘為永久性錯誤
+	RetryAfter time.Duration // 伺服器要求的等待時間（Retry-After）
+	Err        error
+}
+
+func (e *SubmitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SubmitError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable 判斷錯誤是否值得稍後重送（例如寫入離線佇列）
+func IsRetryable(err error) bool {
+	var submitErr *SubmitError
+	return errors.As(err, &submitErr) && submitErr.Retryable
 }
 
 // New creates a new telemetry client
 func New(cfg *config.Config) *Client {
-	// 創建自定義的 HTTP transport 來處理 SSL 設定
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: cfg.API.SkipSSLVerify || cfg.API.InsecureSkipTLS,
-		},
-	}
+	auth, certificates, authErr := newAuth(cfg.API.Auth)
 
+	// 以共用的 HTTP client 處理代理、SSL 設定與 mTLS 客戶端憑證
 	return &Client{
-		httpClient: &http.Client{
-			Timeout:   cfg.API.Timeout,
-			Transport: transport,
-		},
-		config: cfg,
+		httpClient: httpclient.New(httpclient.Options{
+			Timeout:            time.Duration(cfg.API.Timeout),
+			InsecureSkipVerify: cfg.API.SkipSSLVerify || cfg.API.InsecureSkipTLS,
+			Certificates:       certificates,
+			Proxy:              cfg.Proxy,
+		}),
+		config:  cfg,
+		auth:    auth,
+		authErr: authErr,
+		sleep:   time.Sleep,
 	}
 }
 
 // Submit sends telemetry data to the API and returns the response
 func (c *Client) Submit(data interface{}) (map[string]interface{}, error) {
+	return c.SubmitWithKey(data, "")
+}
+
+// SubmitWithKey sends telemetry data with an Idempotency-Key header, retrying on 429 and 5xx
+func (c *Client) SubmitWithKey(data interface{}, idempotencyKey string) (map[string]interface{}, error) {
 	// Check if data is empty
 	// 支援傳入 array 或 map
-	var jsonData []byte
-	var err error
 	if data == nil {
 		return map[string]interface{}{"status": "success", "message": "no data to submit"}, nil
 	}
-	jsonData, err = json.Marshal(data)
+	if c.authErr != nil {
+		// 本地設定問題，修正後重送即可成功，因此保留在離線佇列中
+		return nil, &SubmitError{Retryable: true, Err: fmt.Errorf("invalid auth configuration: %w", c.authErr)}
+	}
+	jsonData, err := json.Marshal(data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+		return nil, &SubmitError{Err: fmt.Errorf("failed to marshal JSON: %w", err)}
+	}
+
+	body, encoding, err := c.encode(jsonData)
+	if err != nil {
+		return nil, &SubmitError{Err: err}
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, err := c.post(body, encoding, idempotencyKey)
+		var submitErr *SubmitError
+		if err == nil || !errors.As(err, &submitErr) || !submitErr.Retryable ||
+			submitErr.StatusCode == 0 || attempt >= c.config.API.MaxRetries {
+			return response, err
+		}
+
+		// 伺服器要求等待太久時不阻塞 hook，交由呼叫者決定是否稍後重送
+		wait := time.Duration(c.config.API.RetryBaseDelay) << attempt
+		if submitErr.RetryAfter > 0 {
+			if submitErr.RetryAfter > time.Duration(c.config.API.MaxRetryAfter) {
+				return nil, err
+			}
+			wait = submitErr.RetryAfter
+		}
+		c.sleep(wait)
+	}
+}
+
+// encode 依設定壓縮請求內容，返回內容與 Content-Encoding
+func (c *Client) encode(jsonData []byte) ([]byte, string, error) {
+	if c.config.API.Compression != "gzip" {
+		return jsonData, "", nil
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(jsonData); err != nil {
+		return nil, "", fmt.Errorf("failed to compress request: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to compress request: %w", err)
 	}
+	return buf.Bytes(), "gzip", nil
+}
 
+// post 發送一次請求
+func (c *Client) post(body []byte, encoding, idempotencyKey string) (map[string]interface{}, error) {
 	// Create request
-	req, err := http.NewRequest("POST", c.config.API.Endpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", c.config.API.Endpoint, bytes.NewReader(body))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, &SubmitError{Err: fmt.Errorf("failed to create request: %w", err)}
 	}
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+	}
+	c.auth.apply(req)
 
 	// Send request
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		// 連線失敗或逾時，通常是網路暫時不可用
+		return nil, &SubmitError{Retryable: true, Err: fmt.Errorf("failed to send request: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	// Read response body (但不强制要求为JSON)
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, &SubmitError{StatusCode: resp.StatusCode, Retryable: true, Err: fmt.Errorf("failed to read response: %w", err)}
 	}
 
 	// 首先检查HTTP状态码来判断成功与否
@@ -89,6 +177,25 @@ func (c *Client) Submit(data interface{}) (map[string]interface{}, error) {
 		}
 	} else {
 		// HTTP错误状态码
-		return nil, fmt.Errorf("API returned error status %d: %s", resp.StatusCode, string(responseBody))
+		return nil, &SubmitError{
+			StatusCode: resp.StatusCode,
+			Retryable:  resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Err:        fmt.Errorf("API returned error status %d: %s", resp.StatusCode, string(responseBody)),
+		}
+	}
+}
+
+// parseRetryAfter 解析 Retry-After（秒數或 HTTP 日期），無法解析時返回 0
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
 	}
+	return 0
 }
@@ -1,7 +1,16 @@
 package telemetry
 
 import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"claude_analysis/core/config"
 )
@@ -30,3 +39,196 @@ func TestTelemetryClientWithSSLConfig(t *testing.T) {
 		t.Error("Expected client to be created successfully with SSL verification enabled")
 	}
 }
+
+// newTestClient 建立指向測試伺服器的 client，並記錄重試等待時間而不真的等待
+func newTestClient(url string) (*Client, *[]time.Duration) {
+	cfg := config.Default()
+	cfg.API.Endpoint = url
+	client := New(cfg)
+	var waits []time.Duration
+	client.sleep = func(d time.Duration) { waits = append(waits, d) }
+	return client, &waits
+}
+
+func TestSubmitWithKey_RetriesAndHonorsRetryAfter(t *testing.T) {
+	var calls int
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{"status":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	client, waits := newTestClient(server.URL)
+	response, err := client.SubmitWithKey(map[string]string{"a": "b"}, "key-1")
+	if err != nil {
+		t.Fatalf("SubmitWithKey error: %v", err)
+	}
+	if response["status"] != "ok" || calls != 3 {
+		t.Errorf("unexpected response %v after %d calls", response, calls)
+	}
+	for _, key := range keys {
+		if key != "key-1" {
+			t.Errorf("idempotency key header missing on retry: %v", keys)
+		}
+	}
+	if len(*waits) != 2 || (*waits)[0] != time.Second || (*waits)[1] != 3*time.Second {
+		t.Errorf("unexpected waits: %v", *waits)
+	}
+}
+
+func TestSubmitWithKey_ErrorClassification(t *testing.T) {
+	cases := []struct {
+		status     int
+		retryAfter string
+		retryable  bool
+		calls      int
+	}{
+		{http.StatusBadRequest, "", false, 1},
+		{http.StatusServiceUnavailable, "", true, 3},
+		{http.StatusTooManyRequests, "3600", true, 1}, // 等待過久，不阻塞，交由呼叫者重送
+	}
+	for _, c := range cases {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if c.retryAfter != "" {
+				w.Header().Set("Retry-After", c.retryAfter)
+			}
+			w.WriteHeader(c.status)
+		}))
+		client, _ := newTestClient(server.URL)
+		_, err := client.Submit(map[string]string{})
+		server.Close()
+
+		var submitErr *SubmitError
+		if !errors.As(err, &submitErr) || submitErr.StatusCode != c.status {
+			t.Fatalf("status %d: unexpected error %v", c.status, err)
+		}
+		if IsRetryable(err) != c.retryable || calls != c.calls {
+			t.Errorf("status %d: retryable=%v calls=%d", c.status, IsRetryable(err), calls)
+		}
+	}
+
+	// 連線失敗屬於可重送錯誤
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	client, _ := newTestClient(server.URL)
+	if _, err := client.Submit(map[string]string{}); !IsRetryable(err) {
+		t.Errorf("network error should be retryable: %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if d := parseRetryAfter("120", now); d != 2*time.Minute {
+		t.Errorf("seconds: got %v", d)
+	}
+	if d := parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now); d != 30*time.Second {
+		t.Errorf("http date: got %v", d)
+	}
+	if d := parseRetryAfter("soon", now); d != 0 {
+		t.Errorf("invalid: got %v", d)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	records := []ClaudeCodeAnalysisRecord{{TaskID: "s1", TotalWriteLines: 3}}
+	k1, _ := IdempotencyKey("m1", records)
+	k2, _ := IdempotencyKey("m1", []ClaudeCodeAnalysisRecord{{TaskID: "s1", TotalWriteLines: 3}})
+	if k1 == "" || k1 != k2 {
+		t.Errorf("key must be deterministic: %s vs %s", k1, k2)
+	}
+	for _, other := range []struct {
+		machine string
+		records []ClaudeCodeAnalysisRecord
+	}{
+		{"m2", records},
+		{"m1", []ClaudeCodeAnalysisRecord{{TaskID: "s2", TotalWriteLines: 3}}},
+		{"m1", []ClaudeCodeAnalysisRecord{{TaskID: "s1", TotalWriteLines: 4}}},
+	} {
+		if k, _ := IdempotencyKey(other.machine, other.records); k == k1 {
+			t.Errorf("key should change with machine, session and content: %+v", other)
+		}
+	}
+}
+
+func TestIdempotencyKey_StableAcrossSurvivalChecks(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	use, _ := json.Marshal(map[string]interface{}{
+		"type": "assistant", "uuid": "a1", "sessionId": "sessKey", "cwd": dir, "timestamp": "2025-01-01T00:00:00Z",
+		"message": map[string]interface{}{"content": []interface{}{map[string]interface{}{
+			"type": "tool_use", "id": "toolu_1", "name": "Write",
+			"input": map[string]interface{}{"file_path": file, "content": "package main\n"},
+		}}},
+	})
+	transcript := filepath.Join(dir, "sessKey.jsonl")
+	if err := os.WriteFile(transcript, append(use, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for run := 0; run < 2; run++ {
+		analysis, err := AnalyzeTranscript(transcript)
+		if err != nil {
+			t.Fatalf("AnalyzeTranscript: %v", err)
+		}
+		for i := range analysis.Records {
+			CheckCodeSurvival(&analysis.Records[i])
+		}
+		survival := analysis.Records[0].CodeSurvival
+		if survival == nil || survival.CheckedAt == 0 {
+			t.Fatalf("expected survival to be checked, got %+v", survival)
+		}
+		// the second run happens a minute later
+		survival.CheckedAt += int64(run) * 60
+		key, err := IdempotencyKey("m1", analysis.Records)
+		if err != nil {
+			t.Fatalf("IdempotencyKey: %v", err)
+		}
+		keys = append(keys, key)
+		if survival.CheckedAt == 0 {
+			t.Errorf("IdempotencyKey must not modify the records")
+		}
+	}
+	if keys[0] != keys[1] {
+		t.Errorf("re-analyzing the same transcript must give the same key: %s vs %s", keys[0], keys[1])
+	}
+}
+
+func TestSubmit_GzipCompression(t *testing.T) {
+	var encoding, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("body is not gzip: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(reader)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	client, _ := newTestClient(server.URL)
+	client.config.API.Compression = "gzip"
+	if _, err := client.Submit(map[string]string{"a": "b"}); err != nil {
+		t.Fatalf("Submit error: %v", err)
+	}
+	if encoding != "gzip" || body != `{"a":"b"}` {
+		t.Errorf("unexpected encoding %q / body %q", encoding, body)
+	}
+}
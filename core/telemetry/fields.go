@@ -0,0 +1,153 @@
+package telemetry
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// fieldSources 说明负载中每个字段的来源，供 dry-run 输出给隐私审查者
+//
+// 键为 JSON 路径，数组元素以 [] 表示；未列出的字段沿用最近的上层路径的说明，
+// 各类明细共有的字段以 records[].*Details[] 描述
+var fieldSources = map[string]string{
+	"user":            "OS user name of the current account (config user_name)",
+	"extensionName":   "Constant \"Claude-Code\" (config extension_name)",
+	"insightsVersion": "Version of the claude_analysis binary",
+	"machineId":       "Machine ID from the operating system (config machine_id)",
+	"schemaVersion":   "Payload format version, see `claude_analysis schema`",
+	"idempotencyKey":  "SHA-256 of the machine ID and record contents, lets the collector drop duplicates",
+	"part":            "Part number when a large payload is split into several requests",
+	"totalParts":      "Number of parts when a large payload is split into several requests",
+	"records":         "One entry per analyzed session",
+
+	"records[].taskId":                        "Session ID from the transcript (sessionId)",
+	"records[].timestamp":                     "Time of the last transcript entry (Unix seconds)",
+	"records[].folderPath":                    "Working directory (cwd) of the session, with forward slashes",
+	"records[].gitRemoteUrl":                  "URL of the origin remote in .git/config of the working directory, exactly as configured",
+	"records[].title":                         "Session summary title written by Claude Code in the transcript",
+	"records[].totalUniqueFiles":              "Number of distinct files read, written or edited",
+	"records[].totalWriteLines":               "Lines written by the Write tool",
+	"records[].totalReadCharacters":           "Characters returned by the Read tool",
+	"records[].totalWriteCharacters":          "Characters written by the Write tool",
+	"records[].totalDiffCharacters":           "Characters of replacement text from the Edit tools",
+	"records[].toolCallCounts":                "Number of calls per built-in tool, counted from tool_use blocks",
+	"records[].toolLatencies":                 "Call count and p50/p90/max latency per tool, from tool_use/tool_result timestamps",
+	"records[].tokenUsage":                    "Token counts per model from the usage of assistant messages",
+	"records[].webDomainCounts":               "Number of WebFetch/WebSearch calls per domain",
+	"records[].sessionStartedAt":              "Session start from the local hook event log (Unix milliseconds)",
+	"records[].hookEventCounts":               "Number of hook events per event name from the local hook event log",
+	"records[].policyDecisions":               "Guardrail decisions made by the PreToolUse policy engine",
+	"records[].policyDecisions[].target":      "Command or file path that triggered the rule",
+	"records[].policyDecisions[].reason":      "Reason given by the rule, which may quote the command or path",
+	"records[].detailSampleRate":              "Fraction of tool calls kept in the detail lists when sampling is enabled (submission detail_sample_rate)",
+	"records[].codeSurvival":                  "How much written code still exists in the working tree at Stop (only when enabled)",
+	"records[].codeSurvival.files[].filePath": "Absolute path of the checked file, with forward slashes",
+
+	"records[].writeToFileDetails":                 "One entry per Write tool call",
+	"records[].writeToFileDetails[].content":       "Full content written to the file",
+	"records[].readFileDetails":                    "One entry per Read tool call (no file content)",
+	"records[].applyDiffDetails":                   "One entry per Edit tool result",
+	"records[].applyDiffDetails[].old_string":      "Text replaced by the edit",
+	"records[].applyDiffDetails[].new_string":      "Replacement text of the edit",
+	"records[].runCommandDetails":                  "One entry per Bash tool call",
+	"records[].runCommandDetails[].command":        "Full shell command",
+	"records[].runCommandDetails[].description":    "Description Claude gave for the command",
+	"records[].runCommandDetails[].filePath":       "Working directory the command ran in",
+	"records[].runCommandDetails[].characterCount": "Length of the command",
+	"records[].webAccessDetails":                   "One entry per WebFetch/WebSearch tool call",
+	"records[].webAccessDetails[].url":             "Fetched URL",
+	"records[].webAccessDetails[].filePath":        "Working directory of the session",
+	"records[].webAccessDetails[].characterCount":  "Length of the URL or search query",
+	"records[].webAccessDetails[].domain":          "Host of the fetched URL (empty for searches)",
+	"records[].webAccessDetails[].query":           "Search query",
+	"records[].webAccessDetails[].toolName":        "WebFetch or WebSearch",
+	"records[].webAccessDetails[].promptLength":    "Length of the WebFetch prompt (the prompt itself is not sent)",
+	"records[].webAccessDetails[].resultSize":      "Size of the tool result (the result itself is not sent)",
+
+	"records[].*Details[].filePath":       "Absolute path of the file, with forward slashes",
+	"records[].*Details[].lineCount":      "Number of lines in the content",
+	"records[].*Details[].characterCount": "Number of characters in the content",
+	"records[].*Details[].timestamp":      "Time of the tool call (Unix seconds)",
+	"records[].*Details[].toolUseId":      "Tool call ID from the transcript",
+	"records[].*Details[].isError":        "Whether the tool reported an error",
+	"records[].*Details[].latencyMs":      "Time between the tool call and its result",
+}
+
+// detailList 匹配各类明细列表，用于查找共有字段的说明
+var detailList = regexp.MustCompile(`^records\[\]\.\w+Details\[\]`)
+
+// FieldSources 返回所有字段来源说明的副本
+func FieldSources() map[string]string {
+	sources := make(map[string]string, len(fieldSources))
+	for key, value := range fieldSources {
+		sources[key] = value
+	}
+	return sources
+}
+
+// fieldSource 返回 JSON 路径的来源说明，没有直接说明时依次查找上层路径
+func fieldSource(jsonPath string) string {
+	for p := jsonPath; p != ""; p = parentPath(p) {
+		if source, ok := fieldSources[p]; ok {
+			return source
+		}
+		if generic := detailList.ReplaceAllString(p, "records[].*Details[]"); generic != p {
+			if source, ok := fieldSources[generic]; ok {
+				return source
+			}
+		}
+	}
+	return ""
+}
+
+func parentPath(p string) string {
+	if trimmed, ok := strings.CutSuffix(p, "[]"); ok {
+		return trimmed
+	}
+	if i := strings.LastIndex(p, "."); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// NormalizePaths 将记录中的文件与目录路径统一为正斜杠并去除多余的 . 与 ..，
+// 使不同平台上传的路径可以直接比较
+func NormalizePaths(analysis *ClaudeCodeAnalysis) {
+	for i := range analysis.Records {
+		record := &analysis.Records[i]
+		record.FolderPath = normalizePath(record.FolderPath)
+		for j := range record.WriteToFileDetails {
+			normalizeBase(&record.WriteToFileDetails[j].ClaudeCodeAnalysisDetailBase)
+		}
+		for j := range record.ReadFileDetails {
+			normalizeBase(&record.ReadFileDetails[j].ClaudeCodeAnalysisDetailBase)
+		}
+		for j := range record.ApplyDiffDetails {
+			normalizeBase(&record.ApplyDiffDetails[j].ClaudeCodeAnalysisDetailBase)
+		}
+		for j := range record.RunCommandDetails {
+			normalizeBase(&record.RunCommandDetails[j].ClaudeCodeAnalysisDetailBase)
+		}
+		for j := range record.WebAccessDetails {
+			normalizeBase(&record.WebAccessDetails[j].ClaudeCodeAnalysisDetailBase)
+		}
+		if record.CodeSurvival != nil {
+			for j := range record.CodeSurvival.Files {
+				record.CodeSurvival.Files[j].FilePath = normalizePath(record.CodeSurvival.Files[j].FilePath)
+			}
+		}
+	}
+}
+
+func normalizeBase(base *ClaudeCodeAnalysisDetailBase) {
+	base.FilePath = normalizePath(base.FilePath)
+}
+
+func normalizePath(p string) string {
+	if p == "" {
+		return ""
+	}
+	return path.Clean(filepath.ToSlash(p))
+}
@@ -0,0 +1,74 @@
+package telemetry
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+// schemaPaths 列出 schema 中所有字段的 JSON 路径，格式与 fieldSources 的键相同
+func schemaPaths(schema *JSONSchema, prefix string, defs map[string]*JSONSchema, paths *[]string) {
+	if schema.Ref != "" {
+		schema = defs[strings.TrimPrefix(schema.Ref, "#/$defs/")]
+	}
+	if schema.Items != nil {
+		schemaPaths(schema.Items, prefix+"[]", defs, paths)
+	}
+	for name, property := range schema.Properties {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		*paths = append(*paths, path)
+		schemaPaths(property, path, defs, paths)
+	}
+}
+
+func TestFieldSources_CoverSchema(t *testing.T) {
+	root := Schema()
+	var paths []string
+	schemaPaths(root, "", root.Defs, &paths)
+	sort.Strings(paths)
+
+	known := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		known[path] = true
+		if fieldSource(path) == "" {
+			t.Errorf("no field source for %s", path)
+		}
+	}
+	for key := range fieldSources {
+		if !known[key] && !strings.Contains(key, "*") {
+			t.Errorf("field source %s does not match any schema field", key)
+		}
+	}
+
+	if got := fieldSource("records[].readFileDetails[].filePath"); got != fieldSources["records[].*Details[].filePath"] {
+		t.Errorf("generic detail source = %q", got)
+	}
+	if got := fieldSource("records[].webAccessDetails[].filePath"); got != fieldSources["records[].webAccessDetails[].filePath"] {
+		t.Errorf("specific source should win over generic one: %q", got)
+	}
+}
+
+func TestNormalizePaths(t *testing.T) {
+	analysis := &ClaudeCodeAnalysis{Records: []ClaudeCodeAnalysisRecord{{
+		FolderPath:         "/work/api/",
+		WriteToFileDetails: []ClaudeCodeAnalysisWriteDetail{{ClaudeCodeAnalysisDetailBase: ClaudeCodeAnalysisDetailBase{FilePath: "/work/api/./src/../main.go"}}},
+		ReadFileDetails:    []ClaudeCodeAnalysisReadDetail{{ClaudeCodeAnalysisDetailBase: ClaudeCodeAnalysisDetailBase{FilePath: ""}}},
+		CodeSurvival:       &ClaudeCodeAnalysisCodeSurvival{Files: []ClaudeCodeAnalysisFileSurvival{{FilePath: "/work//api/main.go"}}},
+	}}}
+	NormalizePaths(analysis)
+
+	record := analysis.Records[0]
+	want := "/work/api/main.go"
+	if record.WriteToFileDetails[0].FilePath != want || record.CodeSurvival.Files[0].FilePath != want {
+		t.Errorf("paths not cleaned: %q, %q", record.WriteToFileDetails[0].FilePath, record.CodeSurvival.Files[0].FilePath)
+	}
+	if record.ReadFileDetails[0].FilePath != "" {
+		t.Errorf("empty path should stay empty, got %q", record.ReadFileDetails[0].FilePath)
+	}
+	if record.FolderPath != "/work/api" {
+		t.Errorf("folder path = %q", record.FolderPath)
+	}
+}
@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// IdempotencyKeyHeader 是携带幂等键的 HTTP header
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyKey 由机器、会话与记录内容计算确定性的幂等键
+// 同一会话内容不变时重复上传得到相同的键，服务端可据此去重
+func IdempotencyKey(machineID string, records []ClaudeCodeAnalysisRecord) (string, error) {
+	content, err := json.Marshal(stableRecords(records))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal records: %w", err)
+	}
+	contentHash := sha256.Sum256(content)
+
+	sessions := make([]string, 0, len(records))
+	for _, record := range records {
+		sessions = append(sessions, record.TaskID)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s", machineID, strings.Join(sessions, ","), hex.EncodeToString(contentHash[:]))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// stableRecords 返回去掉易变字段的副本：每次分析都会变化的检查时间不参与哈希，
+// 否则同一会话重复分析会得到不同的键
+func stableRecords(records []ClaudeCodeAnalysisRecord) []ClaudeCodeAnalysisRecord {
+	stable := make([]ClaudeCodeAnalysisRecord, len(records))
+	copy(stable, records)
+	for i := range stable {
+		if stable[i].CodeSurvival != nil {
+			survival := *stable[i].CodeSurvival
+			survival.CheckedAt = 0
+			stable[i].CodeSurvival = &survival
+		}
+	}
+	return stable
+}
@@ -1,39 +1,28 @@
 package telemetry
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"strings"
-)
+	"path/filepath"
+	"time"
 
-// pythonDictToJSON 將 Python 字典格式的字串轉換為 JSON 格式
-func pythonDictToJSON(pythonDict string) string {
-	result := strings.ReplaceAll(pythonDict, "'", "\"")
-	result = strings.ReplaceAll(result, "False", "false")
-	result = strings.ReplaceAll(result, "True", "true")
-	result = strings.ReplaceAll(result, "None", "null")
-	return result
-}
+	"claude_analysis/core/hook"
+)
 
-// ExtractTranscriptPath 從 Python 字典格式的字串中提取 transcript_path
+// ExtractTranscriptPath 從 hook 輸入（JSON 或 Python 字典格式）中提取 transcript_path
 func ExtractTranscriptPath(input string) (string, error) {
-	jsonStr := pythonDictToJSON(input)
-	jsonBytes := []byte(jsonStr)
-	var data map[string]interface{}
-	if err := json.Unmarshal(jsonBytes, &data); err != nil {
-		return "", fmt.Errorf("failed to parse JSON: %w", err)
-	}
-	transcriptPath, exists := data["transcript_path"]
-	if !exists {
-		return "", fmt.Errorf("找不到 transcript_path")
+	hookInput, err := hook.ParseInput([]byte(input))
+	if err != nil {
+		return "", err
 	}
-	pathStr, ok := transcriptPath.(string)
-	if !ok {
-		return "", fmt.Errorf("transcript_path 不是字串類型")
+	if hookInput.TranscriptPath == "" {
+		return "", fmt.Errorf("找不到 transcript_path")
 	}
-	return pathStr, nil
+	return hookInput.TranscriptPath, nil
 }
 
 // ReadJSONL 讀取 JSONL 文件並返回所有 JSON 對象
@@ -61,3 +50,91 @@ func ReadJSONL(filename string) ([]map[string]interface{}, error) {
 
 	return results, nil
 }
+
+// summaryLine 是 summary 记录中用于匹配标题的字段
+type summaryLine struct {
+	Type     string `json:"type"`
+	Summary  string `json:"summary"`
+	LeafUUID string `json:"leafUuid"`
+}
+
+// maxSummaryLine 是 summary 记录的最大长度，更长的行（工具结果、图片等）不会是 summary，不读入内存
+const maxSummaryLine = 64 * 1024
+
+// ReadSessionSummaries 返回同一项目目录下其他 transcript 中 leafUuid 属于 records 的 summary 记录
+// Claude Code 会把会话标题写进之后的 transcript；修改时间早于会话开始的文件不可能包含本会话的标题，直接跳过
+func ReadSessionSummaries(path string, records []map[string]interface{}) []map[string]interface{} {
+	leafs := make(map[string]bool)
+	var since time.Time
+	for _, record := range records {
+		if uuid, ok := record["uuid"].(string); ok && uuid != "" {
+			leafs[uuid] = true
+		}
+		if ts, ok := record["timestamp"].(string); ok {
+			if t := parseISOTime(ts); !t.IsZero() && (since.IsZero() || t.Before(since)) {
+				since = t
+			}
+		}
+	}
+	if len(leafs) == 0 {
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*.jsonl"))
+	if err != nil {
+		return nil
+	}
+	var results []map[string]interface{}
+	for _, filename := range files {
+		if filepath.Clean(filename) == filepath.Clean(path) {
+			continue
+		}
+		info, err := os.Stat(filename)
+		if err != nil || info.ModTime().Before(since) {
+			continue
+		}
+		for _, summary := range readSummaryFile(filename) {
+			if leafs[summary.LeafUUID] {
+				results = append(results, map[string]interface{}{
+					"type":     summary.Type,
+					"summary":  summary.Summary,
+					"leafUuid": summary.LeafUUID,
+				})
+			}
+		}
+	}
+	return results
+}
+
+// readSummaryFile 返回文件中的 summary 记录
+// 只解析含 leafUuid 的短行，其余行（包括数 MB 的工具结果）只扫描换行符
+func readSummaryFile(filename string) []summaryLine {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var summaries []summaryLine
+	reader := bufio.NewReaderSize(file, maxSummaryLine)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			for err == bufio.ErrBufferFull {
+				_, err = reader.ReadSlice('\n')
+			}
+			if err != nil {
+				break
+			}
+			continue
+		}
+		var summary summaryLine
+		if bytes.Contains(line, []byte(`"leafUuid"`)) && json.Unmarshal(line, &summary) == nil &&
+			summary.Type == "summary" && summary.LeafUUID != "" {
+			summaries = append(summaries, summary)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return summaries
+}
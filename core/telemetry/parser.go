@@ -2,9 +2,10 @@ package telemetry
 
 import (
 	"bufio"
-	"encoding/json"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -16,6 +17,11 @@ type ClaudeCodeAnalysisDetailBase struct {
 	LineCount      int    `json:"lineCount"`
 	CharacterCount int    `json:"characterCount"`
 	Timestamp      int64  `json:"timestamp"`
+	ToolUseID      string `json:"toolUseId,omitempty"`
+	IsError        bool   `json:"isError,omitempty"`
+	LatencyMs      int64  `json:"latencyMs"`
+	// sequence 是工具调用在 transcript 中的顺序（从 1 开始），同一秒内的调用也能区分先后；不输出到 JSON
+	sequence int
 }
 
 // ClaudeCodeAnalysisWriteDetail - writeToFileDetails: 存储完整内容
@@ -43,6 +49,17 @@ type ClaudeCodeAnalysisRunCommandDetail struct {
 	Description string `json:"description"`
 }
 
+// ClaudeCodeAnalysisWebAccessDetail - webAccessDetails: 存储 WebFetch/WebSearch 的访问信息
+type ClaudeCodeAnalysisWebAccessDetail struct {
+	ClaudeCodeAnalysisDetailBase
+	ToolName     string `json:"toolName"`
+	URL          string `json:"url"`
+	Domain       string `json:"domain"`
+	Query        string `json:"query"`
+	PromptLength int    `json:"promptLength"`
+	ResultSize   int    `json:"resultSize"`
+}
+
 // ClaudeCodeAnalysisToolCalls - 工具调用次数计数器
 type ClaudeCodeAnalysisToolCalls struct {
 	Read      int `json:"Read"`
@@ -50,24 +67,64 @@ type ClaudeCodeAnalysisToolCalls struct {
 	Edit      int `json:"Edit"`
 	TodoWrite int `json:"TodoWrite"`
 	Bash      int `json:"Bash"`
+	WebFetch  int `json:"WebFetch"`
+	WebSearch int `json:"WebSearch"`
+}
+
+// ClaudeCodeAnalysisToolLatency - 单个工具的执行耗时分布（毫秒）
+type ClaudeCodeAnalysisToolLatency struct {
+	Count int   `json:"count"`
+	P50Ms int64 `json:"p50Ms"`
+	P90Ms int64 `json:"p90Ms"`
+	MaxMs int64 `json:"maxMs"`
+}
+
+// ClaudeCodeAnalysisTokenUsage - 单个模型的 token 用量
+type ClaudeCodeAnalysisTokenUsage struct {
+	Messages                 int `json:"messages"`
+	InputTokens              int `json:"inputTokens"`
+	OutputTokens             int `json:"outputTokens"`
+	CacheCreationInputTokens int `json:"cacheCreationInputTokens"`
+	CacheReadInputTokens     int `json:"cacheReadInputTokens"`
+}
+
+// ClaudeCodeAnalysisPolicyDecision - PreToolUse 策略引擎做出的一次决策
+type ClaudeCodeAnalysisPolicyDecision struct {
+	ToolName  string `json:"toolName"`
+	ToolUseID string `json:"toolUseId,omitempty"`
+	RuleID    string `json:"ruleId"`
+	Decision  string `json:"decision"` // allow / deny / ask
+	Reason    string `json:"reason,omitempty"`
+	Target    string `json:"target,omitempty"` // 触发规则的命令或文件路径
+	Timestamp int64  `json:"timestamp"`
 }
 
 // ClaudeCodeAnalysisRecord - 单个分析会话的汇总统计
 type ClaudeCodeAnalysisRecord struct {
-	TotalUniqueFiles     int                                  `json:"totalUniqueFiles"`
-	TotalWriteLines      int                                  `json:"totalWriteLines"`
-	TotalReadCharacters  int                                  `json:"totalReadCharacters"`
-	TotalWriteCharacters int                                  `json:"totalWriteCharacters"`
-	TotalDiffCharacters  int                                  `json:"totalDiffCharacters"`
-	WriteToFileDetails   []ClaudeCodeAnalysisWriteDetail      `json:"writeToFileDetails"`
-	ReadFileDetails      []ClaudeCodeAnalysisReadDetail       `json:"readFileDetails"`
-	ApplyDiffDetails     []ClaudeCodeAnalysisApplyDiffDetail  `json:"applyDiffDetails"`
-	RunCommandDetails    []ClaudeCodeAnalysisRunCommandDetail `json:"runCommandDetails"`
-	ToolCallCounts       ClaudeCodeAnalysisToolCalls          `json:"toolCallCounts"`
-	TaskID               string                               `json:"taskId"`
-	Timestamp            int64                                `json:"timestamp"`
-	FolderPath           string                               `json:"folderPath"`
-	GitRemoteURL         string                               `json:"gitRemoteUrl"`
+	TotalUniqueFiles     int                                      `json:"totalUniqueFiles"`
+	TotalWriteLines      int                                      `json:"totalWriteLines"`
+	TotalReadCharacters  int                                      `json:"totalReadCharacters"`
+	TotalWriteCharacters int                                      `json:"totalWriteCharacters"`
+	TotalDiffCharacters  int                                      `json:"totalDiffCharacters"`
+	WriteToFileDetails   []ClaudeCodeAnalysisWriteDetail          `json:"writeToFileDetails"`
+	ReadFileDetails      []ClaudeCodeAnalysisReadDetail           `json:"readFileDetails"`
+	ApplyDiffDetails     []ClaudeCodeAnalysisApplyDiffDetail      `json:"applyDiffDetails"`
+	RunCommandDetails    []ClaudeCodeAnalysisRunCommandDetail     `json:"runCommandDetails"`
+	WebAccessDetails     []ClaudeCodeAnalysisWebAccessDetail      `json:"webAccessDetails"`
+	WebDomainCounts      map[string]int                           `json:"webDomainCounts"`
+	ToolCallCounts       ClaudeCodeAnalysisToolCalls              `json:"toolCallCounts"`
+	ToolLatencies        map[string]ClaudeCodeAnalysisToolLatency `json:"toolLatencies"`
+	TokenUsage           map[string]ClaudeCodeAnalysisTokenUsage  `json:"tokenUsage,omitempty"` // 按模型统计
+	TaskID               string                                   `json:"taskId"`
+	Timestamp            int64                                    `json:"timestamp"`
+	FolderPath           string                                   `json:"folderPath"`
+	GitRemoteURL         string                                   `json:"gitRemoteUrl"`
+	Title                string                                   `json:"title"`
+	CodeSurvival         *ClaudeCodeAnalysisCodeSurvival          `json:"codeSurvival,omitempty"`
+	SessionStartedAt     int64                                    `json:"sessionStartedAt,omitempty"` // 来自 hook 事件日志（Unix 毫秒）
+	HookEventCounts      map[string]int                           `json:"hookEventCounts,omitempty"`
+	PolicyDecisions      []ClaudeCodeAnalysisPolicyDecision       `json:"policyDecisions,omitempty"`
+	DetailSampleRate     float64                                  `json:"detailSampleRate,omitempty"` // 明细被采样时保留的比例
 }
 
 // ClaudeCodeAnalysis - 顶级分析负载
@@ -75,30 +132,38 @@ type ClaudeCodeAnalysis struct {
 	User            string                     `json:"user"`
 	ExtensionName   string                     `json:"extensionName"`
 	InsightsVersion string                     `json:"insightsVersion"`
+	SchemaVersion   int                        `json:"schemaVersion"` // 负载格式版本，见 SchemaVersion
 	MachineID       string                     `json:"machineId"`
+	IdempotencyKey  string                     `json:"idempotencyKey,omitempty"`
+	Part            int                        `json:"part,omitempty"`       // 拆分上传时的序号（从 1 开始）
+	TotalParts      int                        `json:"totalParts,omitempty"` // 拆分上传时的总部分数
 	Records         []ClaudeCodeAnalysisRecord `json:"records"`
 }
 
 // ClaudeCodeLog - 对应 Python 中的 ClaudeCodeLog 模型
 type ClaudeCodeLog struct {
-	ParentUUID    *string     `json:"parentUuid"`
-	IsSidechain   bool        `json:"isSidechain"`
-	UserType      string      `json:"userType"`
-	CWD           string      `json:"cwd"`
-	SessionID     string      `json:"sessionId"`
-	Version       string      `json:"version"`
-	GitBranch     string      `json:"gitBranch"`
-	Type          string      `json:"type"`
-	UUID          string      `json:"uuid"`
-	Timestamp     string      `json:"timestamp"`
-	Message       interface{} `json:"message"`
-	ToolUseResult interface{} `json:"toolUseResult,omitempty"`
+	ParentUUID    *string            `json:"parentUuid"`
+	IsSidechain   bool               `json:"isSidechain"`
+	IsMeta        bool               `json:"isMeta,omitempty"` // Claude Code 自动插入的说明消息，不是用户输入
+	UserType      string             `json:"userType"`
+	CWD           string             `json:"cwd"`
+	SessionID     string             `json:"sessionId"`
+	Version       string             `json:"version"`
+	GitBranch     string             `json:"gitBranch"`
+	Type          string             `json:"type"`
+	UUID          string             `json:"uuid"`
+	Timestamp     string             `json:"timestamp"`
+	Message       *TranscriptMessage `json:"message"`
+	ToolUseResult *ToolUseResult     `json:"toolUseResult,omitempty"`
+	// summary 记录（type=summary）只有以下两个字段，通过 leafUuid 关联到会话中的消息
+	Summary  string `json:"summary,omitempty"`
+	LeafUUID string `json:"leafUuid,omitempty"`
 }
 
-// parseISOTimestamp 解析 ISO 时间戳为 Unix 秒数
-func parseISOTimestamp(ts string) int64 {
+// parseISOTime 解析 ISO 时间戳，失败时返回零值
+func parseISOTime(ts string) time.Time {
 	if ts == "" {
-		return 0
+		return time.Time{}
 	}
 	// 尝试解析不同的时间格式
 	formats := []string{
@@ -110,14 +175,104 @@ func parseISOTimestamp(ts string) int64 {
 
 	for _, format := range formats {
 		if t, err := time.Parse(format, ts); err == nil {
-			return t.Unix()
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseISOTimestamp 解析 ISO 时间戳为 Unix 秒数
+func parseISOTimestamp(ts string) int64 {
+	return unixSeconds(parseISOTime(ts))
+}
+
+// unixSeconds 返回 Unix 秒数，零值时间返回 0
+func unixSeconds(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// urlDomain 从 URL 中提取小写的主机名（不含端口）
+func urlDomain(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// callLatencyMs 计算 tool_use 到 tool_result 的耗时，缺少任一时间戳时返回 0
+func callLatencyMs(call *ToolCall) int64 {
+	if call.Timestamp.IsZero() || call.ResultTimestamp.IsZero() {
+		return 0
+	}
+	latency := call.ResultTimestamp.Sub(call.Timestamp).Milliseconds()
+	if latency < 0 {
+		return 0
+	}
+	return latency
+}
+
+// summarizeLatencies 按最近秩法计算每个工具的 p50/p90/max
+func summarizeLatencies(samples map[string][]int64) map[string]ClaudeCodeAnalysisToolLatency {
+	result := make(map[string]ClaudeCodeAnalysisToolLatency, len(samples))
+	for name, values := range samples {
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		percentile := func(p int) int64 {
+			rank := (p*len(values) + 99) / 100
+			if rank < 1 {
+				rank = 1
+			}
+			return values[rank-1]
+		}
+		result[name] = ClaudeCodeAnalysisToolLatency{
+			Count: len(values),
+			P50Ms: percentile(50),
+			P90Ms: percentile(90),
+			MaxMs: values[len(values)-1],
 		}
 	}
-	return 0
+	return result
 }
 
-// countLines 计算字符串中的行数
-func countLines(s string) int {
+// TokenUsageByModel 按模型汇总助手消息的 token 用量
+// 同一条消息的每个内容块都会单独写一行并重复 usage，因此按 message.id 去重，保留最后一行
+func TokenUsageByModel(logs []ClaudeCodeLog) map[string]ClaudeCodeAnalysisTokenUsage {
+	latest := make(map[string]*TranscriptMessage)
+	var order []string
+	for i := range logs {
+		message := logs[i].Message
+		if logs[i].Type != "assistant" || message == nil || message.Usage == nil || message.Model == "" {
+			continue
+		}
+		id := message.ID
+		if id == "" {
+			id = logs[i].UUID
+		}
+		if _, ok := latest[id]; !ok {
+			order = append(order, id)
+		}
+		latest[id] = message
+	}
+
+	usage := make(map[string]ClaudeCodeAnalysisTokenUsage)
+	for _, id := range order {
+		message := latest[id]
+		total := usage[message.Model]
+		total.Messages++
+		total.InputTokens += message.Usage.InputTokens
+		total.OutputTokens += message.Usage.OutputTokens
+		total.CacheCreationInputTokens += message.Usage.CacheCreationInputTokens
+		total.CacheReadInputTokens += message.Usage.CacheReadInputTokens
+		usage[message.Model] = total
+	}
+	return usage
+}
+
+// CountLines 计算字符串中的行数
+func CountLines(s string) int {
 	if s == "" {
 		return 0
 	}
@@ -131,9 +286,12 @@ func AnalyzeConversations(records []map[string]interface{}) ClaudeCodeAnalysis {
 	readDetails := []ClaudeCodeAnalysisReadDetail{}
 	applyDiffDetails := []ClaudeCodeAnalysisApplyDiffDetail{}
 	runDetails := []ClaudeCodeAnalysisRunCommandDetail{}
+	webDetails := []ClaudeCodeAnalysisWebAccessDetail{}
 
 	toolCounts := ClaudeCodeAnalysisToolCalls{}
 	uniqueFiles := make(map[string]struct{})
+	webDomainCounts := make(map[string]int)
+	latencySamples := make(map[string][]int64)
 
 	totalWriteLines := 0
 	totalReadCharacters := 0
@@ -145,149 +303,165 @@ func AnalyzeConversations(records []map[string]interface{}) ClaudeCodeAnalysis {
 	taskID := ""
 	lastTimestamp := int64(0)
 
-	for _, record := range records {
-		// 尝试转换为 ClaudeCodeLog 结构
-		recordJSON, err := json.Marshal(record)
-		if err != nil {
-			continue
-		}
+	// summary 记录与本会话消息 uuid 的出现顺序，用于匹配会话标题
+	var summaries []ClaudeCodeLog
+	var logs []ClaudeCodeLog
+	uuidOrder := make(map[string]int)
 
-		var claudeCodeLog ClaudeCodeLog
-		if err := json.Unmarshal(recordJSON, &claudeCodeLog); err != nil {
-			// 跳过不符合模型的条目（例如 thinking blocks）
+	for _, claudeCodeLog := range DecodeLogs(records) {
+		// summary 记录没有 sessionId，单独收集后再通过 leafUuid 匹配
+		if claudeCodeLog.Type == "summary" {
+			summaries = append(summaries, claudeCodeLog)
 			continue
 		}
+		logs = append(logs, claudeCodeLog)
+		if claudeCodeLog.UUID != "" {
+			uuidOrder[claudeCodeLog.UUID] = len(uuidOrder)
+		}
 
 		// 提取基本信息
 		if folderPath == "" {
 			folderPath = claudeCodeLog.CWD
 		}
-		taskID = claudeCodeLog.SessionID
+		if claudeCodeLog.SessionID != "" {
+			taskID = claudeCodeLog.SessionID
+		}
 
 		tsInt := parseISOTimestamp(claudeCodeLog.Timestamp)
 		if tsInt > lastTimestamp {
 			lastTimestamp = tsInt
 		}
+	}
 
-		// 计算工具调用（助手 tool_use 仅限）
-		if claudeCodeLog.Type == "assistant" && claudeCodeLog.Message != nil {
-			if messageMap, ok := claudeCodeLog.Message.(map[string]interface{}); ok {
-				if contentArray, ok := messageMap["content"].([]interface{}); ok {
-					for _, item := range contentArray {
-						if itemMap, ok := item.(map[string]interface{}); ok {
-							if itemType, ok := itemMap["type"].(string); ok && itemType == "tool_use" {
-								if name, ok := itemMap["name"].(string); ok {
-									switch name {
-									case "Read":
-										toolCounts.Read++
-									case "Write":
-										toolCounts.Write++
-									case "Edit":
-										toolCounts.Edit++
-									case "TodoWrite":
-										toolCounts.TodoWrite++
-									case "Bash":
-										toolCounts.Bash++
-										// 记录 runCommandDetails（从输入中，没有文件；使用 cwd 作为 filePath）
-										if inputMap, ok := itemMap["input"].(map[string]interface{}); ok {
-											command, _ := inputMap["command"].(string)
-											description, _ := inputMap["description"].(string)
-											runDetails = append(runDetails, ClaudeCodeAnalysisRunCommandDetail{
-												ClaudeCodeAnalysisDetailBase: ClaudeCodeAnalysisDetailBase{
-													FilePath:       claudeCodeLog.CWD,
-													LineCount:      0,
-													CharacterCount: len(command),
-													Timestamp:      tsInt,
-												},
-												Command:     command,
-												Description: description,
-											})
-										}
-									}
-								}
-							}
-						}
-					}
-				}
+	for index, call := range LinkToolCalls(logs) {
+		useTs := unixSeconds(call.Timestamp)
+		resultTs := unixSeconds(call.ResultTimestamp)
+		latencyMs := callLatencyMs(call)
+		if call.Name() != "" && !call.ResultTimestamp.IsZero() && !call.Timestamp.IsZero() {
+			latencySamples[call.Name()] = append(latencySamples[call.Name()], latencyMs)
+		}
+		base := func(filePath string, lineCount, characterCount int, ts int64) ClaudeCodeAnalysisDetailBase {
+			return ClaudeCodeAnalysisDetailBase{
+				FilePath:       filePath,
+				LineCount:      lineCount,
+				CharacterCount: characterCount,
+				Timestamp:      ts,
+				ToolUseID:      call.ID(),
+				IsError:        call.IsError(),
+				LatencyMs:      latencyMs,
+				sequence:       index + 1,
 			}
 		}
 
-		// 从 toolUseResult 填充各种 *Details
-		if claudeCodeLog.ToolUseResult == nil {
-			continue
+		// 计算工具调用（助手 tool_use 仅限），并从输入中记录命令和 web 访问
+		switch call.Name() {
+		case "Read":
+			toolCounts.Read++
+		case "Write":
+			toolCounts.Write++
+		case "Edit":
+			toolCounts.Edit++
+		case "TodoWrite":
+			toolCounts.TodoWrite++
+		case "Bash":
+			toolCounts.Bash++
+			// 记录 runCommandDetails（从输入中，没有文件；使用 cwd 作为 filePath）
+			if call.Use.Input != nil {
+				command := call.Use.InputString("command")
+				runDetails = append(runDetails, ClaudeCodeAnalysisRunCommandDetail{
+					ClaudeCodeAnalysisDetailBase: base(call.CWD, 0, len(command), useTs),
+					Command:                      command,
+					Description:                  call.Use.InputString("description"),
+				})
+			}
+		case "WebFetch", "WebSearch":
+			if call.Name() == "WebFetch" {
+				toolCounts.WebFetch++
+			} else {
+				toolCounts.WebSearch++
+			}
+			rawURL := call.Use.InputString("url")
+			query := call.Use.InputString("query")
+			domain := urlDomain(rawURL)
+			if domain != "" {
+				webDomainCounts[domain]++
+			}
+			target := rawURL
+			if call.Name() == "WebSearch" {
+				target = query
+			}
+			resultSize := 0
+			if call.Result != nil {
+				resultSize = utf8.RuneCountInString(call.Result.Content.String())
+			}
+			webDetails = append(webDetails, ClaudeCodeAnalysisWebAccessDetail{
+				ClaudeCodeAnalysisDetailBase: base(call.CWD, 0, utf8.RuneCountInString(target), useTs),
+				ToolName:                     call.Name(),
+				URL:                          rawURL,
+				Domain:                       domain,
+				Query:                        query,
+				PromptLength:                 utf8.RuneCountInString(call.Use.InputString("prompt")),
+				ResultSize:                   resultSize,
+			})
 		}
 
-		turMap, ok := claudeCodeLog.ToolUseResult.(map[string]interface{})
-		if !ok {
+		// 从 toolUseResult 填充各种 *Details
+		output := call.Output
+		if output == nil {
 			continue
 		}
 
 		// Read result
-		if turType, exists := turMap["type"]; exists && turType == "text" {
-			if fileMap, ok := turMap["file"].(map[string]interface{}); ok {
-				filePath, _ := fileMap["filePath"].(string)
-				content, _ := fileMap["content"].(string)
-				numLinesFloat, _ := fileMap["numLines"].(float64)
-				numLines := int(numLinesFloat)
-
-				readDetails = append(readDetails, ClaudeCodeAnalysisReadDetail{
-					ClaudeCodeAnalysisDetailBase: ClaudeCodeAnalysisDetailBase{
-						FilePath:       filePath,
-						LineCount:      numLines,
-						CharacterCount: utf8.RuneCountInString(content),
-						Timestamp:      tsInt,
-					},
-				})
-				uniqueFiles[filePath] = struct{}{}
-				totalReadCharacters += utf8.RuneCountInString(content)
-			}
+		if output.Type == "text" && output.File != nil {
+			filePath := output.File.FilePath
+			characterCount := utf8.RuneCountInString(output.File.Content)
+			readDetails = append(readDetails, ClaudeCodeAnalysisReadDetail{
+				ClaudeCodeAnalysisDetailBase: base(filePath, output.File.NumLines, characterCount, resultTs),
+			})
+			uniqueFiles[filePath] = struct{}{}
+			totalReadCharacters += characterCount
 		}
 
 		// Write (create) result
-		if turType, exists := turMap["type"]; exists && turType == "create" {
-			filePath, _ := turMap["filePath"].(string)
-			content, _ := turMap["content"].(string)
-			lineCount := countLines(content)
-
+		if output.Type == "create" {
+			lineCount := CountLines(output.Content)
+			characterCount := utf8.RuneCountInString(output.Content)
 			writeDetails = append(writeDetails, ClaudeCodeAnalysisWriteDetail{
-				ClaudeCodeAnalysisDetailBase: ClaudeCodeAnalysisDetailBase{
-					FilePath:       filePath,
-					LineCount:      lineCount,
-					CharacterCount: utf8.RuneCountInString(content),
-					Timestamp:      tsInt,
-				},
-				Content: content,
+				ClaudeCodeAnalysisDetailBase: base(output.FilePath, lineCount, characterCount, resultTs),
+				Content:                      output.Content,
 			})
-			uniqueFiles[filePath] = struct{}{}
+			uniqueFiles[output.FilePath] = struct{}{}
 			totalWriteLines += lineCount
-			totalWriteCharacters += utf8.RuneCountInString(content)
+			totalWriteCharacters += characterCount
 		}
 
 		// Edit result (applyDiff)
-		if filePath, ok := turMap["filePath"].(string); ok {
-			if newString, ok := turMap["newString"].(string); ok {
-				oldString, _ := turMap["oldString"].(string)
-				lineCount := countLines(newString)
-
-				applyDiffDetails = append(applyDiffDetails, ClaudeCodeAnalysisApplyDiffDetail{
-					ClaudeCodeAnalysisDetailBase: ClaudeCodeAnalysisDetailBase{
-						FilePath:       filePath,
-						LineCount:      lineCount,
-						CharacterCount: utf8.RuneCountInString(newString),
-						Timestamp:      tsInt,
-					},
-					OldString: oldString,
-					NewString: newString,
-				})
-				uniqueFiles[filePath] = struct{}{}
-				totalDiffCharacters += utf8.RuneCountInString(newString)
-			}
+		if output.IsEdit() {
+			lineCount := CountLines(output.NewString)
+			characterCount := utf8.RuneCountInString(output.NewString)
+			applyDiffDetails = append(applyDiffDetails, ClaudeCodeAnalysisApplyDiffDetail{
+				ClaudeCodeAnalysisDetailBase: base(output.FilePath, lineCount, characterCount, resultTs),
+				OldString:                    output.OldString,
+				NewString:                    output.NewString,
+			})
+			uniqueFiles[output.FilePath] = struct{}{}
+			totalDiffCharacters += characterCount
 		}
 	}
 
 	// 获取 Git remote URL
 	gitRemoteURL = getGitRemoteOriginURL(folderPath)
 
+	// 会话标题：取 leafUuid 指向本会话中最靠后消息的 summary
+	title := ""
+	titleOrder := -1
+	for _, summary := range summaries {
+		if order, ok := uuidOrder[summary.LeafUUID]; ok && order >= titleOrder {
+			title = strings.Trim(strings.TrimSpace(summary.Summary), "\"")
+			titleOrder = order
+		}
+	}
+
 	record := ClaudeCodeAnalysisRecord{
 		TotalUniqueFiles:     len(uniqueFiles),
 		TotalWriteLines:      totalWriteLines,
@@ -298,27 +472,62 @@ func AnalyzeConversations(records []map[string]interface{}) ClaudeCodeAnalysis {
 		ReadFileDetails:      readDetails,
 		ApplyDiffDetails:     applyDiffDetails,
 		RunCommandDetails:    runDetails,
+		WebAccessDetails:     webDetails,
+		WebDomainCounts:      webDomainCounts,
 		ToolCallCounts:       toolCounts,
+		ToolLatencies:        summarizeLatencies(latencySamples),
+		TokenUsage:           TokenUsageByModel(logs),
 		TaskID:               taskID,
 		Timestamp:            lastTimestamp,
 		FolderPath:           folderPath,
 		GitRemoteURL:         gitRemoteURL,
+		Title:                title,
 	}
 
 	// 返回顶级分析对象（注意：这里需要在调用方设置 user, extensionName 等）
 	analysis := ClaudeCodeAnalysis{
-		Records: []ClaudeCodeAnalysisRecord{record},
+		SchemaVersion: SchemaVersion,
+		Records:       []ClaudeCodeAnalysisRecord{record},
 	}
 
 	return analysis
 }
 
+// AnalyzeTranscript 读取 transcript 文件并分析，同时从同目录的 transcript 中匹配会话标题
+func AnalyzeTranscript(path string) (ClaudeCodeAnalysis, error) {
+	records, err := ReadJSONL(path)
+	if err != nil {
+		return ClaudeCodeAnalysis{}, err
+	}
+	// summary 记录在分析中只用于匹配标题
+	records = append(records, ReadSessionSummaries(path, records)...)
+	return AnalyzeConversations(records), nil
+}
+
 // AggregateConversationStats 为了向后兼容，保留原有接口但使用新逻辑
 func AggregateConversationStats(records []map[string]interface{}) []ClaudeCodeAnalysisRecord {
 	analysis := AnalyzeConversations(records)
 	return analysis.Records
 }
 
+// RepoName 返回用于展示与分组的仓库名称：去掉认证信息与 .git 后缀的 remote，没有 remote 时使用目录名称
+func RepoName(record *ClaudeCodeAnalysisRecord) string {
+	remote := strings.TrimSpace(record.GitRemoteURL)
+	if remote == "" {
+		if record.FolderPath == "" {
+			return "unknown"
+		}
+		return filepath.Base(record.FolderPath)
+	}
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		remote = u.Host + u.Path
+	} else if at := strings.Index(remote, "@"); at >= 0 {
+		// scp 形式：git@github.com:org/repo.git
+		remote = strings.Replace(remote[at+1:], ":", "/", 1)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(remote, "/"), ".git")
+}
+
 func getGitRemoteOriginURL(cwd string) string {
 	if cwd == "" {
 		return ""
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -239,6 +240,231 @@ func TestParser_EmptyRecords_ReturnsEmpty(t *testing.T) {
 	}
 }
 
+func TestParser_WebAccessDetails(t *testing.T) {
+	recs := []map[string]interface{}{
+		{
+			"type":      "assistant",
+			"uuid":      "w1",
+			"cwd":       "/repo",
+			"sessionId": "sessWeb",
+			"timestamp": "2025-01-01T00:00:00Z",
+			"message": map[string]interface{}{
+				"content": []interface{}{
+					map[string]interface{}{
+						"type": "tool_use",
+						"id":   "toolu_fetch",
+						"name": "WebFetch",
+						"input": map[string]interface{}{
+							"url":    "https://GitHub.com:443/golang/go/releases",
+							"prompt": "latest version",
+						},
+					},
+					map[string]interface{}{
+						"type":  "tool_use",
+						"id":    "toolu_search",
+						"name":  "WebSearch",
+						"input": map[string]interface{}{"query": "golang release"},
+					},
+				},
+			},
+		},
+		{
+			"type":       "user",
+			"parentUuid": "w1",
+			"sessionId":  "sessWeb",
+			"timestamp":  "2025-01-01T00:00:02Z",
+			"message": map[string]interface{}{
+				"content": []interface{}{
+					map[string]interface{}{"type": "tool_result", "tool_use_id": "toolu_fetch", "content": "go1.25"},
+					map[string]interface{}{
+						"type":        "tool_result",
+						"tool_use_id": "toolu_search",
+						"content":     []interface{}{map[string]interface{}{"type": "text", "text": "result"}},
+					},
+				},
+			},
+		},
+	}
+
+	record := AnalyzeConversations(recs).Records[0]
+	if record.ToolCallCounts.WebFetch != 1 || record.ToolCallCounts.WebSearch != 1 {
+		t.Errorf("web tool counts mismatch: %+v", record.ToolCallCounts)
+	}
+	if len(record.WebAccessDetails) != 2 {
+		t.Fatalf("expected 2 web access details, got %+v", record.WebAccessDetails)
+	}
+	fetch := record.WebAccessDetails[0]
+	if fetch.Domain != "github.com" || fetch.PromptLength != 14 || fetch.ResultSize != 6 {
+		t.Errorf("WebFetch detail mismatch: %+v", fetch)
+	}
+	search := record.WebAccessDetails[1]
+	if search.Query != "golang release" || search.Domain != "" || search.ResultSize != 6 {
+		t.Errorf("WebSearch detail mismatch: %+v", search)
+	}
+	if len(record.WebDomainCounts) != 1 || record.WebDomainCounts["github.com"] != 1 {
+		t.Errorf("WebDomainCounts mismatch: %+v", record.WebDomainCounts)
+	}
+}
+
+func TestParser_SessionTitleFromSummary(t *testing.T) {
+	recs := []map[string]interface{}{
+		// summary of an earlier session carried over on resume; must not match
+		{"type": "summary", "summary": "Previous Session", "leafUuid": "other-session-leaf"},
+		{"type": "summary", "summary": "\"Early Title\"", "leafUuid": "u1"},
+		{"type": "summary", "summary": "Git Commit Creation Following Repository Convention", "leafUuid": "u2"},
+		{"type": "user", "uuid": "u1", "sessionId": "sessTitle", "cwd": "/repo", "timestamp": "2025-01-01T00:00:00Z"},
+		{"type": "assistant", "uuid": "u2", "sessionId": "sessTitle", "cwd": "/repo", "timestamp": "2025-01-01T00:00:01Z"},
+	}
+
+	record := AnalyzeConversations(recs).Records[0]
+	if record.Title != "Git Commit Creation Following Repository Convention" {
+		t.Errorf("title mismatch, got %q", record.Title)
+	}
+	if record.TaskID != "sessTitle" {
+		t.Errorf("taskId expected 'sessTitle', got %q", record.TaskID)
+	}
+
+	// Only a carried-over summary: no title, and trailing summary lines must not clear taskId
+	recs = []map[string]interface{}{
+		{"type": "user", "uuid": "u1", "sessionId": "sessTitle", "timestamp": "2025-01-01T00:00:00Z"},
+		{"type": "summary", "summary": "Previous Session", "leafUuid": "other-session-leaf"},
+	}
+	record = AnalyzeConversations(recs).Records[0]
+	if record.Title != "" || record.TaskID != "sessTitle" {
+		t.Errorf("expected empty title and taskId 'sessTitle', got %q / %q", record.Title, record.TaskID)
+	}
+}
+
+func TestAnalyzeTranscript_TitleFromSiblingTranscript(t *testing.T) {
+	dir := t.TempDir()
+	session := `{"type":"user","uuid":"leaf-1","sessionId":"sessA","timestamp":"2025-01-01T00:00:00Z"}` + "\n"
+	// Claude Code writes the title of sessA into a later transcript of the same project
+	later := `{"type":"summary","summary":"Azure Gateway Project Setup Discussion","leafUuid":"leaf-1"}` + "\n" +
+		`{"type":"user","uuid":"leaf-2","sessionId":"sessB","timestamp":"2025-01-02T00:00:00Z"}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "sessA.jsonl"), []byte(session), 0o644); err != nil {
+		t.Fatalf("write transcript: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sessB.jsonl"), []byte(later), 0o644); err != nil {
+		t.Fatalf("write transcript: %v", err)
+	}
+
+	analysis, err := AnalyzeTranscript(filepath.Join(dir, "sessA.jsonl"))
+	if err != nil {
+		t.Fatalf("AnalyzeTranscript error: %v", err)
+	}
+	record := analysis.Records[0]
+	if record.Title != "Azure Gateway Project Setup Discussion" || record.TaskID != "sessA" {
+		t.Errorf("unexpected title/taskId: %q / %q", record.Title, record.TaskID)
+	}
+}
+
+func TestReadSessionSummaries(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sessA.jsonl")
+	records := []map[string]interface{}{
+		{"type": "user", "uuid": "leaf-1", "sessionId": "sessA", "timestamp": "2025-01-01T00:00:00Z"},
+	}
+	write := func(name, content string, modTime time.Time) {
+		t.Helper()
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	write("sessA.jsonl", `{"type":"user","uuid":"leaf-1","sessionId":"sessA"}`+"\n", later)
+	// the summary sits in the middle of a later transcript and is not written in compact form
+	// lines longer than any summary (tool results, images) are skipped without being parsed
+	huge := `{"type":"summary","summary":"` + strings.Repeat("x", 2*maxSummaryLine) + `","leafUuid":"leaf-1"}`
+	write("sessB.jsonl", `{"type":"user","uuid":"leaf-2","sessionId":"sessB"}`+"\n"+huge+"\n"+
+		`{"leafUuid": "leaf-1", "type": "summary", "summary": "Title A"}`+"\n"+
+		`{"type":"summary","summary":"Title B","leafUuid":"leaf-2"}`+"\n"+
+		`{"type":"user","message":{"content":"{\"type\":\"summary\"}"}}`+"\n", later)
+	// modified before sessA started, so it cannot hold the title of sessA
+	write("old.jsonl", `{"type":"summary","summary":"Stale","leafUuid":"leaf-1"}`+"\n",
+		time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC))
+
+	summaries := ReadSessionSummaries(path, records)
+	if len(summaries) != 1 || summaries[0]["summary"] != "Title A" || summaries[0]["leafUuid"] != "leaf-1" {
+		t.Fatalf("expected only the summary of leaf-1 from sessB, got %v", summaries)
+	}
+}
+
+func TestParser_ToolLatencies(t *testing.T) {
+	var recs []map[string]interface{}
+	// five Bash calls taking 1s..5s, plus one MCP call without a result
+	for i := 1; i <= 5; i++ {
+		id := "toolu_bash_" + string(rune('0'+i))
+		recs = append(recs,
+			map[string]interface{}{
+				"type":      "assistant",
+				"sessionId": "sessLatency",
+				"timestamp": "2025-01-01T00:00:00.000Z",
+				"message": map[string]interface{}{
+					"content": []interface{}{
+						map[string]interface{}{"type": "tool_use", "id": id, "name": "Bash", "input": map[string]interface{}{"command": "make"}},
+					},
+				},
+			},
+			map[string]interface{}{
+				"type":      "user",
+				"sessionId": "sessLatency",
+				"timestamp": time.Date(2025, 1, 1, 0, 0, i, 0, time.UTC).Format(time.RFC3339Nano),
+				"message": map[string]interface{}{
+					"content": []interface{}{
+						map[string]interface{}{"type": "tool_result", "tool_use_id": id, "content": "ok"},
+					},
+				},
+			},
+		)
+	}
+	recs = append(recs, map[string]interface{}{
+		"type":      "assistant",
+		"timestamp": "2025-01-01T00:00:09.000Z",
+		"message": map[string]interface{}{
+			"content": []interface{}{
+				map[string]interface{}{"type": "tool_use", "id": "toolu_mcp", "name": "mcp__jira__search"},
+			},
+		},
+	})
+
+	record := AnalyzeConversations(recs).Records[0]
+	bash, ok := record.ToolLatencies["Bash"]
+	if !ok {
+		t.Fatalf("missing Bash latency: %+v", record.ToolLatencies)
+	}
+	if bash.Count != 5 || bash.P50Ms != 3000 || bash.P90Ms != 5000 || bash.MaxMs != 5000 {
+		t.Errorf("Bash latency mismatch: %+v", bash)
+	}
+	if _, ok := record.ToolLatencies["mcp__jira__search"]; ok {
+		t.Errorf("tool without result should not have latency: %+v", record.ToolLatencies)
+	}
+	if len(record.RunCommandDetails) != 5 || record.RunCommandDetails[1].LatencyMs != 2000 {
+		t.Errorf("run command detail latency mismatch: %+v", record.RunCommandDetails)
+	}
+}
+
+func TestRepoName(t *testing.T) {
+	for remote, want := range map[string]string{
+		"git@github.com:acme/api.git":          "github.com/acme/api",
+		"https://user:pw@gitlab.example/a/b/":  "gitlab.example/a/b",
+		"ssh://git@host:2222/team/project.git": "host:2222/team/project",
+	} {
+		if got := RepoName(&ClaudeCodeAnalysisRecord{GitRemoteURL: remote}); got != want {
+			t.Errorf("RepoName(%q) = %q, want %q", remote, got, want)
+		}
+	}
+	if got := RepoName(&ClaudeCodeAnalysisRecord{FolderPath: "/work/tools"}); got != "tools" {
+		t.Errorf("expected folder name fallback, got %q", got)
+	}
+	if got := RepoName(&ClaudeCodeAnalysisRecord{}); got != "unknown" {
+		t.Errorf("expected unknown, got %q", got)
+	}
+}
+
 // Integration tests that execute the binary and hit network are purposely omitted
 // to keep tests hermetic. End-to-end behavior is covered by unit tests using
 // AnalyzeConversations and real sample JSONL lines.
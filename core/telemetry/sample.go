@@ -0,0 +1,59 @@
+package telemetry
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// SampleDetails 按比例保留每条记录的明细列表，rate 不在 (0, 1) 内时不做任何处理
+//
+// 是否保留由工具调用的哈希决定，同一会话重复上传时保留的明细相同；
+// 汇总字段（total*、toolCallCounts、tokenUsage 等）仍按完整的 transcript 计算，
+// 被采样的记录以 detailSampleRate 标明比例
+func SampleDetails(analysis *ClaudeCodeAnalysis, rate float64) {
+	if rate <= 0 || rate >= 1 {
+		return
+	}
+	for i := range analysis.Records {
+		record := &analysis.Records[i]
+		record.WriteToFileDetails = sampleDetails(record.TaskID, rate, record.WriteToFileDetails, func(d ClaudeCodeAnalysisWriteDetail) ClaudeCodeAnalysisDetailBase {
+			return d.ClaudeCodeAnalysisDetailBase
+		})
+		record.ReadFileDetails = sampleDetails(record.TaskID, rate, record.ReadFileDetails, func(d ClaudeCodeAnalysisReadDetail) ClaudeCodeAnalysisDetailBase {
+			return d.ClaudeCodeAnalysisDetailBase
+		})
+		record.ApplyDiffDetails = sampleDetails(record.TaskID, rate, record.ApplyDiffDetails, func(d ClaudeCodeAnalysisApplyDiffDetail) ClaudeCodeAnalysisDetailBase {
+			return d.ClaudeCodeAnalysisDetailBase
+		})
+		record.RunCommandDetails = sampleDetails(record.TaskID, rate, record.RunCommandDetails, func(d ClaudeCodeAnalysisRunCommandDetail) ClaudeCodeAnalysisDetailBase {
+			return d.ClaudeCodeAnalysisDetailBase
+		})
+		record.WebAccessDetails = sampleDetails(record.TaskID, rate, record.WebAccessDetails, func(d ClaudeCodeAnalysisWebAccessDetail) ClaudeCodeAnalysisDetailBase {
+			return d.ClaudeCodeAnalysisDetailBase
+		})
+		record.DetailSampleRate = rate
+	}
+}
+
+// sampleDetails 返回保留的明细，结果不为 nil 以符合 schema
+func sampleDetails[T any](taskID string, rate float64, details []T, base func(T) ClaudeCodeAnalysisDetailBase) []T {
+	kept := make([]T, 0, int(float64(len(details))*rate)+1)
+	for _, detail := range details {
+		if sampled(taskID, base(detail), rate) {
+			kept = append(kept, detail)
+		}
+	}
+	return kept
+}
+
+// sampled 将工具调用哈希到 [0, 1)，小于 rate 时保留
+func sampled(taskID string, base ClaudeCodeAnalysisDetailBase, rate float64) bool {
+	key := taskID + "\x00" + base.ToolUseID
+	if base.ToolUseID == "" {
+		key = fmt.Sprintf("%s\x00%d\x00%s", taskID, base.Timestamp, base.FilePath)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return float64(binary.BigEndian.Uint64(sum[:8]))/math.MaxUint64 < rate
+}
@@ -0,0 +1,66 @@
+package telemetry
+
+import (
+	"fmt"
+	"testing"
+)
+
+func sampleRecord(taskID string, n int) ClaudeCodeAnalysisRecord {
+	record := ClaudeCodeAnalysisRecord{
+		TaskID:          taskID,
+		TotalWriteLines: 1000,
+		ToolCallCounts:  ClaudeCodeAnalysisToolCalls{Read: n},
+		WebDomainCounts: map[string]int{},
+		ToolLatencies:   map[string]ClaudeCodeAnalysisToolLatency{},
+	}
+	clearDetails(&record)
+	for i := 0; i < n; i++ {
+		base := ClaudeCodeAnalysisDetailBase{FilePath: "f.go", Timestamp: int64(i), ToolUseID: fmt.Sprintf("toolu_%d", i)}
+		record.ReadFileDetails = append(record.ReadFileDetails, ClaudeCodeAnalysisReadDetail{ClaudeCodeAnalysisDetailBase: base})
+		record.RunCommandDetails = append(record.RunCommandDetails, ClaudeCodeAnalysisRunCommandDetail{ClaudeCodeAnalysisDetailBase: base, Command: "ls"})
+	}
+	return record
+}
+
+func TestSampleDetails(t *testing.T) {
+	analysis := &ClaudeCodeAnalysis{SchemaVersion: SchemaVersion, Records: []ClaudeCodeAnalysisRecord{sampleRecord("s1", 1000)}}
+	SampleDetails(analysis, 0.2)
+	record := analysis.Records[0]
+
+	kept := len(record.ReadFileDetails)
+	if kept < 150 || kept > 250 {
+		t.Errorf("expected about 200 of 1000 details kept, got %d", kept)
+	}
+	// 同一个工具调用在各类明细中的取舍一致
+	if len(record.RunCommandDetails) != kept {
+		t.Errorf("read and command details sampled differently: %d vs %d", kept, len(record.RunCommandDetails))
+	}
+	if record.DetailSampleRate != 0.2 || record.TotalWriteLines != 1000 || record.ToolCallCounts.Read != 1000 {
+		t.Errorf("summary fields should be kept: %+v", record.ToolCallCounts)
+	}
+	if record.WriteToFileDetails == nil {
+		t.Errorf("empty detail lists should stay empty lists")
+	}
+
+	// 结果是确定的，同一会话重复上传时保留相同的明细
+	again := &ClaudeCodeAnalysis{Records: []ClaudeCodeAnalysisRecord{sampleRecord("s1", 1000)}}
+	SampleDetails(again, 0.2)
+	for i, detail := range again.Records[0].ReadFileDetails {
+		if detail.ToolUseID != record.ReadFileDetails[i].ToolUseID {
+			t.Fatalf("sampling is not deterministic at %d", i)
+		}
+	}
+	if err := Validate(analysis); err != nil {
+		t.Errorf("sampled payload does not match schema: %v", err)
+	}
+}
+
+func TestSampleDetails_NoOp(t *testing.T) {
+	for _, rate := range []float64{0, 1, 1.5, -1} {
+		analysis := &ClaudeCodeAnalysis{Records: []ClaudeCodeAnalysisRecord{sampleRecord("s1", 10)}}
+		SampleDetails(analysis, rate)
+		if len(analysis.Records[0].ReadFileDetails) != 10 || analysis.Records[0].DetailSampleRate != 0 {
+			t.Errorf("rate %v should not sample", rate)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SchemaVersion 是上传负载的格式版本，写入 ClaudeCodeAnalysis.schemaVersion
+// 只在不兼容的修改（删除或改名字段、改变类型）时递增；新增可选字段不需要递增
+const SchemaVersion = 1
+
+// maxValidationErrors 限制一次校验报告的错误数量
+const maxValidationErrors = 10
+
+// JSONSchema - JSON Schema（draft 2020-12）的子集，足以描述遥测负载
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Const                interface{}            `json:"const,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Defs                 map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+// ValidationError - 负载不符合 schema，Errors 为带 JSON 路径的错误描述
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	return "payload does not match schema v" + fmt.Sprint(SchemaVersion) + ": " + strings.Join(e.Errors, "; ")
+}
+
+var (
+	schemaOnce   sync.Once
+	cachedSchema *JSONSchema
+)
+
+// Schema 返回由 ClaudeCodeAnalysis 的结构与 json 标签生成的 JSON Schema
+//
+// 没有 omitempty 的字段为必需字段；具名结构体放在 $defs 中以 $ref 引用
+func Schema() *JSONSchema {
+	schemaOnce.Do(func() {
+		g := &schemaGenerator{defs: make(map[string]*JSONSchema)}
+		root := g.structSchema(reflect.TypeOf(ClaudeCodeAnalysis{}))
+		root.Properties["schemaVersion"].Const = SchemaVersion
+		root.Schema = "https://json-schema.org/draft/2020-12/schema"
+		root.Title = "ClaudeCodeAnalysis"
+		root.Defs = g.defs
+		cachedSchema = root
+	})
+	return cachedSchema
+}
+
+// Validate 检查分析结果序列化后是否符合 Schema
+func Validate(analysis *ClaudeCodeAnalysis) error {
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return fmt.Errorf("failed to marshal analysis: %w", err)
+	}
+	return ValidateJSON(data)
+}
+
+// ValidateJSON 检查 JSON 负载是否符合 Schema
+func ValidateJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	root := Schema()
+	v := &validator{defs: root.Defs}
+	v.validate(root, value, "$")
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+// schemaGenerator 以反射生成 schema，具名结构体只生成一次
+type schemaGenerator struct {
+	defs map[string]*JSONSchema
+}
+
+func (g *schemaGenerator) typeSchema(t reflect.Type) *JSONSchema {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.typeSchema(t.Elem())
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: g.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // 先占位，避免递归类型无限展开
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return &JSONSchema{Ref: "#/$defs/" + t.Name()}
+	default:
+		// interface{} 等无法确定类型的字段接受任意值
+		return &JSONSchema{}
+	}
+}
+
+// structSchema 按 encoding/json 的规则展开结构体字段，嵌入的结构体字段提升到外层
+func (g *schemaGenerator) structSchema(t reflect.Type) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+	g.addFields(schema, t)
+	sort.Strings(schema.Required)
+	return schema
+}
+
+func (g *schemaGenerator) addFields(schema *JSONSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.addFields(schema, field.Type)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = g.typeSchema(field.Type)
+		if !strings.Contains(","+options+",", ",omitempty,") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// validator 按 schema 检查 json.Decoder（UseNumber）解码后的值
+type validator struct {
+	defs   map[string]*JSONSchema
+	errors []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if len(v.errors) < maxValidationErrors {
+		v.errors = append(v.errors, path+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) validate(schema *JSONSchema, value interface{}, path string) {
+	if schema.Ref != "" {
+		def, ok := v.defs[strings.TrimPrefix(schema.Ref, "#/$defs/")]
+		if !ok {
+			v.fail(path, "unknown reference %s", schema.Ref)
+			return
+		}
+		schema = def
+	}
+	if schema.Const != nil && fmt.Sprint(value) != fmt.Sprint(schema.Const) {
+		v.fail(path, "must be %v, got %v", schema.Const, value)
+		return
+	}
+
+	switch schema.Type {
+	case "":
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			v.fail(path, "expected object, got %s", jsonType(value))
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				v.fail(path, "missing required property %q", name)
+			}
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if property, ok := schema.Properties[key]; ok {
+				v.validate(property, object[key], path+"."+key)
+			} else if schema.AdditionalProperties != nil {
+				v.validate(schema.AdditionalProperties, object[key], path+"["+fmt.Sprintf("%q", key)+"]")
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, "expected array, got %s", jsonType(value))
+			return
+		}
+		for i, item := range array {
+			v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "integer":
+		number, ok := value.(json.Number)
+		if _, err := number.Int64(); !ok || err != nil {
+			v.fail(path, "expected integer, got %s", jsonType(value))
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			v.fail(path, "expected number, got %s", jsonType(value))
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			v.fail(path, "expected string, got %s", jsonType(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "expected boolean, got %s", jsonType(value))
+		}
+	}
+}
+
+// jsonType 返回值的 JSON 类型名称，用于错误信息
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,134 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func projectRoot(t *testing.T) string {
+	_, thisFile, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatalf("failed to get caller info")
+	}
+	return filepath.Dir(filepath.Dir(filepath.Dir(thisFile)))
+}
+
+func TestSchema_Structure(t *testing.T) {
+	schema := Schema()
+	if got := schema.Properties["schemaVersion"]; got.Type != "integer" || got.Const != SchemaVersion {
+		t.Errorf("schemaVersion = %+v", got)
+	}
+	if contains(schema.Required, "idempotencyKey") || !contains(schema.Required, "records") {
+		t.Errorf("root required = %v", schema.Required)
+	}
+
+	record := schema.Defs["ClaudeCodeAnalysisRecord"]
+	if record == nil {
+		t.Fatalf("missing ClaudeCodeAnalysisRecord definition")
+	}
+	if contains(record.Required, "tokenUsage") || !contains(record.Required, "writeToFileDetails") {
+		t.Errorf("record required = %v", record.Required)
+	}
+	if got := record.Properties["toolLatencies"]; got.Type != "object" || got.AdditionalProperties.Ref != "#/$defs/ClaudeCodeAnalysisToolLatency" {
+		t.Errorf("toolLatencies = %+v", got)
+	}
+	if got := record.Properties["codeSurvival"]; got.Ref != "#/$defs/ClaudeCodeAnalysisCodeSurvival" {
+		t.Errorf("pointer field should reference its struct, got %+v", got)
+	}
+
+	// 嵌入的 DetailBase 字段提升到明细上
+	write := schema.Defs["ClaudeCodeAnalysisWriteDetail"]
+	for _, name := range []string{"filePath", "lineCount", "content"} {
+		if _, ok := write.Properties[name]; !ok {
+			t.Errorf("write detail is missing %q", name)
+		}
+	}
+	if _, ok := schema.Defs["ClaudeCodeAnalysisDetailBase"]; ok {
+		t.Errorf("embedded struct should not get its own definition")
+	}
+}
+
+// examples/schema.json 供 collector 与 Python 工具使用，必须与类型定义一致
+func TestSchema_MatchesCommittedFile(t *testing.T) {
+	committed, err := os.ReadFile(filepath.Join(projectRoot(t), "examples", "schema.json"))
+	if err != nil {
+		t.Fatalf("read schema.json: %v", err)
+	}
+	generated, _ := json.MarshalIndent(Schema(), "", "  ")
+	if !bytes.Equal(bytes.TrimSpace(committed), generated) {
+		t.Errorf("examples/schema.json is out of date, regenerate it with: claude_analysis schema > examples/schema.json")
+	}
+}
+
+func TestValidate_ExampleTranscripts(t *testing.T) {
+	paths, _ := filepath.Glob(filepath.Join(projectRoot(t), "examples", "original", "*", "*.jsonl"))
+	paths = append(paths, filepath.Join(projectRoot(t), "examples", "test_conversation.jsonl"))
+	for _, path := range paths {
+		analysis, err := AnalyzeTranscript(path)
+		if err != nil {
+			t.Fatalf("AnalyzeTranscript(%s): %v", path, err)
+		}
+		if err := Validate(&analysis); err != nil {
+			t.Errorf("%s: %v", filepath.Base(path), err)
+		}
+		// 拆分后的每个部分同样需要符合 schema
+		parts, err := SplitAnalysis(&analysis, 4096)
+		if err != nil {
+			t.Fatalf("SplitAnalysis: %v", err)
+		}
+		for _, part := range parts {
+			if err := Validate(part); err != nil {
+				t.Errorf("%s part %d: %v", filepath.Base(path), part.Part, err)
+			}
+		}
+	}
+}
+
+func TestValidateJSON_Errors(t *testing.T) {
+	analysis := AnalyzeConversations(nil)
+	analysis.Records = []ClaudeCodeAnalysisRecord{{TaskID: "s1"}}
+	clearDetails(&analysis.Records[0])
+	analysis.Records[0].WebDomainCounts = map[string]int{}
+	analysis.Records[0].ToolLatencies = map[string]ClaudeCodeAnalysisToolLatency{}
+	if err := Validate(&analysis); err != nil {
+		t.Fatalf("expected a valid payload, got %v", err)
+	}
+
+	valid, _ := json.Marshal(analysis)
+	tests := []struct {
+		name, from, to, want string
+	}{
+		{"null details", `"readFileDetails":[]`, `"readFileDetails":null`, "$.records[0].readFileDetails: expected array, got null"},
+		{"wrong type", `"taskId":"s1"`, `"taskId":1`, "$.records[0].taskId: expected string, got integer"},
+		{"fractional integer", `"timestamp":0`, `"timestamp":1.5`, "$.records[0].timestamp: expected integer, got number"},
+		{"missing property", `"folderPath":"",`, ``, `$.records[0]: missing required property "folderPath"`},
+		{"schema version", `"schemaVersion":1`, `"schemaVersion":2`, "$.schemaVersion: must be 1, got 2"},
+		{"map values", `"webDomainCounts":{}`, `"webDomainCounts":{"a.com":"x"}`, `$.records[0].webDomainCounts["a.com"]: expected integer`},
+	}
+	for _, tt := range tests {
+		payload := strings.Replace(string(valid), tt.from, tt.to, 1)
+		if payload == string(valid) {
+			t.Fatalf("%s: %q not found in payload", tt.name, tt.from)
+		}
+		err := ValidateJSON([]byte(payload))
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SplitAnalysis 将超过 maxBytes 的分析结果拆分为多个部分，每个部分带有 part/totalParts
+//
+// 第一个包含某条记录的部分带有该记录的全部汇总字段，之后的部分只带有识别字段
+// （taskId、timestamp、folderPath、gitRemoteUrl、title）和剩余的明细，
+// collector 以 idempotencyKey 与 taskId 合并明细即可还原。单条明细本身超过上限时会单独成为一个部分。
+func SplitAnalysis(analysis *ClaudeCodeAnalysis, maxBytes int) ([]*ClaudeCodeAnalysis, error) {
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal analysis: %w", err)
+	}
+	if maxBytes <= 0 || len(data) <= maxBytes {
+		return []*ClaudeCodeAnalysis{analysis}, nil
+	}
+
+	envelope := *analysis
+	envelope.Records = []ClaudeCodeAnalysisRecord{}
+	envelope.Part, envelope.TotalParts = 9999, 9999
+	s := &splitter{maxBytes: maxBytes, envelope: envelope, envelopeSize: jsonSize(envelope)}
+	s.newPart()
+
+	for _, record := range analysis.Records {
+		head := record
+		clearDetails(&head)
+		s.addRecord(head)
+
+		skeleton := ClaudeCodeAnalysisRecord{
+			TaskID:       record.TaskID,
+			Timestamp:    record.Timestamp,
+			FolderPath:   record.FolderPath,
+			GitRemoteURL: record.GitRemoteURL,
+			Title:        record.Title,
+			// 续接部分不重复统计值，但仍输出空对象以符合 schema
+			WebDomainCounts: map[string]int{},
+			ToolLatencies:   map[string]ClaudeCodeAnalysisToolLatency{},
+		}
+		clearDetails(&skeleton)
+
+		addDetails(s, skeleton, record.WriteToFileDetails, func(r *ClaudeCodeAnalysisRecord) *[]ClaudeCodeAnalysisWriteDetail { return &r.WriteToFileDetails })
+		addDetails(s, skeleton, record.ReadFileDetails, func(r *ClaudeCodeAnalysisRecord) *[]ClaudeCodeAnalysisReadDetail { return &r.ReadFileDetails })
+		addDetails(s, skeleton, record.ApplyDiffDetails, func(r *ClaudeCodeAnalysisRecord) *[]ClaudeCodeAnalysisApplyDiffDetail { return &r.ApplyDiffDetails })
+		addDetails(s, skeleton, record.RunCommandDetails, func(r *ClaudeCodeAnalysisRecord) *[]ClaudeCodeAnalysisRunCommandDetail { return &r.RunCommandDetails })
+		addDetails(s, skeleton, record.WebAccessDetails, func(r *ClaudeCodeAnalysisRecord) *[]ClaudeCodeAnalysisWebAccessDetail { return &r.WebAccessDetails })
+	}
+
+	for i, part := range s.parts {
+		part.Part = i + 1
+		part.TotalParts = len(s.parts)
+	}
+	return s.parts, nil
+}
+
+// splitter 以贪心方式把记录与明细装入各个部分
+type splitter struct {
+	maxBytes     int
+	envelope     ClaudeCodeAnalysis
+	envelopeSize int
+	parts        []*ClaudeCodeAnalysis
+	size         int  // 当前部分的估计大小
+	fresh        bool // 当前部分除了续接用的识别字段外还没有内容
+}
+
+func (s *splitter) current() *ClaudeCodeAnalysis {
+	return s.parts[len(s.parts)-1]
+}
+
+func (s *splitter) newPart() {
+	part := s.envelope
+	part.Records = []ClaudeCodeAnalysisRecord{}
+	s.parts = append(s.parts, &part)
+	s.size = s.envelopeSize
+	s.fresh = true
+}
+
+// addRecord 在当前部分加入一条记录，放不下时开始新的部分
+func (s *splitter) addRecord(record ClaudeCodeAnalysisRecord) {
+	size := jsonSize(record) + 1
+	if s.size+size > s.maxBytes && !s.fresh {
+		s.newPart()
+	}
+	part := s.current()
+	part.Records = append(part.Records, record)
+	s.size += size
+	s.fresh = false
+}
+
+// addDetails 把一条记录的某类明细逐条加入，放不下时在新的部分以 skeleton 续接该记录
+func addDetails[T any](s *splitter, skeleton ClaudeCodeAnalysisRecord, details []T, field func(*ClaudeCodeAnalysisRecord) *[]T) {
+	for _, detail := range details {
+		size := jsonSize(detail) + 1
+		if s.size+size > s.maxBytes && !s.fresh {
+			s.newPart()
+			part := s.current()
+			part.Records = append(part.Records, skeleton)
+			clearDetails(&part.Records[len(part.Records)-1])
+			s.size += jsonSize(skeleton) + 1
+		}
+		part := s.current()
+		target := field(&part.Records[len(part.Records)-1])
+		*target = append(*target, detail)
+		s.size += size
+		s.fresh = false
+	}
+}
+
+// clearDetails 将明细列表重置为空列表（而不是 null）
+func clearDetails(record *ClaudeCodeAnalysisRecord) {
+	record.WriteToFileDetails = []ClaudeCodeAnalysisWriteDetail{}
+	record.ReadFileDetails = []ClaudeCodeAnalysisReadDetail{}
+	record.ApplyDiffDetails = []ClaudeCodeAnalysisApplyDiffDetail{}
+	record.RunCommandDetails = []ClaudeCodeAnalysisRunCommandDetail{}
+	record.WebAccessDetails = []ClaudeCodeAnalysisWebAccessDetail{}
+}
+
+func jsonSize(v interface{}) int {
+	data, _ := json.Marshal(v)
+	return len(data)
+}
@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSplitAnalysis(t *testing.T) {
+	record := ClaudeCodeAnalysisRecord{TaskID: "s1", Title: "big session", TotalWriteLines: 100}
+	clearDetails(&record)
+	for i := 0; i < 20; i++ {
+		record.WriteToFileDetails = append(record.WriteToFileDetails, ClaudeCodeAnalysisWriteDetail{
+			ClaudeCodeAnalysisDetailBase: ClaudeCodeAnalysisDetailBase{FilePath: "f.go", Timestamp: int64(i)},
+			Content:                      strings.Repeat("x", 200),
+		})
+		record.RunCommandDetails = append(record.RunCommandDetails, ClaudeCodeAnalysisRunCommandDetail{Command: "go test ./..."})
+	}
+	analysis := &ClaudeCodeAnalysis{User: "u", MachineID: "m", IdempotencyKey: "k", Records: []ClaudeCodeAnalysisRecord{record}}
+
+	// 未超过上限时原样返回
+	parts, err := SplitAnalysis(analysis, 1<<20)
+	if err != nil || len(parts) != 1 || parts[0] != analysis || parts[0].TotalParts != 0 {
+		t.Fatalf("expected the analysis unchanged, got %d parts / %v", len(parts), err)
+	}
+
+	const limit = 1500
+	parts, err = SplitAnalysis(analysis, limit)
+	if err != nil {
+		t.Fatalf("SplitAnalysis error: %v", err)
+	}
+	if len(parts) < 3 {
+		t.Fatalf("expected several parts, got %d", len(parts))
+	}
+
+	var writes, commands int
+	for i, part := range parts {
+		data, _ := json.Marshal(part)
+		if len(data) > limit {
+			t.Errorf("part %d is %d bytes, over the %d limit", i+1, len(data), limit)
+		}
+		if part.Part != i+1 || part.TotalParts != len(parts) || part.IdempotencyKey != "k" || part.User != "u" {
+			t.Errorf("part %d has wrong envelope: %+v", i+1, part)
+		}
+		if len(part.Records) != 1 || part.Records[0].TaskID != "s1" || part.Records[0].Title != "big session" {
+			t.Fatalf("part %d lost record identity: %+v", i+1, part.Records)
+		}
+		if (i == 0) != (part.Records[0].TotalWriteLines == 100) {
+			t.Errorf("summary fields should only be in the first part")
+		}
+		for j, detail := range part.Records[0].WriteToFileDetails {
+			if detail.Timestamp != int64(writes+j) {
+				t.Errorf("write details out of order")
+			}
+		}
+		writes += len(part.Records[0].WriteToFileDetails)
+		commands += len(part.Records[0].RunCommandDetails)
+	}
+	if writes != 20 || commands != 20 {
+		t.Errorf("details lost: %d writes, %d commands", writes, commands)
+	}
+	if len(analysis.Records[0].WriteToFileDetails) != 20 {
+		t.Errorf("input analysis must not be modified")
+	}
+}
@@ -0,0 +1,148 @@
+package telemetry
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ClaudeCodeAnalysisFileSurvival - 单个文件中 AI 写入代码的存活情况
+type ClaudeCodeAnalysisFileSurvival struct {
+	FilePath       string `json:"filePath"`
+	SurvivingLines int    `json:"survivingLines"`
+	RevertedLines  int    `json:"revertedLines"`
+	FileMissing    bool   `json:"fileMissing,omitempty"`
+}
+
+// ClaudeCodeAnalysisCodeSurvival - 与工作区比对后的代码存活统计
+type ClaudeCodeAnalysisCodeSurvival struct {
+	CheckedAt      int64                            `json:"checkedAt"`
+	SurvivingLines int                              `json:"survivingLines"`
+	RevertedLines  int                              `json:"revertedLines"`
+	SurvivalRate   float64                          `json:"survivalRate"`
+	Files          []ClaudeCodeAnalysisFileSurvival `json:"files"`
+}
+
+// survivalChange 是按 transcript 顺序排列后的一次写入或编辑
+type survivalChange struct {
+	filePath  string
+	timestamp int64
+	sequence  int
+	create    bool
+	oldString string
+	newString string
+}
+
+// survivalLines 将内容拆成去掉首尾空白的非空行；空行不计入存活统计
+func survivalLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			lines = append(lines, trimmed)
+		}
+	}
+	return lines
+}
+
+// CheckCodeSurvival 检查每次 Write 的内容和 Edit 的 newString 是否仍存在于磁盘文件中，
+// 并把结果写入 record.CodeSurvival。AI 自己后续编辑替换掉的行不计入统计。
+func CheckCodeSurvival(record *ClaudeCodeAnalysisRecord) {
+	var changes []survivalChange
+	for _, detail := range record.WriteToFileDetails {
+		changes = append(changes, survivalChange{
+			filePath:  detail.FilePath,
+			timestamp: detail.Timestamp,
+			sequence:  detail.sequence,
+			create:    true,
+			newString: detail.Content,
+		})
+	}
+	for _, detail := range record.ApplyDiffDetails {
+		changes = append(changes, survivalChange{
+			filePath:  detail.FilePath,
+			timestamp: detail.Timestamp,
+			sequence:  detail.sequence,
+			oldString: detail.OldString,
+			newString: detail.NewString,
+		})
+	}
+	// Write 与 Edit 分开收集，需要合并回调用顺序；时间戳只到秒，同一秒内的先后只能由 transcript 顺序决定。
+	// 从 JSON 还原的记录没有顺序，才退回按时间戳排序
+	sequenced := true
+	for _, change := range changes {
+		if change.sequence == 0 {
+			sequenced = false
+			break
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if sequenced {
+			return changes[i].sequence < changes[j].sequence
+		}
+		return changes[i].timestamp < changes[j].timestamp
+	})
+
+	// 每个文件中 AI 贡献的行（多重集合），按首次出现顺序记录文件
+	contributed := make(map[string]map[string]int)
+	var order []string
+	for _, change := range changes {
+		lines, ok := contributed[change.filePath]
+		if !ok || change.create {
+			if !ok {
+				order = append(order, change.filePath)
+			}
+			lines = make(map[string]int)
+			contributed[change.filePath] = lines
+		}
+		for _, line := range survivalLines(change.oldString) {
+			if lines[line] > 0 {
+				lines[line]--
+			}
+		}
+		for _, line := range survivalLines(change.newString) {
+			lines[line]++
+		}
+	}
+
+	survival := &ClaudeCodeAnalysisCodeSurvival{
+		CheckedAt: time.Now().Unix(),
+		Files:     []ClaudeCodeAnalysisFileSurvival{},
+	}
+	for _, filePath := range order {
+		fileSurvival := ClaudeCodeAnalysisFileSurvival{FilePath: filePath}
+
+		diskPath := filePath
+		if !filepath.IsAbs(diskPath) && record.FolderPath != "" {
+			diskPath = filepath.Join(record.FolderPath, diskPath)
+		}
+		onDisk := make(map[string]int)
+		if data, err := os.ReadFile(diskPath); err == nil {
+			for _, line := range survivalLines(string(data)) {
+				onDisk[line]++
+			}
+		} else {
+			fileSurvival.FileMissing = true
+		}
+
+		for line, count := range contributed[filePath] {
+			surviving := count
+			if onDisk[line] < surviving {
+				surviving = onDisk[line]
+			}
+			fileSurvival.SurvivingLines += surviving
+			fileSurvival.RevertedLines += count - surviving
+		}
+		if fileSurvival.SurvivingLines+fileSurvival.RevertedLines == 0 {
+			continue
+		}
+		survival.SurvivingLines += fileSurvival.SurvivingLines
+		survival.RevertedLines += fileSurvival.RevertedLines
+		survival.Files = append(survival.Files, fileSurvival)
+	}
+	if total := survival.SurvivingLines + survival.RevertedLines; total > 0 {
+		survival.SurvivalRate = float64(survival.SurvivingLines) / float64(total)
+	}
+	record.CodeSurvival = survival
+}
@@ -0,0 +1,97 @@
+package telemetry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckCodeSurvival(t *testing.T) {
+	dir := t.TempDir()
+	// a.go: written with 3 lines, then AI edits "b := 2" -> "b := 3", then the user drops "c := 4"
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("a := 1\n\nb := 3\n"), 0o644); err != nil {
+		t.Fatalf("write a.go: %v", err)
+	}
+
+	record := ClaudeCodeAnalysisRecord{
+		FolderPath: dir,
+		WriteToFileDetails: []ClaudeCodeAnalysisWriteDetail{
+			{
+				ClaudeCodeAnalysisDetailBase: ClaudeCodeAnalysisDetailBase{FilePath: filepath.Join(dir, "a.go"), Timestamp: 1},
+				Content:                      "a := 1\n\nb := 2\nc := 4\n",
+			},
+			{
+				// relative paths resolve under FolderPath; file was deleted since
+				ClaudeCodeAnalysisDetailBase: ClaudeCodeAnalysisDetailBase{FilePath: "gone.txt", Timestamp: 3},
+				Content:                      "x\ny",
+			},
+		},
+		ApplyDiffDetails: []ClaudeCodeAnalysisApplyDiffDetail{
+			{
+				ClaudeCodeAnalysisDetailBase: ClaudeCodeAnalysisDetailBase{FilePath: filepath.Join(dir, "a.go"), Timestamp: 2},
+				OldString:                    "b := 2",
+				NewString:                    "b := 3",
+			},
+		},
+	}
+
+	CheckCodeSurvival(&record)
+	survival := record.CodeSurvival
+	if survival == nil {
+		t.Fatalf("expected CodeSurvival to be set")
+	}
+	if len(survival.Files) != 2 {
+		t.Fatalf("expected 2 files, got %+v", survival.Files)
+	}
+
+	a := survival.Files[0]
+	if a.SurvivingLines != 2 || a.RevertedLines != 1 || a.FileMissing {
+		t.Errorf("a.go survival mismatch: %+v", a)
+	}
+	gone := survival.Files[1]
+	if gone.FilePath != "gone.txt" || !gone.FileMissing || gone.RevertedLines != 2 {
+		t.Errorf("gone.txt survival mismatch: %+v", gone)
+	}
+	if survival.SurvivingLines != 2 || survival.RevertedLines != 3 {
+		t.Errorf("totals mismatch: %+v", survival)
+	}
+	if survival.SurvivalRate != 0.4 {
+		t.Errorf("survival rate expected 0.4, got %v", survival.SurvivalRate)
+	}
+}
+
+func TestCheckCodeSurvival_OrdersChangesByTranscript(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("c := 3\n"), 0o644); err != nil {
+		t.Fatalf("write a.go: %v", err)
+	}
+
+	// an Edit followed by a Write that replaces the whole file, both within the same second
+	var recs []map[string]interface{}
+	names := []string{"Edit", "Write"}
+	for i, result := range []map[string]interface{}{
+		{"filePath": "a.go", "oldString": "b := 1", "newString": "b := 2"},
+		{"type": "create", "filePath": "a.go", "content": "c := 3\n"},
+	} {
+		uuid := string(rune('a' + i))
+		recs = append(recs,
+			map[string]interface{}{
+				"type": "assistant", "uuid": uuid, "cwd": dir, "sessionId": "sessOrder",
+				"timestamp": "2025-01-01T00:00:00.100Z",
+				"message": map[string]interface{}{"content": []interface{}{
+					map[string]interface{}{"type": "tool_use", "name": names[i]},
+				}},
+			},
+			map[string]interface{}{
+				"parentUuid": uuid, "timestamp": "2025-01-01T00:00:00.500Z", "toolUseResult": result,
+			},
+		)
+	}
+
+	record := AnalyzeConversations(recs).Records[0]
+	CheckCodeSurvival(&record)
+	survival := record.CodeSurvival
+	if survival.SurvivingLines != 1 || survival.RevertedLines != 0 {
+		t.Errorf("the later Write should replace the edited lines, got %+v", survival)
+	}
+}
@@ -0,0 +1,428 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// TextBlock - text 内容块
+type TextBlock struct {
+	Text string `json:"text"`
+}
+
+// ThinkingBlock - thinking 内容块（扩展思考）
+type ThinkingBlock struct {
+	Thinking  string `json:"thinking"`
+	Signature string `json:"signature"`
+}
+
+// ToolUseBlock - 助手发起的工具调用
+type ToolUseBlock struct {
+	ID    string                 `json:"id"`
+	Name  string                 `json:"name"`
+	Input map[string]interface{} `json:"input"`
+}
+
+// InputString 返回输入参数中的字符串字段，不存在或类型不符时返回空字符串
+func (b *ToolUseBlock) InputString(key string) string {
+	s, _ := b.Input[key].(string)
+	return s
+}
+
+// ToolResultBlock - 用户消息中返回给模型的工具结果
+type ToolResultBlock struct {
+	ToolUseID string            `json:"tool_use_id"`
+	Content   ToolResultContent `json:"content"`
+	IsError   bool              `json:"is_error"`
+}
+
+// ImageSource - image 内容块的数据来源
+type ImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// ImageBlock - image 内容块
+type ImageBlock struct {
+	Source ImageSource `json:"source"`
+}
+
+// ContentBlock - message.content 中的单个内容块，按 Type 填充对应的类型化字段
+type ContentBlock struct {
+	Type       string
+	Text       *TextBlock
+	Thinking   *ThinkingBlock
+	ToolUse    *ToolUseBlock
+	ToolResult *ToolResultBlock
+	Image      *ImageBlock
+}
+
+// UnmarshalJSON 根据 type 字段解析为对应的内容块；未知类型只保留 Type
+func (b *ContentBlock) UnmarshalJSON(data []byte) error {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		// 非对象的内容块直接忽略，避免整行解析失败
+		return nil
+	}
+	*b = ContentBlock{Type: head.Type}
+
+	// 单个内容块解析失败时只丢弃该块的细节，不影响整条消息
+	switch head.Type {
+	case "text":
+		var v TextBlock
+		if json.Unmarshal(data, &v) == nil {
+			b.Text = &v
+		}
+	case "thinking":
+		var v ThinkingBlock
+		if json.Unmarshal(data, &v) == nil {
+			b.Thinking = &v
+		}
+	case "tool_use":
+		var v ToolUseBlock
+		if json.Unmarshal(data, &v) == nil {
+			b.ToolUse = &v
+		}
+	case "tool_result":
+		var v ToolResultBlock
+		if json.Unmarshal(data, &v) == nil {
+			b.ToolResult = &v
+		}
+	case "image":
+		var v ImageBlock
+		if json.Unmarshal(data, &v) == nil {
+			b.Image = &v
+		}
+	}
+	return nil
+}
+
+// ToolResultContent - tool_result 的 content，可能是字符串或内容块数组
+type ToolResultContent struct {
+	Blocks []ContentBlock
+}
+
+// UnmarshalJSON 支持字符串和内容块数组两种格式
+func (c *ToolResultContent) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		c.Blocks = []ContentBlock{{Type: "text", Text: &TextBlock{Text: s}}}
+		return nil
+	}
+	var blocks []ContentBlock
+	if json.Unmarshal(data, &blocks) == nil {
+		c.Blocks = blocks
+	}
+	return nil
+}
+
+// String 返回所有 text 块拼接后的文本
+func (c ToolResultContent) String() string {
+	var b strings.Builder
+	for _, block := range c.Blocks {
+		if block.Text != nil {
+			b.WriteString(block.Text.Text)
+		}
+	}
+	return b.String()
+}
+
+// MessageContent - message.content，用户消息可能直接是字符串
+type MessageContent []ContentBlock
+
+// UnmarshalJSON 将字符串内容转换为单个 text 块
+func (c *MessageContent) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*c = MessageContent{{Type: "text", Text: &TextBlock{Text: s}}}
+		return nil
+	}
+	var blocks []ContentBlock
+	if json.Unmarshal(data, &blocks) == nil {
+		*c = blocks
+	}
+	return nil
+}
+
+// MessageUsage - 助手消息的 token 用量
+type MessageUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// TranscriptMessage - transcript 中的 message 字段
+type TranscriptMessage struct {
+	ID         string         `json:"id"`
+	Role       string         `json:"role"`
+	Model      string         `json:"model"`
+	Content    MessageContent `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      *MessageUsage  `json:"usage"`
+}
+
+// UnmarshalJSON 宽松解析：非对象的 message（例如直接是字符串）按 content 处理，
+// 字段类型不符时只丢弃该字段，与内容块的解析方式一致，避免整行被跳过
+func (m *TranscriptMessage) UnmarshalJSON(data []byte) error {
+	*m = TranscriptMessage{}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return m.Content.UnmarshalJSON(data)
+	}
+	type plain TranscriptMessage
+	if json.Unmarshal(data, (*plain)(m)) == nil {
+		return nil
+	}
+
+	*m = TranscriptMessage{
+		ID:         rawString(fields, "id"),
+		Role:       rawString(fields, "role"),
+		Model:      rawString(fields, "model"),
+		StopReason: rawString(fields, "stop_reason"),
+	}
+	if raw, ok := fields["content"]; ok {
+		_ = m.Content.UnmarshalJSON(raw)
+	}
+	if raw, ok := fields["usage"]; ok {
+		var usage MessageUsage
+		if json.Unmarshal(raw, &usage) == nil {
+			m.Usage = &usage
+		}
+	}
+	return nil
+}
+
+// ToolUseResultFile - Read 工具结果中的文件信息
+type ToolUseResultFile struct {
+	FilePath   string `json:"filePath"`
+	Content    string `json:"content"`
+	NumLines   int    `json:"numLines"`
+	StartLine  int    `json:"startLine"`
+	TotalLines int    `json:"totalLines"`
+}
+
+// ToolUseResult - transcript 顶层的 toolUseResult，结构随工具而异
+// 字符串形式的结果（通常是错误信息）保存在 Raw 中
+type ToolUseResult struct {
+	Raw  string
+	Type string
+	// Read
+	File *ToolUseResultFile
+	// Write / Edit
+	FilePath  string
+	Content   string
+	OldString string
+	NewString string
+	// Bash
+	Stdout      string
+	Stderr      string
+	Interrupted bool
+	// WebFetch / WebSearch
+	URL        string
+	Bytes      int
+	Code       int
+	Result     string
+	Query      string
+	DurationMs int64
+
+	hasNewString bool
+}
+
+// IsEdit 判断结果是否来自 Edit（带有 newString 字段）
+func (r *ToolUseResult) IsEdit() bool {
+	return r.FilePath != "" && r.hasNewString
+}
+
+// UnmarshalJSON 宽松解析：字段类型不符时忽略该字段，而不是让整行失败
+func (r *ToolUseResult) UnmarshalJSON(data []byte) error {
+	*r = ToolUseResult{}
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		r.Raw = s
+		return nil
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		// 数组等其他格式（例如 MCP 工具）不提取细节
+		return nil
+	}
+
+	r.Type = rawString(fields, "type")
+	if raw, ok := fields["file"]; ok {
+		var file ToolUseResultFile
+		if json.Unmarshal(raw, &file) == nil {
+			r.File = &file
+		}
+	}
+	r.FilePath = rawString(fields, "filePath")
+	r.Content = rawString(fields, "content")
+	r.OldString = rawString(fields, "oldString")
+	r.NewString = rawString(fields, "newString")
+	if raw, ok := fields["newString"]; ok {
+		var v string
+		r.hasNewString = json.Unmarshal(raw, &v) == nil
+	}
+	r.Stdout = rawString(fields, "stdout")
+	r.Stderr = rawString(fields, "stderr")
+	_ = json.Unmarshal(fields["interrupted"], &r.Interrupted)
+	r.URL = rawString(fields, "url")
+	r.Bytes = int(rawNumber(fields, "bytes"))
+	r.Code = int(rawNumber(fields, "code"))
+	r.Result = rawString(fields, "result")
+	r.Query = rawString(fields, "query")
+	r.DurationMs = int64(rawNumber(fields, "durationMs"))
+	if r.DurationMs == 0 {
+		r.DurationMs = int64(rawNumber(fields, "durationSeconds") * 1000)
+	}
+	return nil
+}
+
+// rawString 读取字符串字段，类型不符时返回空字符串
+func rawString(fields map[string]json.RawMessage, key string) string {
+	var s string
+	if raw, ok := fields[key]; ok {
+		_ = json.Unmarshal(raw, &s)
+	}
+	return s
+}
+
+// rawNumber 读取数值字段，类型不符时返回 0
+func rawNumber(fields map[string]json.RawMessage, key string) float64 {
+	var f float64
+	if raw, ok := fields[key]; ok {
+		_ = json.Unmarshal(raw, &f)
+	}
+	return f
+}
+
+// ToolCall - 一次完整的工具调用：tool_use 与其 tool_result 通过 tool_use_id 关联
+type ToolCall struct {
+	// Use 为 nil 表示只找到了结果（transcript 中缺少对应的 tool_use）
+	Use       *ToolUseBlock
+	CWD       string
+	Timestamp time.Time
+
+	Result          *ToolResultBlock
+	Output          *ToolUseResult
+	ResultTimestamp time.Time
+}
+
+// Name 返回工具名称，缺少 tool_use 时返回空字符串
+func (c *ToolCall) Name() string {
+	if c.Use == nil {
+		return ""
+	}
+	return c.Use.Name
+}
+
+// ID 返回 tool_use_id
+func (c *ToolCall) ID() string {
+	if c.Use != nil {
+		return c.Use.ID
+	}
+	if c.Result != nil {
+		return c.Result.ToolUseID
+	}
+	return ""
+}
+
+// IsError 判断工具结果是否为错误
+func (c *ToolCall) IsError() bool {
+	return c.Result != nil && c.Result.IsError
+}
+
+// DecodeLogs 将原始 JSONL 记录解析为 ClaudeCodeLog，跳过不符合模型的条目
+func DecodeLogs(records []map[string]interface{}) []ClaudeCodeLog {
+	logs := make([]ClaudeCodeLog, 0, len(records))
+	for _, record := range records {
+		recordJSON, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		var claudeCodeLog ClaudeCodeLog
+		if err := json.Unmarshal(recordJSON, &claudeCodeLog); err != nil {
+			continue
+		}
+		logs = append(logs, claudeCodeLog)
+	}
+	return logs
+}
+
+// LinkToolCalls 将 tool_use 与 tool_result 配对，按 tool_use 出现顺序返回
+// 优先使用 tool_use_id；缺少 id 时退回到 parentUuid 指向的助手消息，
+// 但只在该消息恰好只有一个未完成的调用时才配对，并行调用无法区分时结果保持孤立
+func LinkToolCalls(logs []ClaudeCodeLog) []*ToolCall {
+	var calls []*ToolCall
+	byID := make(map[string]*ToolCall)
+	byMessage := make(map[string][]*ToolCall)
+
+	// onlyOpen 返回 parentUuid 对应消息中唯一满足条件的调用，没有或多于一个时返回 nil
+	onlyOpen := func(parentUUID string, open func(*ToolCall) bool) *ToolCall {
+		var found *ToolCall
+		for _, call := range byMessage[parentUUID] {
+			if open(call) {
+				if found != nil {
+					return nil
+				}
+				found = call
+			}
+		}
+		return found
+	}
+
+	for i := range logs {
+		entry := &logs[i]
+		ts := parseISOTime(entry.Timestamp)
+
+		var matched *ToolCall
+		if entry.Message != nil {
+			for _, block := range entry.Message.Content {
+				switch {
+				case block.ToolUse != nil:
+					call := &ToolCall{Use: block.ToolUse, CWD: entry.CWD, Timestamp: ts}
+					calls = append(calls, call)
+					if block.ToolUse.ID != "" {
+						byID[block.ToolUse.ID] = call
+					}
+					if entry.UUID != "" {
+						byMessage[entry.UUID] = append(byMessage[entry.UUID], call)
+					}
+				case block.ToolResult != nil:
+					call := byID[block.ToolResult.ToolUseID]
+					if call == nil && entry.ParentUUID != nil {
+						call = onlyOpen(*entry.ParentUUID, func(c *ToolCall) bool { return c.Result == nil })
+					}
+					if call == nil {
+						call = &ToolCall{CWD: entry.CWD}
+						calls = append(calls, call)
+					}
+					call.Result = block.ToolResult
+					call.ResultTimestamp = ts
+					if matched == nil {
+						matched = call
+					}
+				}
+			}
+		}
+
+		if entry.ToolUseResult == nil {
+			continue
+		}
+		// toolUseResult 属于本条消息中的第一个 tool_result
+		if matched == nil && entry.ParentUUID != nil {
+			matched = onlyOpen(*entry.ParentUUID, func(c *ToolCall) bool { return c.Output == nil })
+		}
+		if matched == nil {
+			matched = &ToolCall{CWD: entry.CWD}
+			calls = append(calls, matched)
+		}
+		matched.Output = entry.ToolUseResult
+		matched.ResultTimestamp = ts
+	}
+	return calls
+}
@@ -0,0 +1,193 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func decodeTranscript(t *testing.T, lines ...string) []ClaudeCodeLog {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range lines {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("invalid test line %s: %v", line, err)
+		}
+		records = append(records, obj)
+	}
+	return DecodeLogs(records)
+}
+
+func TestDecodeLogs_TypedContentBlocks(t *testing.T) {
+	logs := decodeTranscript(t,
+		`{"type":"user","uuid":"u1","message":{"role":"user","content":"plain prompt"}}`,
+		`{"type":"assistant","uuid":"a1","message":{"role":"assistant","model":"claude-sonnet-4","content":[
+			{"type":"thinking","thinking":"hmm","signature":"sig"},
+			{"type":"text","text":"hello"},
+			{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"ls"}}
+		],"usage":{"input_tokens":3,"output_tokens":5}}}`,
+		`{"type":"user","uuid":"u2","message":{"role":"user","content":[
+			{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"a.txt"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AA=="}}],"is_error":true}
+		]},"toolUseResult":"Error: permission denied"}`,
+	)
+	if len(logs) != 3 {
+		t.Fatalf("expected 3 logs, got %d", len(logs))
+	}
+
+	prompt := logs[0].Message.Content
+	if len(prompt) != 1 || prompt[0].Text == nil || prompt[0].Text.Text != "plain prompt" {
+		t.Errorf("string content should become a single text block: %+v", prompt)
+	}
+
+	blocks := logs[1].Message.Content
+	if len(blocks) != 3 || blocks[0].Thinking == nil || blocks[1].Text == nil || blocks[2].ToolUse == nil {
+		t.Fatalf("unexpected assistant blocks: %+v", blocks)
+	}
+	if blocks[2].ToolUse.InputString("command") != "ls" {
+		t.Errorf("tool_use input mismatch: %+v", blocks[2].ToolUse)
+	}
+	if logs[1].Message.Usage == nil || logs[1].Message.Usage.OutputTokens != 5 {
+		t.Errorf("usage not decoded: %+v", logs[1].Message.Usage)
+	}
+
+	result := logs[2].Message.Content[0].ToolResult
+	if result == nil || !result.IsError || result.Content.String() != "a.txt" {
+		t.Fatalf("unexpected tool_result: %+v", result)
+	}
+	if len(result.Content.Blocks) != 2 || result.Content.Blocks[1].Image == nil {
+		t.Errorf("image block not decoded: %+v", result.Content.Blocks)
+	}
+	if logs[2].ToolUseResult == nil || logs[2].ToolUseResult.Raw != "Error: permission denied" {
+		t.Errorf("string toolUseResult should be kept in Raw: %+v", logs[2].ToolUseResult)
+	}
+}
+
+func TestDecodeLogs_TolerantMessage(t *testing.T) {
+	// message itself may be a bare string, and a mistyped field must not drop the line
+	logs := decodeTranscript(t,
+		`{"type":"user","uuid":"u1","message":"plain prompt"}`,
+		`{"type":"assistant","uuid":"a1","message":{"id":42,"model":"claude-sonnet-4","content":[{"type":"text","text":"hi"}],"usage":{"output_tokens":5}}}`,
+		`{"type":"user","uuid":"u2","message":7}`,
+	)
+	if len(logs) != 3 {
+		t.Fatalf("expected 3 logs, got %d", len(logs))
+	}
+	if content := logs[0].Message.Content; len(content) != 1 || content[0].Text == nil || content[0].Text.Text != "plain prompt" {
+		t.Errorf("string message should become a single text block: %+v", logs[0].Message)
+	}
+	msg := logs[1].Message
+	if msg.ID != "" || msg.Model != "claude-sonnet-4" || len(msg.Content) != 1 || msg.Usage == nil || msg.Usage.OutputTokens != 5 {
+		t.Errorf("only the mistyped field should be dropped: %+v", msg)
+	}
+	if logs[2].Message == nil || len(logs[2].Message.Content) != 0 {
+		t.Errorf("non-object message should decode as empty: %+v", logs[2].Message)
+	}
+}
+
+func TestDecodeLogs_TolerantToolUseResult(t *testing.T) {
+	// Task results carry content as an array; it must not drop the whole line
+	logs := decodeTranscript(t,
+		`{"type":"user","uuid":"u1","sessionId":"s","toolUseResult":{"content":[{"type":"text","text":"done"}],"totalDurationMs":10}}`,
+		`{"type":"user","uuid":"u2","sessionId":"s","toolUseResult":[{"type":"text","text":"mcp"}]}`,
+	)
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 logs, got %d", len(logs))
+	}
+	if logs[0].ToolUseResult == nil || logs[0].ToolUseResult.Content != "" {
+		t.Errorf("unexpected toolUseResult: %+v", logs[0].ToolUseResult)
+	}
+}
+
+func TestLinkToolCalls_PairsByToolUseID(t *testing.T) {
+	logs := decodeTranscript(t,
+		`{"type":"assistant","uuid":"a1","cwd":"/repo","timestamp":"2025-01-01T00:00:00.000Z","message":{"content":[
+			{"type":"tool_use","id":"toolu_read","name":"Read","input":{"file_path":"/repo/a.go"}},
+			{"type":"tool_use","id":"toolu_edit","name":"Edit","input":{"file_path":"/repo/a.go","old_string":"a","new_string":"b"}}
+		]}}`,
+		// results arrive in reverse order
+		`{"type":"user","uuid":"u1","parentUuid":"a1","timestamp":"2025-01-01T00:00:03.000Z","message":{"content":[
+			{"type":"tool_result","tool_use_id":"toolu_edit","content":"ok"}
+		]},"toolUseResult":{"filePath":"/repo/a.go","oldString":"a","newString":"b"}}`,
+		`{"type":"user","uuid":"u2","parentUuid":"u1","timestamp":"2025-01-01T00:00:05.000Z","message":{"content":[
+			{"type":"tool_result","tool_use_id":"toolu_read","content":"package a"}
+		]},"toolUseResult":{"type":"text","file":{"filePath":"/repo/a.go","content":"package a","numLines":1}}}`,
+	)
+
+	calls := LinkToolCalls(logs)
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	read, edit := calls[0], calls[1]
+	if read.Name() != "Read" || read.Output == nil || read.Output.File == nil || read.Output.File.FilePath != "/repo/a.go" {
+		t.Errorf("Read call not linked to its result: %+v", read)
+	}
+	if edit.Name() != "Edit" || edit.Output == nil || !edit.Output.IsEdit() || edit.Use.InputString("new_string") != "b" {
+		t.Errorf("Edit call not linked to its result: %+v", edit)
+	}
+	if got := read.ResultTimestamp.Sub(read.Timestamp).Seconds(); got != 5 {
+		t.Errorf("Read result timestamp mismatch, delta %v", got)
+	}
+}
+
+func TestLinkToolCalls_ParentFallback(t *testing.T) {
+	logs := decodeTranscript(t,
+		`{"type":"assistant","uuid":"a1","message":{"content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"ls"}}]}}`,
+		`{"type":"user","uuid":"u1","parentUuid":"a1","message":{"content":[{"type":"tool_result","content":"a.txt"}]}}`,
+		// two parallel calls: a result without id cannot be attributed to either
+		`{"type":"assistant","uuid":"a2","message":{"content":[
+			{"type":"tool_use","id":"toolu_2","name":"Read","input":{"file_path":"a.go"}},
+			{"type":"tool_use","id":"toolu_3","name":"Read","input":{"file_path":"b.go"}}
+		]}}`,
+		`{"type":"user","uuid":"u2","parentUuid":"a2","message":{"content":[{"type":"tool_result","content":"package b"}]},"toolUseResult":{"type":"text","file":{"filePath":"b.go"}}}`,
+	)
+
+	calls := LinkToolCalls(logs)
+	if len(calls) != 4 {
+		t.Fatalf("expected 3 calls and 1 orphan result, got %d", len(calls))
+	}
+	if calls[0].Result == nil || calls[0].Result.Content.String() != "a.txt" {
+		t.Errorf("single open call should be linked through parentUuid: %+v", calls[0])
+	}
+	if calls[1].Result != nil || calls[1].Output != nil || calls[2].Result != nil || calls[2].Output != nil {
+		t.Errorf("parallel calls should not be guessed: %+v / %+v", calls[1], calls[2])
+	}
+	if orphan := calls[3]; orphan.Use != nil || orphan.Result == nil || orphan.Output == nil || orphan.Output.File.FilePath != "b.go" {
+		t.Errorf("ambiguous result should stay orphaned: %+v", orphan)
+	}
+}
+
+func TestLinkToolCalls_OrphanResult(t *testing.T) {
+	logs := decodeTranscript(t,
+		`{"type":"user","uuid":"u1","toolUseResult":{"type":"create","filePath":"x.txt","content":"x"}}`,
+	)
+	calls := LinkToolCalls(logs)
+	if len(calls) != 1 || calls[0].Use != nil || calls[0].Output == nil || calls[0].Output.Type != "create" {
+		t.Fatalf("orphan result should produce a call without tool_use: %+v", calls)
+	}
+	if calls[0].Name() != "" {
+		t.Errorf("orphan call should have no name, got %q", calls[0].Name())
+	}
+}
+
+func TestTokenUsageByModel_DedupesMessageID(t *testing.T) {
+	logs := decodeTranscript(t,
+		`{"type":"assistant","uuid":"a1","message":{"id":"msg_1","model":"claude-sonnet-4","content":[{"type":"thinking","thinking":"..."}],"usage":{"input_tokens":10,"output_tokens":1,"cache_read_input_tokens":100}}}`,
+		`{"type":"assistant","uuid":"a2","message":{"id":"msg_1","model":"claude-sonnet-4","content":[{"type":"text","text":"hi"}],"usage":{"input_tokens":10,"output_tokens":20,"cache_read_input_tokens":100}}}`,
+		`{"type":"assistant","uuid":"a3","message":{"id":"msg_2","model":"claude-sonnet-4","content":[],"usage":{"input_tokens":5,"output_tokens":7,"cache_creation_input_tokens":50}}}`,
+		`{"type":"assistant","uuid":"a4","message":{"id":"msg_3","model":"claude-3-5-haiku","content":[],"usage":{"input_tokens":3,"output_tokens":4}}}`,
+		`{"type":"user","uuid":"u1","message":{"role":"user","content":"hello"}}`,
+	)
+
+	usage := TokenUsageByModel(logs)
+	sonnet := usage["claude-sonnet-4"]
+	if sonnet.Messages != 2 || sonnet.InputTokens != 15 || sonnet.OutputTokens != 27 ||
+		sonnet.CacheReadInputTokens != 100 || sonnet.CacheCreationInputTokens != 50 {
+		t.Errorf("sonnet usage mismatch: %+v", sonnet)
+	}
+	if haiku := usage["claude-3-5-haiku"]; haiku.Messages != 1 || haiku.OutputTokens != 4 {
+		t.Errorf("haiku usage mismatch: %+v", haiku)
+	}
+	if len(usage) != 2 {
+		t.Errorf("unexpected models: %+v", usage)
+	}
+}
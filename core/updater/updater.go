@@ -4,9 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"time"
 
+	"claude_analysis/core/httpclient"
 	"claude_analysis/core/version"
 )
 
@@ -43,13 +43,13 @@ type UpdateResult struct {
 }
 
 // CheckForUpdatesGraceful 檢查是否有新版本可用，優雅處理錯誤
-func CheckForUpdatesGraceful() (*UpdateResult, error) {
+func CheckForUpdatesGraceful(proxy httpclient.Proxy) (*UpdateResult, error) {
 	result := &UpdateResult{
 		CurrentVersion: version.GetVersion(),
 	}
 
 	// 獲取最新版本信息
-	latestRelease, err := getLatestRelease()
+	latestRelease, err := getLatestRelease(proxy)
 	if err != nil {
 		result.Error = fmt.Sprintf("Failed to get latest release: %v", err)
 		result.Message = "Unable to check for updates, continuing with current version"
@@ -70,8 +70,8 @@ func CheckForUpdatesGraceful() (*UpdateResult, error) {
 }
 
 // getLatestRelease 從 Gitea API 獲取最新的 release
-func getLatestRelease() (*Release, error) {
-	client := &http.Client{Timeout: CHECK_TIMEOUT}
+func getLatestRelease(proxy httpclient.Proxy) (*Release, error) {
+	client := httpclient.New(httpclient.Options{Timeout: CHECK_TIMEOUT, Proxy: proxy})
 
 	url := fmt.Sprintf("%s%s?draft=false&pre-release=false", GITEA_BASE_URL, RELEASES_API)
 	resp, err := client.Get(url)
@@ -96,27 +96,16 @@ func getLatestRelease() (*Release, error) {
 	return &releases[0], nil
 }
 
-// ForceUpdateCheck 友善地檢查更新並提醒用戶，不會中斷程序執行
-func ForceUpdateCheck() error {
-	result, err := CheckForUpdatesGraceful()
+// UpdateNotice 檢查更新並返回一行提示訊息，沒有新版本時返回空字串
+// 不輸出到 stderr 也不等待，適合放入 hook 輸出的 systemMessage
+func UpdateNotice(proxy httpclient.Proxy) (string, error) {
+	result, err := CheckForUpdatesGraceful(proxy)
 	if err != nil {
-		// 如果無法檢查更新，僅記錄警告但不阻止程序運行
-		fmt.Fprintf(os.Stderr, "\n⚠️  無法檢查更新: %v\n", err)
-		fmt.Fprintf(os.Stderr, "請稍後手動檢查更新: %s/%s/%s/releases\n\n", GITEA_BASE_URL, REPO_OWNER, REPO_NAME)
-		return nil
+		return "", err
 	}
-
-	if result.HasUpdate {
-		fmt.Fprintf(os.Stderr, "\n🔔 有新版本可用！\n")
-		fmt.Fprintf(os.Stderr, "當前版本: %s\n", result.CurrentVersion)
-		fmt.Fprintf(os.Stderr, "最新版本: %s\n", result.LatestVersion)
-		fmt.Fprintf(os.Stderr, "\n建議手動更新：\n")
-		fmt.Fprintf(os.Stderr, "  前往下載頁面: %s/%s/%s/releases\n", GITEA_BASE_URL, REPO_OWNER, REPO_NAME)
-		fmt.Fprintf(os.Stderr, "\n程序將在 3 秒後繼續執行...\n\n")
-
-		// 等待 3 秒讓用戶看到提示
-		time.Sleep(3 * time.Second)
+	if !result.HasUpdate {
+		return "", nil
 	}
-
-	return nil
+	return fmt.Sprintf("claude_analysis %s is available (current %s), download it from %s/%s/%s/releases",
+		result.LatestVersion, result.CurrentVersion, GITEA_BASE_URL, REPO_OWNER, REPO_NAME), nil
 }
@@ -5,13 +5,24 @@ from pathlib import Path
 from datetime import datetime, timezone
 
 import orjsonl
+from jsonschema import Draft202012Validator
 from pydantic import Field, BaseModel, ValidationError
 import machineid
 
 # ============================================================================
 # Claude Code Analysis Models - data used for analysis stats
+#
+# The authoritative payload format is the JSON Schema printed by
+# `claude_analysis schema` (checked in as examples/schema.json). These models
+# cover the subset this sample produces; every payload is validated against
+# the schema before it is written.
 # ============================================================================
 
+SCHEMA_PATH = Path(__file__).parent / "examples" / "schema.json"
+PAYLOAD_SCHEMA = json.loads(SCHEMA_PATH.read_text(encoding="utf-8"))
+PAYLOAD_VALIDATOR = Draft202012Validator(PAYLOAD_SCHEMA)
+SCHEMA_VERSION: int = PAYLOAD_SCHEMA["properties"]["schemaVersion"]["const"]
+
 
 class ClaudeCodeAnalysisDetailBase(BaseModel):
     """Base detail model with shared required fields."""
@@ -20,6 +31,7 @@ class ClaudeCodeAnalysisDetailBase(BaseModel):
     lineCount: int
     characterCount: int
     timestamp: int
+    latencyMs: int = 0
 
 
 class ClaudeCodeAnalysisWriteDetail(ClaudeCodeAnalysisDetailBase):
@@ -48,6 +60,26 @@ class ClaudeCodeAnalysisRunCommandDetail(ClaudeCodeAnalysisDetailBase):
     description: str = ""
 
 
+class ClaudeCodeAnalysisWebAccessDetail(ClaudeCodeAnalysisDetailBase):
+    """webAccessDetails: WebFetch/WebSearch calls (not produced by this sample)."""
+
+    toolName: str = ""
+    url: str = ""
+    domain: str = ""
+    query: str = ""
+    promptLength: int = 0
+    resultSize: int = 0
+
+
+class ClaudeCodeAnalysisToolLatency(BaseModel):
+    """Latency percentiles per tool (not produced by this sample)."""
+
+    count: int = 0
+    p50Ms: int = 0
+    p90Ms: int = 0
+    maxMs: int = 0
+
+
 class ClaudeCodeAnalysisToolCalls(BaseModel):
     """Counters for how many times each tool was invoked."""
 
@@ -56,6 +88,8 @@ class ClaudeCodeAnalysisToolCalls(BaseModel):
     Edit: int = 0
     TodoWrite: int = 0
     Bash: int = 0
+    WebFetch: int = 0
+    WebSearch: int = 0
 
 
 class ClaudeCodeAnalysisRecord(BaseModel):
@@ -70,8 +104,12 @@ class ClaudeCodeAnalysisRecord(BaseModel):
     readFileDetails: list[ClaudeCodeAnalysisReadDetail]
     applyDiffDetails: list[ClaudeCodeAnalysisApplyDiffDetail]
     runCommandDetails: list[ClaudeCodeAnalysisRunCommandDetail]
+    webAccessDetails: list[ClaudeCodeAnalysisWebAccessDetail] = []
+    webDomainCounts: dict[str, int] = {}
     toolCallCounts: ClaudeCodeAnalysisToolCalls
+    toolLatencies: dict[str, ClaudeCodeAnalysisToolLatency] = {}
     taskId: str
+    title: str = ""
     timestamp: int
     folderPath: str
     gitRemoteUrl: str
@@ -86,6 +124,7 @@ class ClaudeCodeAnalysis(BaseModel):
     # Hardcoded for now to keep the sample simple.
     insightsVersion: str = "0.1.0"
     machineId: str = machineid.id()
+    schemaVersion: int = SCHEMA_VERSION
     records: list[ClaudeCodeAnalysisRecord] = []
 
 
@@ -435,9 +474,18 @@ def analyze_conversations(input_path: str) -> None:
         )
 
         analysis = ClaudeCodeAnalysis(records=[record])
+        payload = analysis.model_dump(mode="json")
+
+        # Fail loudly when the models drift from the schema the Go tool publishes
+        errors = sorted(PAYLOAD_VALIDATOR.iter_errors(payload), key=lambda e: list(e.absolute_path))
+        if errors:
+            details = "\n".join(
+                f"  {'/'.join(str(p) for p in error.absolute_path) or '<root>'}: {error.message}" for error in errors
+            )
+            raise ValueError(f"{conversation_path.name}: output does not match {SCHEMA_PATH.name}:\n{details}")
 
         with output_path.open("w", encoding="utf-8") as f:
-            json.dump(analysis.model_dump(mode="json"), f, ensure_ascii=False, indent=4)
+            json.dump(payload, f, ensure_ascii=False, indent=4)
 
 
 if __name__ == "__main__":